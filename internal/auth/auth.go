@@ -2,8 +2,12 @@ package auth
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
 	"os"
+	"strings"
 
 	"github.com/bentsolheim/gmail-cli/internal/config"
 	"github.com/pkg/browser"
@@ -13,50 +17,155 @@ import (
 	"google.golang.org/api/option"
 )
 
+// ServiceOptions configures the Gmail service created by GetGmailService and ForceReauth.
+type ServiceOptions struct {
+	// Retry controls retries of rate-limited and transient server errors.
+	Retry RetryConfig
+	// Verbose reports retries on stderr.
+	Verbose bool
+	// Flow configures the browser flow when a new token is needed.
+	Flow FlowOptions
+	// Endpoint is the base URL of the Gmail API, e.g. a local fake server.
+	// Empty means Google's production endpoint.
+	Endpoint string
+	// NoAuth sends requests without credentials. It requires Endpoint.
+	NoAuth bool
+}
+
+// FlowOptions configures the OAuth authorization flow.
+type FlowOptions struct {
+	// NoBrowser prints the authorization URL and reads the redirect URL or
+	// authorization code from stdin instead of running a callback server.
+	NoBrowser bool
+	// ListenAddr is the host:port the callback server listens on.
+	// Empty means a random port on localhost.
+	ListenAddr string
+}
+
+// DefaultServiceOptions returns the default service options.
+func DefaultServiceOptions() ServiceOptions {
+	return ServiceOptions{
+		Retry: DefaultRetryConfig(),
+	}
+}
+
 // GetGmailService returns an authenticated Gmail service.
 // If a valid token exists, it uses that. Otherwise, it initiates the OAuth flow.
-func GetGmailService(ctx context.Context) (*gmail.Service, error) {
-	oauthConfig, err := loadOAuthConfig()
+// With opts.NoAuth, no credentials are loaded at all.
+func GetGmailService(ctx context.Context, opts ServiceOptions) (*gmail.Service, error) {
+	if opts.NoAuth {
+		if opts.Endpoint == "" {
+			return nil, errors.New("skipping authentication requires a custom API endpoint")
+		}
+		return newService(ctx, &http.Client{}, opts)
+	}
+
+	credentials, err := loadCredentials()
+	if err != nil {
+		return nil, err
+	}
+
+	if isServiceAccount(credentials) {
+		tokenSource, err := newServiceAccountTokenSource(ctx, credentials)
+		if err != nil {
+			return nil, err
+		}
+		return newService(ctx, oauth2.NewClient(ctx, tokenSource), opts)
+	}
+
+	oauthConfig, err := parseOAuthConfig(credentials)
 	if err != nil {
 		return nil, err
 	}
 
-	token, err := getToken(ctx, oauthConfig)
+	tokenSource, err := getTokenSource(ctx, oauthConfig, opts.Flow)
 	if err != nil {
 		return nil, err
 	}
 
-	client := oauthConfig.Client(ctx, token)
-	return gmail.NewService(ctx, option.WithHTTPClient(client))
+	return newService(ctx, oauth2.NewClient(ctx, tokenSource), opts)
 }
 
 // ForceReauth performs a fresh OAuth flow, ignoring any existing token.
-func ForceReauth(ctx context.Context) (*gmail.Service, error) {
-	oauthConfig, err := loadOAuthConfig()
+// With service account credentials there is no browser flow; a token is
+// fetched to check that delegation works.
+func ForceReauth(ctx context.Context, opts ServiceOptions) (*gmail.Service, error) {
+	credentials, err := loadCredentials()
+	if err != nil {
+		return nil, err
+	}
+
+	if isServiceAccount(credentials) {
+		tokenSource, err := newServiceAccountTokenSource(ctx, credentials)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := tokenSource.Token(); err != nil {
+			return nil, err
+		}
+		return newService(ctx, oauth2.NewClient(ctx, tokenSource), opts)
+	}
+
+	oauthConfig, err := parseOAuthConfig(credentials)
 	if err != nil {
 		return nil, err
 	}
 
-	token, err := performOAuthFlow(ctx, oauthConfig)
+	store, err := NewTokenStore()
 	if err != nil {
 		return nil, err
 	}
 
-	if err := SaveToken(config.TokenPath(), token); err != nil {
+	token, err := performOAuthFlow(ctx, oauthConfig, opts.Flow)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := store.Save(token); err != nil {
 		return nil, fmt.Errorf("failed to save token: %w", err)
 	}
 
-	client := oauthConfig.Client(ctx, token)
-	return gmail.NewService(ctx, option.WithHTTPClient(client))
+	tokenSource := NewPersistingTokenSource(ctx, oauthConfig, token, store)
+	return newService(ctx, oauth2.NewClient(ctx, tokenSource), opts)
 }
 
-func loadOAuthConfig() (*oauth2.Config, error) {
+// newService creates a Gmail service whose requests are retried according to
+// opts and sent to opts.Endpoint, if set.
+func newService(ctx context.Context, client *http.Client, opts ServiceOptions) (*gmail.Service, error) {
+	var log io.Writer
+	if opts.Verbose {
+		log = os.Stderr
+	}
+	client.Transport = NewRetryTransport(client.Transport, opts.Retry, log)
+
+	serviceOpts := []option.ClientOption{option.WithHTTPClient(client)}
+	if opts.Endpoint != "" {
+		// Request paths are appended to the endpoint
+		serviceOpts = append(serviceOpts, option.WithEndpoint(strings.TrimSuffix(opts.Endpoint, "/")+"/"))
+	}
+	return gmail.NewService(ctx, serviceOpts...)
+}
+
+// loadCredentials reads credentials.json, which holds either installed-app
+// OAuth client secrets or a service account key.
+func loadCredentials() ([]byte, error) {
 	credPath := config.CredentialsPath()
 	b, err := os.ReadFile(credPath)
 	if err != nil {
 		return nil, fmt.Errorf("unable to read credentials file at %s: %w\n\nTo set up authentication:\n1. Go to https://console.cloud.google.com/\n2. Create a project and enable the Gmail API\n3. Create OAuth 2.0 credentials (Desktop app)\n4. Download the credentials and save as %s", credPath, err, credPath)
 	}
+	return b, nil
+}
+
+func loadOAuthConfig() (*oauth2.Config, error) {
+	b, err := loadCredentials()
+	if err != nil {
+		return nil, err
+	}
+	return parseOAuthConfig(b)
+}
 
+func parseOAuthConfig(b []byte) (*oauth2.Config, error) {
 	cfg, err := google.ConfigFromJSON(b, gmail.GmailReadonlyScope)
 	if err != nil {
 		return nil, fmt.Errorf("unable to parse credentials: %w", err)
@@ -65,33 +174,58 @@ func loadOAuthConfig() (*oauth2.Config, error) {
 	return cfg, nil
 }
 
-func getToken(ctx context.Context, cfg *oauth2.Config) (*oauth2.Token, error) {
-	tokenPath := config.TokenPath()
-	token, err := LoadToken(tokenPath)
-	if err == nil && token.Valid() {
-		return token, nil
-	}
-
-	// Token doesn't exist or is invalid, need to authenticate
-	token, err = performOAuthFlow(ctx, cfg)
+// getTokenSource returns a token source for the stored token, refreshing it if
+// it has expired. The browser flow is only used when there is no stored token
+// or the refresh token is no longer accepted.
+func getTokenSource(ctx context.Context, cfg *oauth2.Config, flow FlowOptions) (oauth2.TokenSource, error) {
+	store, err := NewTokenStore()
 	if err != nil {
 		return nil, err
 	}
 
-	if err := config.EnsureConfigDir(); err != nil {
-		return nil, fmt.Errorf("failed to create config directory: %w", err)
+	token, err := store.Load()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to load token from %s: %w", store.Location(), err)
+	}
+	if err == nil && (token.Valid() || token.RefreshToken != "") {
+		tokenSource := NewPersistingTokenSource(ctx, cfg, token, store)
+
+		// Refresh up front so a revoked refresh token falls back to the browser flow
+		_, err := tokenSource.Token()
+		if err == nil {
+			return tokenSource, nil
+		}
+		if !IsInvalidGrant(err) {
+			return nil, fmt.Errorf("failed to refresh token: %w", err)
+		}
+		fmt.Println("Stored token is no longer valid, re-authenticating...")
 	}
 
-	if err := SaveToken(tokenPath, token); err != nil {
+	// Token doesn't exist or can't be refreshed, need to authenticate
+	token, err = performOAuthFlow(ctx, cfg, flow)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := store.Save(token); err != nil {
 		return nil, fmt.Errorf("failed to save token: %w", err)
 	}
 
-	return token, nil
+	return NewPersistingTokenSource(ctx, cfg, token, store), nil
 }
 
-func performOAuthFlow(ctx context.Context, cfg *oauth2.Config) (*oauth2.Token, error) {
+func performOAuthFlow(ctx context.Context, cfg *oauth2.Config, flow FlowOptions) (*oauth2.Token, error) {
+	if flow.NoBrowser {
+		return performManualFlow(ctx, cfg, flow, os.Stdin)
+	}
+
+	session, err := newAuthSession()
+	if err != nil {
+		return nil, err
+	}
+
 	// Start callback server
-	server, err := NewCallbackServer()
+	server, err := NewCallbackServer(flow.ListenAddr, session.state)
 	if err != nil {
 		return nil, err
 	}
@@ -106,7 +240,7 @@ func performOAuthFlow(ctx context.Context, cfg *oauth2.Config) (*oauth2.Token, e
 	server.Start(cancelCtx)
 
 	// Generate auth URL and open browser
-	authURL := cfg.AuthCodeURL("state-token", oauth2.AccessTypeOffline)
+	authURL := session.AuthCodeURL(cfg)
 
 	fmt.Println("Opening browser for authentication...")
 	fmt.Printf("If the browser doesn't open, visit this URL:\n%s\n\n", authURL)
@@ -124,10 +258,5 @@ func performOAuthFlow(ctx context.Context, cfg *oauth2.Config) (*oauth2.Token, e
 	}
 
 	// Exchange code for token
-	token, err := cfg.Exchange(ctx, code)
-	if err != nil {
-		return nil, fmt.Errorf("failed to exchange authorization code: %w", err)
-	}
-
-	return token, nil
+	return session.Exchange(ctx, cfg, code)
 }
@@ -0,0 +1,41 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGetGmailService_NoAuth(t *testing.T) {
+	// No credentials are read from the config directory
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	var gotPath, gotAuth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotAuth = r.URL.Path, r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"labels": []}`))
+	}))
+	defer server.Close()
+
+	opts := DefaultServiceOptions()
+	opts.Endpoint = server.URL
+	opts.NoAuth = true
+	service, err := GetGmailService(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("GetGmailService() error = %v", err)
+	}
+	if _, err := service.Users.Labels.List("me").Do(); err != nil {
+		t.Fatalf("Labels.List() error = %v", err)
+	}
+	if gotPath != "/gmail/v1/users/me/labels" || gotAuth != "" {
+		t.Errorf("request to %q with Authorization %q, want /gmail/v1/users/me/labels without", gotPath, gotAuth)
+	}
+
+	// Without an endpoint, requests would go to Google unauthenticated
+	opts.Endpoint = ""
+	if _, err := GetGmailService(context.Background(), opts); err == nil {
+		t.Error("GetGmailService() with NoAuth and no endpoint succeeded, want error")
+	}
+}
@@ -10,19 +10,27 @@ import (
 // CallbackServer handles the OAuth callback on localhost.
 type CallbackServer struct {
 	listener net.Listener
+	state    string
 	codeChan chan string
 	errChan  chan error
 }
 
-// NewCallbackServer creates a callback server listening on a random available port.
-func NewCallbackServer() (*CallbackServer, error) {
-	listener, err := net.Listen("tcp", "localhost:0")
+// NewCallbackServer creates a callback server listening on addr.
+// An empty addr listens on a random available port on localhost.
+// Callbacks whose state parameter doesn't match state are rejected.
+func NewCallbackServer(addr, state string) (*CallbackServer, error) {
+	if addr == "" {
+		addr = "localhost:0"
+	}
+
+	listener, err := net.Listen("tcp", addr)
 	if err != nil {
 		return nil, fmt.Errorf("failed to start callback server: %w", err)
 	}
 
 	return &CallbackServer{
 		listener: listener,
+		state:    state,
 		codeChan: make(chan string, 1),
 		errChan:  make(chan error, 1),
 	}, nil
@@ -52,24 +60,35 @@ func (s *CallbackServer) Start(ctx context.Context) {
 
 	go func() {
 		if err := server.Serve(s.listener); err != http.ErrServerClosed {
-			s.errChan <- err
+			s.sendErr(err)
 		}
 	}()
 }
 
 func (s *CallbackServer) handleCallback(w http.ResponseWriter, r *http.Request) {
+	// Reject callbacks that weren't started by this flow, so other local
+	// processes can't inject an authorization code
+	if !validState(s.state, r.URL.Query().Get("state")) {
+		http.Error(w, "Invalid state parameter", http.StatusBadRequest)
+		return
+	}
+
 	code := r.URL.Query().Get("code")
 	if code == "" {
 		errMsg := r.URL.Query().Get("error")
 		if errMsg == "" {
 			errMsg = "no authorization code received"
 		}
-		s.errChan <- fmt.Errorf("OAuth error: %s", errMsg)
+		s.sendErr(fmt.Errorf("OAuth error: %s", errMsg))
 		http.Error(w, "Authorization failed", http.StatusBadRequest)
 		return
 	}
 
-	s.codeChan <- code
+	select {
+	case s.codeChan <- code:
+	default:
+		// A code was already received; ignore repeated callbacks
+	}
 
 	w.Header().Set("Content-Type", "text/html")
 	fmt.Fprint(w, `<!DOCTYPE html>
@@ -82,6 +101,14 @@ func (s *CallbackServer) handleCallback(w http.ResponseWriter, r *http.Request)
 </html>`)
 }
 
+// sendErr reports err to WaitForCode without blocking if an error is already pending.
+func (s *CallbackServer) sendErr(err error) {
+	select {
+	case s.errChan <- err:
+	default:
+	}
+}
+
 // WaitForCode blocks until an authorization code is received or an error occurs.
 func (s *CallbackServer) WaitForCode(ctx context.Context) (string, error) {
 	select {
@@ -0,0 +1,180 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+func TestCallbackServer_HandleCallback(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantCode   string
+		wantErr    bool
+	}{
+		{
+			name:       "valid state delivers code",
+			query:      "state=expected&code=auth-code",
+			wantStatus: http.StatusOK,
+			wantCode:   "auth-code",
+		},
+		{
+			name:       "mismatched state is rejected",
+			query:      "state=attacker&code=injected",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "missing state is rejected",
+			query:      "code=injected",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "OAuth error with valid state is reported",
+			query:      "state=expected&error=access_denied",
+			wantStatus: http.StatusBadRequest,
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, err := NewCallbackServer("", "expected")
+			if err != nil {
+				t.Fatalf("NewCallbackServer() error = %v", err)
+			}
+			defer server.Close()
+
+			req := httptest.NewRequest(http.MethodGet, "/callback?"+tt.query, nil)
+			rec := httptest.NewRecorder()
+			server.handleCallback(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			code, err := server.WaitForCode(ctx)
+
+			switch {
+			case tt.wantCode != "":
+				if err != nil || code != tt.wantCode {
+					t.Errorf("WaitForCode() = %q, %v; want %q", code, err, tt.wantCode)
+				}
+			case tt.wantErr:
+				if err == nil || err == context.DeadlineExceeded {
+					t.Errorf("WaitForCode() error = %v, want OAuth error", err)
+				}
+			default:
+				// Rejected callbacks must not end the flow
+				if err != context.DeadlineExceeded {
+					t.Errorf("WaitForCode() = %q, %v; want no code delivered", code, err)
+				}
+			}
+		})
+	}
+}
+
+func TestCallbackServer_RejectsInjectedCodeOverHTTP(t *testing.T) {
+	server, err := NewCallbackServer("", "expected")
+	if err != nil {
+		t.Fatalf("NewCallbackServer() error = %v", err)
+	}
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	server.Start(ctx)
+
+	get := func(query string) int {
+		resp, err := http.Get(server.RedirectURL() + "?" + query)
+		if err != nil {
+			t.Fatalf("GET callback error = %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if status := get("state=forged&code=injected"); status != http.StatusBadRequest {
+		t.Errorf("forged callback status = %d, want %d", status, http.StatusBadRequest)
+	}
+	if status := get("state=expected&code=real"); status != http.StatusOK {
+		t.Errorf("valid callback status = %d, want %d", status, http.StatusOK)
+	}
+
+	waitCtx, waitCancel := context.WithTimeout(ctx, time.Second)
+	defer waitCancel()
+	code, err := server.WaitForCode(waitCtx)
+	if err != nil || code != "real" {
+		t.Errorf("WaitForCode() = %q, %v; want %q", code, err, "real")
+	}
+}
+
+func TestAuthSession_PKCE(t *testing.T) {
+	session, err := newAuthSession()
+	if err != nil {
+		t.Fatalf("newAuthSession() error = %v", err)
+	}
+
+	other, err := newAuthSession()
+	if err != nil {
+		t.Fatalf("newAuthSession() error = %v", err)
+	}
+	if session.state == other.state || session.verifier == other.verifier {
+		t.Error("each session should get a fresh state and verifier")
+	}
+
+	var gotVerifier string
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		gotVerifier = r.Form.Get("code_verifier")
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"access_token":"access","token_type":"Bearer","expires_in":3600}`)
+	}))
+	defer tokenServer.Close()
+
+	cfg := &oauth2.Config{
+		ClientID:    "client",
+		RedirectURL: "http://localhost/callback",
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  "https://accounts.example.com/auth",
+			TokenURL: tokenServer.URL,
+		},
+	}
+
+	authURL, err := url.Parse(session.AuthCodeURL(cfg))
+	if err != nil {
+		t.Fatalf("failed to parse auth URL: %v", err)
+	}
+	query := authURL.Query()
+
+	if query.Get("state") != session.state {
+		t.Errorf("state = %q, want %q", query.Get("state"), session.state)
+	}
+	if query.Get("code_challenge_method") != "S256" {
+		t.Errorf("code_challenge_method = %q, want S256", query.Get("code_challenge_method"))
+	}
+	challenge := query.Get("code_challenge")
+	if challenge == "" || strings.Contains(authURL.RawQuery, session.verifier) {
+		t.Errorf("auth URL should carry the challenge but not the verifier: %s", authURL)
+	}
+
+	if _, err := session.Exchange(context.Background(), cfg, "code"); err != nil {
+		t.Fatalf("Exchange() error = %v", err)
+	}
+	if gotVerifier != session.verifier {
+		t.Errorf("code_verifier = %q, want %q", gotVerifier, session.verifier)
+	}
+	if oauth2.S256ChallengeFromVerifier(gotVerifier) != challenge {
+		t.Error("code_verifier does not match code_challenge")
+	}
+}
@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+
+	"golang.org/x/oauth2"
+)
+
+// performManualFlow runs the OAuth flow without a local callback server.
+// The user opens the authorization URL on any machine, and pastes the URL the
+// browser was redirected to (or just the code) back on input.
+func performManualFlow(ctx context.Context, cfg *oauth2.Config, flow FlowOptions, input io.Reader) (*oauth2.Token, error) {
+	session, err := newAuthSession()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.RedirectURL = manualRedirectURL(flow.ListenAddr)
+
+	authURL := session.AuthCodeURL(cfg)
+
+	fmt.Printf("Visit this URL in a browser on any machine:\n%s\n\n", authURL)
+	fmt.Println("After authorizing, the browser is redirected to a localhost page that will")
+	fmt.Println("likely fail to load. Copy the full URL from the address bar and paste it here.")
+	fmt.Print("\nRedirect URL or authorization code: ")
+
+	line, err := bufio.NewReader(input).ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return nil, fmt.Errorf("failed to read authorization response: %w", err)
+	}
+
+	code, err := parseAuthorizationResponse(line, session.state)
+	if err != nil {
+		return nil, err
+	}
+
+	return session.Exchange(ctx, cfg, code)
+}
+
+// manualRedirectURL returns the redirect URL used by the manual flow. The port
+// from listenAddr is kept so the same client settings work with port forwarding.
+func manualRedirectURL(listenAddr string) string {
+	if _, port, err := net.SplitHostPort(listenAddr); err == nil && port != "" && port != "0" {
+		return fmt.Sprintf("http://localhost:%s/callback", port)
+	}
+	return "http://localhost/callback"
+}
+
+// parseAuthorizationResponse extracts the authorization code from a pasted
+// redirect URL, query string or bare code. A pasted URL must carry the
+// expected state; a bare code is trusted since the user typed it.
+func parseAuthorizationResponse(input, state string) (string, error) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return "", fmt.Errorf("no authorization code entered")
+	}
+
+	if !strings.Contains(input, "code=") && !strings.Contains(input, "error=") {
+		return input, nil
+	}
+
+	query := input
+	if idx := strings.Index(input, "?"); idx >= 0 {
+		query = input[idx+1:]
+	}
+
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse redirect URL: %w", err)
+	}
+
+	if errMsg := values.Get("error"); errMsg != "" {
+		return "", fmt.Errorf("OAuth error: %s", errMsg)
+	}
+
+	if !validState(state, values.Get("state")) {
+		return "", fmt.Errorf("state in redirect URL does not match this authorization request")
+	}
+
+	code := values.Get("code")
+	if code == "" {
+		return "", fmt.Errorf("no authorization code found in redirect URL")
+	}
+
+	return code, nil
+}
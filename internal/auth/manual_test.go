@@ -0,0 +1,75 @@
+package auth
+
+import "testing"
+
+func TestParseAuthorizationResponse(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:  "full redirect URL",
+			input: "http://localhost/callback?state=s3cr3t&code=4/0AbCd-Ef&scope=https://www.googleapis.com/auth/gmail.readonly\n",
+			want:  "4/0AbCd-Ef",
+		},
+		{
+			name:    "redirect URL with wrong state",
+			input:   "http://localhost/callback?state=other&code=4/0AbCd-Ef",
+			wantErr: true,
+		},
+		{
+			name:    "redirect URL without state",
+			input:   "http://localhost/callback?code=4/0AbCd-Ef",
+			wantErr: true,
+		},
+		{
+			name:  "query string only",
+			input: "code=4%2F0AbCd&state=s3cr3t",
+			want:  "4/0AbCd",
+		},
+		{
+			name:  "bare code",
+			input: "  4/0AbCd-Ef  \n",
+			want:  "4/0AbCd-Ef",
+		},
+		{
+			name:    "error in redirect",
+			input:   "http://localhost/callback?error=access_denied",
+			wantErr: true,
+		},
+		{
+			name:    "empty input",
+			input:   "\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseAuthorizationResponse(tt.input, "s3cr3t")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseAuthorizationResponse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseAuthorizationResponse() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestManualRedirectURL(t *testing.T) {
+	tests := map[string]string{
+		"":               "http://localhost/callback",
+		"localhost:0":    "http://localhost/callback",
+		"localhost:8085": "http://localhost:8085/callback",
+		"0.0.0.0:9000":   "http://localhost:9000/callback",
+	}
+
+	for addr, want := range tests {
+		if got := manualRedirectURL(addr); got != want {
+			t.Errorf("manualRedirectURL(%q) = %q, want %q", addr, got, want)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package auth
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetryConfig controls how failed Gmail API requests are retried.
+type RetryConfig struct {
+	// MaxAttempts is the total number of attempts, including the first.
+	MaxAttempts int
+	// MaxElapsed is the total time budget for a request including retries.
+	MaxElapsed time.Duration
+	// BaseDelay is the backoff before the first retry. It doubles on each retry.
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff between two attempts.
+	MaxDelay time.Duration
+}
+
+// DefaultRetryConfig returns the retry settings used for the Gmail service.
+func DefaultRetryConfig() RetryConfig {
+	return RetryConfig{
+		MaxAttempts: 6,
+		MaxElapsed:  2 * time.Minute,
+		BaseDelay:   500 * time.Millisecond,
+		MaxDelay:    30 * time.Second,
+	}
+}
+
+// RetryTransport is an http.RoundTripper that retries requests rejected with
+// rate limit errors or transient server errors, using jittered exponential backoff.
+type RetryTransport struct {
+	Base   http.RoundTripper
+	Config RetryConfig
+	// Log receives a line for every retry. Nil disables logging.
+	Log io.Writer
+
+	// sleep waits for d or until the request is canceled. Replaced in tests.
+	sleep func(req *http.Request, d time.Duration) error
+}
+
+// NewRetryTransport wraps base with retry handling. A nil base uses http.DefaultTransport.
+func NewRetryTransport(base http.RoundTripper, cfg RetryConfig, log io.Writer) *RetryTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &RetryTransport{
+		Base:   base,
+		Config: cfg,
+		Log:    log,
+	}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *RetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 && req.GetBody != nil {
+			// Requests with a body can only be retried if it can be recreated
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+
+		resp, err := t.Base.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+
+		reason, retryable := retryReason(resp)
+		if !retryable || attempt >= t.Config.MaxAttempts || !canRetry(req) {
+			return resp, nil
+		}
+
+		delay := t.backoff(attempt)
+		if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+			delay = retryAfter
+		}
+
+		if t.Config.MaxElapsed > 0 && time.Since(start)+delay > t.Config.MaxElapsed {
+			return resp, nil
+		}
+
+		// Drain and close the body so the connection can be reused
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+
+		if t.Log != nil {
+			fmt.Fprintf(t.Log, "Retrying %s %s in %v (attempt %d/%d): %s\n",
+				req.Method, req.URL.Path, delay.Round(time.Millisecond), attempt+1, t.Config.MaxAttempts, reason)
+		}
+
+		sleep := t.sleep
+		if sleep == nil {
+			sleep = sleepContext
+		}
+		if err := sleep(req, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// backoff returns a full-jitter exponential delay for the given attempt.
+func (t *RetryTransport) backoff(attempt int) time.Duration {
+	delay := t.Config.BaseDelay
+	for i := 1; i < attempt && delay < t.Config.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > t.Config.MaxDelay {
+		delay = t.Config.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return rand.N(delay) + 1
+}
+
+// canRetry reports whether the request can be sent again.
+func canRetry(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// retryReason reports whether the response should be retried and why.
+func retryReason(resp *http.Response) (string, bool) {
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return "rate limited", true
+	case resp.StatusCode == http.StatusInternalServerError,
+		resp.StatusCode == http.StatusBadGateway,
+		resp.StatusCode == http.StatusServiceUnavailable,
+		resp.StatusCode == http.StatusGatewayTimeout:
+		return resp.Status, true
+	case resp.StatusCode == http.StatusForbidden:
+		// Gmail reports quota errors as 403 with a rate limit reason in the body
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		if err != nil {
+			return "", false
+		}
+		for _, reason := range []string{"userRateLimitExceeded", "rateLimitExceeded"} {
+			if strings.Contains(string(body), `"`+reason+`"`) {
+				return reason, true
+			}
+		}
+	}
+	return "", false
+}
+
+// parseRetryAfter parses a Retry-After header given in seconds or as an HTTP date.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := t.Sub(now); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// sleepContext waits for d or until the request context is done.
+func sleepContext(req *http.Request, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-req.Context().Done():
+		return req.Context().Err()
+	}
+}
@@ -0,0 +1,227 @@
+package auth
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestRetryTransport returns a RetryTransport that records delays instead of sleeping.
+func newTestRetryTransport(cfg RetryConfig, log io.Writer) (*RetryTransport, *[]time.Duration) {
+	var delays []time.Duration
+	rt := NewRetryTransport(nil, cfg, log)
+	rt.sleep = func(req *http.Request, d time.Duration) error {
+		delays = append(delays, d)
+		return nil
+	}
+	return rt, &delays
+}
+
+func TestRetryTransport(t *testing.T) {
+	rateLimitBody := `{"error":{"code":403,"errors":[{"reason":"userRateLimitExceeded"}]}}`
+	forbiddenBody := `{"error":{"code":403,"errors":[{"reason":"insufficientPermissions"}]}}`
+
+	tests := []struct {
+		name         string
+		failures     int
+		status       int
+		body         string
+		retryAfter   string
+		wantStatus   int
+		wantAttempts int32
+	}{
+		{
+			name:         "429 is retried",
+			failures:     2,
+			status:       http.StatusTooManyRequests,
+			wantStatus:   http.StatusOK,
+			wantAttempts: 3,
+		},
+		{
+			name:         "503 is retried",
+			failures:     1,
+			status:       http.StatusServiceUnavailable,
+			wantStatus:   http.StatusOK,
+			wantAttempts: 2,
+		},
+		{
+			name:         "403 rate limit is retried",
+			failures:     1,
+			status:       http.StatusForbidden,
+			body:         rateLimitBody,
+			wantStatus:   http.StatusOK,
+			wantAttempts: 2,
+		},
+		{
+			name:         "403 permission error is not retried",
+			failures:     1,
+			status:       http.StatusForbidden,
+			body:         forbiddenBody,
+			wantStatus:   http.StatusForbidden,
+			wantAttempts: 1,
+		},
+		{
+			name:         "404 is not retried",
+			failures:     1,
+			status:       http.StatusNotFound,
+			wantStatus:   http.StatusNotFound,
+			wantAttempts: 1,
+		},
+		{
+			name:         "gives up after max attempts",
+			failures:     10,
+			status:       http.StatusInternalServerError,
+			wantStatus:   http.StatusInternalServerError,
+			wantAttempts: 4,
+		},
+		{
+			name:         "gives up when Retry-After exceeds budget",
+			failures:     1,
+			status:       http.StatusTooManyRequests,
+			retryAfter:   "3600",
+			wantStatus:   http.StatusTooManyRequests,
+			wantAttempts: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if int(attempts.Add(1)) <= tt.failures {
+					if tt.retryAfter != "" {
+						w.Header().Set("Retry-After", tt.retryAfter)
+					}
+					w.WriteHeader(tt.status)
+					fmt.Fprint(w, tt.body)
+					return
+				}
+				fmt.Fprint(w, "ok")
+			}))
+			defer server.Close()
+
+			cfg := RetryConfig{
+				MaxAttempts: 4,
+				MaxElapsed:  time.Minute,
+				BaseDelay:   time.Millisecond,
+				MaxDelay:    10 * time.Millisecond,
+			}
+			rt, _ := newTestRetryTransport(cfg, nil)
+			client := &http.Client{Transport: rt}
+
+			resp, err := client.Get(server.URL)
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if got := attempts.Load(); got != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", got, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestRetryTransport_BodyPreservedForNonRetryable403(t *testing.T) {
+	body := `{"error":{"code":403,"errors":[{"reason":"forbidden"}]}}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, body)
+	}))
+	defer server.Close()
+
+	rt, _ := newTestRetryTransport(DefaultRetryConfig(), nil)
+	resp, err := (&http.Client{Transport: rt}).Get(server.URL)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	defer resp.Body.Close()
+
+	var got bytes.Buffer
+	got.ReadFrom(resp.Body)
+	if got.String() != body {
+		t.Errorf("body = %q, want %q", got.String(), body)
+	}
+}
+
+func TestRetryTransport_HonorsRetryAfterAndLogs(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) == 1 {
+			w.Header().Set("Retry-After", "7")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		fmt.Fprint(w, "ok")
+	}))
+	defer server.Close()
+
+	var log bytes.Buffer
+	rt, delays := newTestRetryTransport(DefaultRetryConfig(), &log)
+	resp, err := (&http.Client{Transport: rt}).Get(server.URL + "/gmail/v1/users/me/threads")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	resp.Body.Close()
+
+	if len(*delays) != 1 || (*delays)[0] != 7*time.Second {
+		t.Errorf("delays = %v, want [7s]", *delays)
+	}
+	if !strings.Contains(log.String(), "Retrying GET /gmail/v1/users/me/threads in 7s (attempt 2/6): rate limited") {
+		t.Errorf("unexpected log output: %q", log.String())
+	}
+}
+
+func TestRetryTransport_BackoffGrowsAndIsCapped(t *testing.T) {
+	rt := NewRetryTransport(nil, RetryConfig{
+		BaseDelay: 100 * time.Millisecond,
+		MaxDelay:  time.Second,
+	}, nil)
+
+	for attempt, max := range map[int]time.Duration{
+		1:  100 * time.Millisecond,
+		2:  200 * time.Millisecond,
+		3:  400 * time.Millisecond,
+		5:  time.Second,
+		40: time.Second,
+		70: time.Second,
+	} {
+		for i := 0; i < 50; i++ {
+			if d := rt.backoff(attempt); d <= 0 || d > max {
+				t.Fatalf("backoff(%d) = %v, want in (0, %v]", attempt, d, max)
+			}
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 12, 1, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value  string
+		want   time.Duration
+		wantOK bool
+	}{
+		{"", 0, false},
+		{"30", 30 * time.Second, true},
+		{"Mon, 01 Dec 2025 10:00:05 GMT", 5 * time.Second, true},
+		{"Mon, 01 Dec 2025 09:00:00 GMT", 0, true},
+		{"soon", 0, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := parseRetryAfter(tt.value, now)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("parseRetryAfter(%q) = %v, %v; want %v, %v", tt.value, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/bentsolheim/gmail-cli/internal/config"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/gmail/v1"
+)
+
+// isServiceAccount reports whether credentials is a service account key.
+func isServiceAccount(credentials []byte) bool {
+	var key struct {
+		Type string `json:"type"`
+	}
+	return json.Unmarshal(credentials, &key) == nil && key.Type == "service_account"
+}
+
+// serviceAccountTokenSource fetches tokens for a service account that
+// impersonates a Workspace user through domain-wide delegation.
+type serviceAccountTokenSource struct {
+	source   oauth2.TokenSource
+	clientID string
+	email    string
+	subject  string
+}
+
+// newServiceAccountTokenSource returns a token source that impersonates the
+// configured subject with the service account key in credentials.
+func newServiceAccountTokenSource(ctx context.Context, credentials []byte) (*serviceAccountTokenSource, error) {
+	subject := config.ServiceAccountSubject()
+	if subject == "" {
+		return nil, fmt.Errorf("service account credentials need a user to impersonate: run 'gmail-cli auth --subject user@example.com' or set %s", config.SubjectEnv)
+	}
+
+	jwtConfig, err := google.JWTConfigFromJSON(credentials, gmail.GmailReadonlyScope)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse service account key: %w", err)
+	}
+	jwtConfig.Subject = subject
+
+	var key struct {
+		ClientID string `json:"client_id"`
+	}
+	json.Unmarshal(credentials, &key)
+
+	return &serviceAccountTokenSource{
+		source:   oauth2.ReuseTokenSource(nil, jwtConfig.TokenSource(ctx)),
+		clientID: key.ClientID,
+		email:    jwtConfig.Email,
+		subject:  subject,
+	}, nil
+}
+
+// Token returns a token for the impersonated user.
+func (s *serviceAccountTokenSource) Token() (*oauth2.Token, error) {
+	token, err := s.source.Token()
+	if err != nil {
+		if isDelegationError(err) {
+			return nil, fmt.Errorf("service account %s may not impersonate %s: grant domain-wide delegation to client ID %s with scope %s in the Google Workspace admin console: %w",
+				s.email, s.subject, s.clientID, gmail.GmailReadonlyScope, err)
+		}
+		return nil, fmt.Errorf("failed to get service account token: %w", err)
+	}
+	return token, nil
+}
+
+// Description describes the service account and impersonated user, for display.
+func (s *serviceAccountTokenSource) Description() string {
+	return fmt.Sprintf("service account %s impersonating %s", s.email, s.subject)
+}
+
+// isDelegationError reports whether err means domain-wide delegation is not set up.
+func isDelegationError(err error) bool {
+	var retrieveErr *oauth2.RetrieveError
+	if !errors.As(err, &retrieveErr) {
+		return false
+	}
+
+	// The JWT flow doesn't parse the error body into ErrorCode
+	code := retrieveErr.ErrorCode
+	if code == "" {
+		var body struct {
+			Error string `json:"error"`
+		}
+		json.Unmarshal(retrieveErr.Body, &body)
+		code = body.Error
+	}
+	return code == "unauthorized_client" || code == "access_denied"
+}
@@ -0,0 +1,142 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bentsolheim/gmail-cli/internal/config"
+)
+
+// newServiceAccountKey returns a service account key JSON whose token_uri
+// points at tokenURL.
+func newServiceAccountKey(t *testing.T, tokenURL string) []byte {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := json.Marshal(map[string]string{
+		"type":           "service_account",
+		"client_email":   "reader@project.iam.gserviceaccount.com",
+		"client_id":      "1234567890",
+		"private_key_id": "key1",
+		"private_key":    string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"token_uri":      tokenURL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestServiceAccountTokenSource(t *testing.T) {
+	var gotSubject string
+	delegated := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+
+		// The JWT assertion's payload carries the impersonated user as "sub"
+		parts := strings.Split(r.PostForm.Get("assertion"), ".")
+		if len(parts) == 3 {
+			payload, _ := base64.RawURLEncoding.DecodeString(parts[1])
+			var claims struct {
+				Sub string `json:"sub"`
+			}
+			json.Unmarshal(payload, &claims)
+			gotSubject = claims.Sub
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if !delegated {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error":"unauthorized_client","error_description":"Client is unauthorized to retrieve access tokens using this method"}`)
+			return
+		}
+		fmt.Fprint(w, `{"access_token":"sa-access","token_type":"Bearer","expires_in":3600}`)
+	}))
+	defer server.Close()
+
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	key := newServiceAccountKey(t, server.URL)
+
+	if !isServiceAccount(key) {
+		t.Fatal("isServiceAccount() = false for a service account key")
+	}
+	if isServiceAccount([]byte(`{"installed":{"client_id":"x"}}`)) {
+		t.Error("isServiceAccount() = true for installed-app credentials")
+	}
+
+	t.Run("requires subject", func(t *testing.T) {
+		t.Setenv(config.SubjectEnv, "")
+		if _, err := newServiceAccountTokenSource(context.Background(), key); err == nil {
+			t.Error("newServiceAccountTokenSource() without subject should fail")
+		}
+	})
+
+	t.Run("impersonates subject", func(t *testing.T) {
+		t.Setenv(config.SubjectEnv, "support@example.com")
+		ts, err := newServiceAccountTokenSource(context.Background(), key)
+		if err != nil {
+			t.Fatalf("newServiceAccountTokenSource() error = %v", err)
+		}
+
+		token, err := ts.Token()
+		if err != nil {
+			t.Fatalf("Token() error = %v", err)
+		}
+		if token.AccessToken != "sa-access" {
+			t.Errorf("AccessToken = %q, want sa-access", token.AccessToken)
+		}
+		if gotSubject != "support@example.com" {
+			t.Errorf("subject = %q, want support@example.com", gotSubject)
+		}
+	})
+
+	t.Run("saved subject", func(t *testing.T) {
+		t.Setenv(config.SubjectEnv, "")
+		if err := config.SetServiceAccountSubject("saved@example.com"); err != nil {
+			t.Fatal(err)
+		}
+		ts, err := newServiceAccountTokenSource(context.Background(), key)
+		if err != nil {
+			t.Fatalf("newServiceAccountTokenSource() error = %v", err)
+		}
+		if _, err := ts.Token(); err != nil {
+			t.Fatalf("Token() error = %v", err)
+		}
+		if gotSubject != "saved@example.com" {
+			t.Errorf("subject = %q, want saved@example.com", gotSubject)
+		}
+	})
+
+	t.Run("delegation not granted", func(t *testing.T) {
+		delegated = false
+		defer func() { delegated = true }()
+
+		t.Setenv(config.SubjectEnv, "boss@example.com")
+		ts, err := newServiceAccountTokenSource(context.Background(), key)
+		if err != nil {
+			t.Fatalf("newServiceAccountTokenSource() error = %v", err)
+		}
+		_, err = ts.Token()
+		if err == nil || !strings.Contains(err.Error(), "domain-wide delegation") || !strings.Contains(err.Error(), "1234567890") {
+			t.Errorf("Token() error = %v, want delegation hint with client ID", err)
+		}
+	})
+}
@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+
+	"golang.org/x/oauth2"
+)
+
+// authSession holds the per-flow secrets for a single authorization:
+// the state parameter that ties the callback to this flow, and the PKCE
+// code verifier that ties the code exchange to this flow.
+type authSession struct {
+	state    string
+	verifier string
+}
+
+// newAuthSession creates a session with a random state and PKCE verifier.
+func newAuthSession() (*authSession, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, fmt.Errorf("failed to generate state: %w", err)
+	}
+
+	return &authSession{
+		state:    base64.RawURLEncoding.EncodeToString(b),
+		verifier: oauth2.GenerateVerifier(),
+	}, nil
+}
+
+// AuthCodeURL returns the authorization URL including the state and the S256 code challenge.
+func (a *authSession) AuthCodeURL(cfg *oauth2.Config) string {
+	return cfg.AuthCodeURL(a.state, oauth2.AccessTypeOffline, oauth2.S256ChallengeOption(a.verifier))
+}
+
+// Exchange exchanges an authorization code for a token, sending the code verifier.
+func (a *authSession) Exchange(ctx context.Context, cfg *oauth2.Config, code string) (*oauth2.Token, error) {
+	token, err := cfg.Exchange(ctx, code, oauth2.VerifierOption(a.verifier))
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange authorization code: %w", err)
+	}
+	return token, nil
+}
+
+// validState reports whether state matches the expected state in constant time.
+func validState(expected, state string) bool {
+	return expected != "" && subtle.ConstantTimeCompare([]byte(expected), []byte(state)) == 1
+}
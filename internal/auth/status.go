@@ -0,0 +1,220 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	// DefaultRevokeURL is Google's OAuth token revocation endpoint.
+	DefaultRevokeURL = "https://oauth2.googleapis.com/revoke"
+
+	// DefaultTokenInfoURL is Google's endpoint for inspecting an access token.
+	DefaultTokenInfoURL = "https://oauth2.googleapis.com/tokeninfo"
+)
+
+// ErrNoToken is returned when there is no stored token.
+var ErrNoToken = errors.New("not authenticated")
+
+// Status describes the stored credentials and whether they still work.
+type Status struct {
+	TokenLocation   string
+	Expiry          time.Time
+	HasRefreshToken bool
+	Scopes          []string
+	Email           string
+}
+
+// GetStatus loads the stored token, refreshing it if needed, and reports its
+// scopes and the authenticated account. It never starts the browser flow.
+func GetStatus(ctx context.Context, tokenInfoURL string, opts ServiceOptions) (*Status, error) {
+	credentials, err := loadCredentials()
+	if err != nil {
+		return nil, err
+	}
+
+	if isServiceAccount(credentials) {
+		tokenSource, err := newServiceAccountTokenSource(ctx, credentials)
+		if err != nil {
+			return nil, err
+		}
+		status := &Status{TokenLocation: tokenSource.Description()}
+		return status, checkStatus(ctx, status, tokenSource, tokenInfoURL, opts)
+	}
+
+	store, err := NewTokenStore()
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := loadStoredToken(store)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &Status{
+		TokenLocation:   store.Location(),
+		Expiry:          token.Expiry,
+		HasRefreshToken: token.RefreshToken != "",
+	}
+
+	oauthConfig, err := parseOAuthConfig(credentials)
+	if err != nil {
+		return status, err
+	}
+
+	tokenSource := NewPersistingTokenSource(ctx, oauthConfig, token, store)
+	return status, checkStatus(ctx, status, tokenSource, tokenInfoURL, opts)
+}
+
+// checkStatus fetches a token, its scopes and the profile, filling in status.
+func checkStatus(ctx context.Context, status *Status, tokenSource oauth2.TokenSource, tokenInfoURL string, opts ServiceOptions) error {
+	token, err := tokenSource.Token()
+	if err != nil {
+		if IsInvalidGrant(err) {
+			return fmt.Errorf("refresh token was revoked or expired, run 'gmail-cli auth': %w", err)
+		}
+		return fmt.Errorf("failed to refresh token: %w", err)
+	}
+	status.Expiry = token.Expiry
+
+	status.Scopes, err = fetchTokenScopes(ctx, tokenInfoURL, token.AccessToken)
+	if err != nil {
+		return err
+	}
+
+	service, err := newService(ctx, oauth2.NewClient(ctx, tokenSource), opts)
+	if err != nil {
+		return err
+	}
+
+	profile, err := service.Users.GetProfile("me").Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("failed to get profile: %w", err)
+	}
+	status.Email = profile.EmailAddress
+
+	return nil
+}
+
+// fetchTokenScopes returns the scopes granted to an access token.
+func fetchTokenScopes(ctx context.Context, tokenInfoURL, accessToken string) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		tokenInfoURL+"?access_token="+url.QueryEscape(accessToken), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up token info: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to look up token info: %s", resp.Status)
+	}
+
+	var info struct {
+		Scope string `json:"scope"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return nil, fmt.Errorf("failed to parse token info: %w", err)
+	}
+
+	return strings.Fields(info.Scope), nil
+}
+
+// Revoke revokes the stored token with Google and deletes it locally.
+// A token the server no longer recognizes is treated as already revoked.
+func Revoke(ctx context.Context, revokeURL string) error {
+	if credentials, err := loadCredentials(); err == nil && isServiceAccount(credentials) {
+		return fmt.Errorf("service account credentials have no stored token to revoke; disable the key in the Google Cloud console instead")
+	}
+
+	store, err := NewTokenStore()
+	if err != nil {
+		return err
+	}
+
+	token, err := loadStoredToken(store)
+	if err != nil {
+		return err
+	}
+
+	if err := revokeToken(ctx, revokeURL, token); err != nil {
+		return err
+	}
+
+	return store.Delete()
+}
+
+// loadStoredToken loads the token from store, returning ErrNoToken if there is none.
+func loadStoredToken(store TokenStore) (*oauth2.Token, error) {
+	token, err := store.Load()
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: no token in %s, run 'gmail-cli auth'", ErrNoToken, store.Location())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token from %s: %w", store.Location(), err)
+	}
+	return token, nil
+}
+
+// revokeToken revokes the refresh token, or the access token if there is no refresh token.
+// Revoking a refresh token also revokes its access tokens.
+func revokeToken(ctx context.Context, revokeURL string, token *oauth2.Token) error {
+	value := token.RefreshToken
+	if value == "" {
+		value = token.AccessToken
+	}
+
+	form := url.Values{"token": {value}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, revokeURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	var body struct {
+		Error string `json:"error"`
+	}
+	json.NewDecoder(resp.Body).Decode(&body)
+	if body.Error == "invalid_token" {
+		return nil
+	}
+
+	return fmt.Errorf("failed to revoke token: %s %s", resp.Status, body.Error)
+}
+
+// Logout deletes the stored token without contacting Google.
+func Logout() error {
+	store, err := NewTokenStore()
+	if err != nil {
+		return err
+	}
+
+	if err := store.Delete(); err != nil {
+		return fmt.Errorf("failed to delete token: %w", err)
+	}
+	return nil
+}
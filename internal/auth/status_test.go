@@ -0,0 +1,143 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/bentsolheim/gmail-cli/internal/config"
+	"golang.org/x/oauth2"
+)
+
+func TestRevoke(t *testing.T) {
+	tests := []struct {
+		name      string
+		token     *oauth2.Token
+		status    int
+		body      string
+		wantToken string
+		wantErr   bool
+		wantLocal bool
+	}{
+		{
+			name:      "revokes refresh token and deletes local token",
+			token:     &oauth2.Token{AccessToken: "access", RefreshToken: "refresh"},
+			status:    http.StatusOK,
+			wantToken: "refresh",
+		},
+		{
+			name:      "falls back to access token",
+			token:     &oauth2.Token{AccessToken: "access"},
+			status:    http.StatusOK,
+			wantToken: "access",
+		},
+		{
+			name:      "already revoked token is deleted",
+			token:     &oauth2.Token{AccessToken: "access", RefreshToken: "refresh"},
+			status:    http.StatusBadRequest,
+			body:      `{"error":"invalid_token"}`,
+			wantToken: "refresh",
+		},
+		{
+			name:      "server error keeps local token",
+			token:     &oauth2.Token{AccessToken: "access", RefreshToken: "refresh"},
+			status:    http.StatusInternalServerError,
+			wantToken: "refresh",
+			wantErr:   true,
+			wantLocal: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+			if err := config.EnsureConfigDir(); err != nil {
+				t.Fatal(err)
+			}
+			if err := SaveToken(config.TokenPath(), tt.token); err != nil {
+				t.Fatal(err)
+			}
+
+			var gotToken string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				r.ParseForm()
+				gotToken = r.PostForm.Get("token")
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			}))
+			defer server.Close()
+
+			err := Revoke(context.Background(), server.URL)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Revoke() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if gotToken != tt.wantToken {
+				t.Errorf("revoked token = %q, want %q", gotToken, tt.wantToken)
+			}
+
+			_, statErr := os.Stat(config.TokenPath())
+			if exists := statErr == nil; exists != tt.wantLocal {
+				t.Errorf("local token exists = %v, want %v", exists, tt.wantLocal)
+			}
+		})
+	}
+}
+
+func TestRevoke_NoToken(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	err := Revoke(context.Background(), "http://127.0.0.1:0")
+	if !errors.Is(err, ErrNoToken) {
+		t.Errorf("Revoke() error = %v, want ErrNoToken", err)
+	}
+}
+
+func TestFetchTokenScopes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("access_token") != "access" {
+			http.Error(w, `{"error":"invalid_token"}`, http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, `{"scope":"https://www.googleapis.com/auth/gmail.readonly openid","expires_in":"3599"}`)
+	}))
+	defer server.Close()
+
+	scopes, err := fetchTokenScopes(context.Background(), server.URL, "access")
+	if err != nil {
+		t.Fatalf("fetchTokenScopes() error = %v", err)
+	}
+	if got := strings.Join(scopes, ","); got != "https://www.googleapis.com/auth/gmail.readonly,openid" {
+		t.Errorf("scopes = %s", got)
+	}
+
+	if _, err := fetchTokenScopes(context.Background(), server.URL, "bad"); err == nil {
+		t.Error("fetchTokenScopes() with invalid token should fail")
+	}
+}
+
+func TestLogout(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	if err := config.EnsureConfigDir(); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveToken(config.TokenPath(), &oauth2.Token{AccessToken: "access"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Logout(); err != nil {
+		t.Fatalf("Logout() error = %v", err)
+	}
+	if _, err := os.Stat(config.TokenPath()); !os.IsNotExist(err) {
+		t.Error("token should be deleted")
+	}
+
+	// Logging out again is not an error
+	if err := Logout(); err != nil {
+		t.Errorf("second Logout() error = %v", err)
+	}
+}
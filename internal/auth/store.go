@@ -0,0 +1,272 @@
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/bentsolheim/gmail-cli/internal/config"
+	"github.com/zalando/go-keyring"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/oauth2"
+)
+
+// TokenStore persists the OAuth token between runs.
+// Load returns an error matching os.ErrNotExist when no token is stored.
+type TokenStore interface {
+	Load() (*oauth2.Token, error)
+	Save(token *oauth2.Token) error
+	Delete() error
+	// Location describes where the token is stored, for display.
+	Location() string
+}
+
+// NewTokenStore returns the token store for the active account, using the
+// backend configured in config.
+func NewTokenStore() (TokenStore, error) {
+	return NewAccountTokenStore(config.Account())
+}
+
+// NewAccountTokenStore returns the token store for an account, using the
+// backend configured in config.
+func NewAccountTokenStore(account string) (TokenStore, error) {
+	switch backend := config.TokenStoreBackend(); backend {
+	case config.TokenStoreFile:
+		return &FileTokenStore{Path: config.AccountTokenPath(account)}, nil
+	case config.TokenStoreEncrypted:
+		passphrase := os.Getenv(config.TokenPassphraseEnv)
+		if passphrase == "" {
+			return nil, fmt.Errorf("token store %q requires a passphrase in %s", backend, config.TokenPassphraseEnv)
+		}
+		return &EncryptedFileTokenStore{Path: config.AccountEncryptedTokenPath(account), Passphrase: passphrase}, nil
+	case config.TokenStoreKeyring:
+		return &KeyringTokenStore{Service: config.AppName(), User: keyringUser(account)}, nil
+	default:
+		return nil, fmt.Errorf("unknown token store %q (valid: %s, %s, %s)", backend,
+			config.TokenStoreFile, config.TokenStoreEncrypted, config.TokenStoreKeyring)
+	}
+}
+
+// FileTokenStore stores the token as plaintext JSON in a file.
+type FileTokenStore struct {
+	Path string
+}
+
+// Load reads the token from the file.
+func (s *FileTokenStore) Load() (*oauth2.Token, error) {
+	return LoadToken(s.Path)
+}
+
+// Save writes the token to the file.
+func (s *FileTokenStore) Save(token *oauth2.Token) error {
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0700); err != nil {
+		return err
+	}
+	return SaveToken(s.Path, token)
+}
+
+// Delete removes the file.
+func (s *FileTokenStore) Delete() error {
+	return removeFile(s.Path)
+}
+
+// Location returns the file path.
+func (s *FileTokenStore) Location() string {
+	return s.Path
+}
+
+// scrypt parameters for deriving the token encryption key
+const (
+	scryptN      = 1 << 15
+	scryptR      = 8
+	scryptP      = 1
+	scryptKeyLen = 32
+)
+
+// encryptedToken is the on-disk format of an EncryptedFileTokenStore.
+type encryptedToken struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	N          int    `json:"n"`
+	R          int    `json:"r"`
+	P          int    `json:"p"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// EncryptedFileTokenStore stores the token in a file encrypted with
+// AES-256-GCM, using a key derived from a passphrase with scrypt.
+type EncryptedFileTokenStore struct {
+	Path       string
+	Passphrase string
+}
+
+// Load reads and decrypts the token.
+func (s *EncryptedFileTokenStore) Load() (*oauth2.Token, error) {
+	b, err := os.ReadFile(s.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	var enc encryptedToken
+	if err := json.Unmarshal(b, &enc); err != nil {
+		return nil, fmt.Errorf("failed to parse encrypted token: %w", err)
+	}
+	if enc.Version != 1 || enc.KDF != "scrypt" {
+		return nil, fmt.Errorf("unsupported encrypted token format (version %d, kdf %q)", enc.Version, enc.KDF)
+	}
+
+	gcm, err := newTokenCipher(s.Passphrase, enc.Salt, enc.N, enc.R, enc.P)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := gcm.Open(nil, enc.Nonce, enc.Ciphertext, nil)
+	if err != nil {
+		return nil, errors.New("failed to decrypt token: wrong passphrase or corrupted file")
+	}
+
+	token := &oauth2.Token{}
+	if err := json.Unmarshal(plaintext, token); err != nil {
+		return nil, fmt.Errorf("failed to parse decrypted token: %w", err)
+	}
+	return token, nil
+}
+
+// Save encrypts and writes the token with a fresh salt and nonce.
+func (s *EncryptedFileTokenStore) Save(token *oauth2.Token) error {
+	plaintext, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+
+	enc := encryptedToken{
+		Version: 1,
+		KDF:     "scrypt",
+		N:       scryptN,
+		R:       scryptR,
+		P:       scryptP,
+		Salt:    make([]byte, 16),
+	}
+	if _, err := rand.Read(enc.Salt); err != nil {
+		return err
+	}
+
+	gcm, err := newTokenCipher(s.Passphrase, enc.Salt, enc.N, enc.R, enc.P)
+	if err != nil {
+		return err
+	}
+
+	enc.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(enc.Nonce); err != nil {
+		return err
+	}
+	enc.Ciphertext = gcm.Seal(nil, enc.Nonce, plaintext, nil)
+
+	b, err := json.Marshal(enc)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0700); err != nil {
+		return err
+	}
+	return writeFileAtomic(s.Path, b)
+}
+
+// Delete removes the encrypted file.
+func (s *EncryptedFileTokenStore) Delete() error {
+	return removeFile(s.Path)
+}
+
+// Location returns the file path.
+func (s *EncryptedFileTokenStore) Location() string {
+	return s.Path + " (encrypted)"
+}
+
+// newTokenCipher derives a key from the passphrase and returns an AES-GCM cipher.
+func newTokenCipher(passphrase string, salt []byte, n, r, p int) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, n, r, p, scryptKeyLen)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// KeyringTokenStore stores the token in the OS keyring: Secret Service on
+// Linux, Keychain on macOS and Credential Manager on Windows.
+type KeyringTokenStore struct {
+	Service string
+	User    string
+}
+
+// Load reads the token from the keyring.
+func (s *KeyringTokenStore) Load() (*oauth2.Token, error) {
+	secret, err := keyring.Get(s.Service, s.User)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return nil, fmt.Errorf("no token in keyring: %w", os.ErrNotExist)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token from keyring: %w", err)
+	}
+
+	token := &oauth2.Token{}
+	if err := json.Unmarshal([]byte(secret), token); err != nil {
+		return nil, fmt.Errorf("failed to parse token from keyring: %w", err)
+	}
+	return token, nil
+}
+
+// Save writes the token to the keyring.
+func (s *KeyringTokenStore) Save(token *oauth2.Token) error {
+	b, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+	if err := keyring.Set(s.Service, s.User, string(b)); err != nil {
+		return fmt.Errorf("failed to save token to keyring: %w", err)
+	}
+	return nil
+}
+
+// Delete removes the token from the keyring.
+func (s *KeyringTokenStore) Delete() error {
+	err := keyring.Delete(s.Service, s.User)
+	if err != nil && !errors.Is(err, keyring.ErrNotFound) {
+		return fmt.Errorf("failed to delete token from keyring: %w", err)
+	}
+	return nil
+}
+
+// Location describes the keyring entry.
+func (s *KeyringTokenStore) Location() string {
+	return fmt.Sprintf("keyring (service %q, user %q)", s.Service, s.User)
+}
+
+// keyringUser returns the keyring entry name for an account.
+func keyringUser(account string) string {
+	if account == "" || account == config.DefaultAccount {
+		return "token"
+	}
+	return "token:" + account
+}
+
+// removeFile deletes a file, ignoring a file that doesn't exist.
+func removeFile(path string) error {
+	err := os.Remove(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
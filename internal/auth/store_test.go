@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bentsolheim/gmail-cli/internal/config"
+	"github.com/zalando/go-keyring"
+	"golang.org/x/oauth2"
+)
+
+func TestTokenStores_RoundTrip(t *testing.T) {
+	keyring.MockInit()
+	dir := t.TempDir()
+
+	stores := map[string]TokenStore{
+		"file":      &FileTokenStore{Path: filepath.Join(dir, "token.json")},
+		"encrypted": &EncryptedFileTokenStore{Path: filepath.Join(dir, "token.json.enc"), Passphrase: "correct horse"},
+		"keyring":   &KeyringTokenStore{Service: "gmail-cli-test", User: "token"},
+	}
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			if _, err := store.Load(); !errors.Is(err, os.ErrNotExist) {
+				t.Fatalf("Load() on empty store error = %v, want os.ErrNotExist", err)
+			}
+
+			want := &oauth2.Token{AccessToken: "access", RefreshToken: "refresh-secret", TokenType: "Bearer"}
+			if err := store.Save(want); err != nil {
+				t.Fatalf("Save() error = %v", err)
+			}
+
+			got, err := store.Load()
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if got.AccessToken != want.AccessToken || got.RefreshToken != want.RefreshToken {
+				t.Errorf("Load() = %+v, want %+v", got, want)
+			}
+
+			if err := store.Delete(); err != nil {
+				t.Fatalf("Delete() error = %v", err)
+			}
+			if _, err := store.Load(); !errors.Is(err, os.ErrNotExist) {
+				t.Errorf("Load() after Delete() error = %v, want os.ErrNotExist", err)
+			}
+			if err := store.Delete(); err != nil {
+				t.Errorf("Delete() of missing token error = %v", err)
+			}
+		})
+	}
+}
+
+func TestEncryptedFileTokenStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token.json.enc")
+	store := &EncryptedFileTokenStore{Path: path, Passphrase: "correct horse"}
+
+	if err := store.Save(&oauth2.Token{AccessToken: "access", RefreshToken: "refresh-secret"}); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(b, []byte("refresh-secret")) {
+		t.Error("encrypted file contains the plaintext refresh token")
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("permissions = %o, want 600", perm)
+	}
+
+	wrong := &EncryptedFileTokenStore{Path: path, Passphrase: "wrong"}
+	if _, err := wrong.Load(); err == nil {
+		t.Error("Load() with wrong passphrase should fail")
+	}
+}
+
+func TestNewTokenStore(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	tests := []struct {
+		backend    string
+		passphrase string
+		wantType   TokenStore
+		wantErr    bool
+	}{
+		{backend: "", wantType: &FileTokenStore{}},
+		{backend: config.TokenStoreFile, wantType: &FileTokenStore{}},
+		{backend: config.TokenStoreEncrypted, passphrase: "secret", wantType: &EncryptedFileTokenStore{}},
+		{backend: config.TokenStoreEncrypted, wantErr: true},
+		{backend: config.TokenStoreKeyring, wantType: &KeyringTokenStore{}},
+		{backend: "vault", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Setenv(config.TokenStoreEnv, tt.backend)
+		t.Setenv(config.TokenPassphraseEnv, tt.passphrase)
+
+		store, err := NewTokenStore()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("NewTokenStore(%q) error = %v, wantErr %v", tt.backend, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && fmt.Sprintf("%T", store) != fmt.Sprintf("%T", tt.wantType) {
+			t.Errorf("NewTokenStore(%q) = %T, want %T", tt.backend, store, tt.wantType)
+		}
+	}
+}
@@ -1,8 +1,13 @@
 package auth
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
+	"sync"
 
 	"golang.org/x/oauth2"
 )
@@ -22,11 +27,89 @@ func LoadToken(path string) (*oauth2.Token, error) {
 
 // SaveToken saves a token to a file path.
 func SaveToken(path string, token *oauth2.Token) error {
-	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	b, err := json.Marshal(token)
 	if err != nil {
 		return err
 	}
-	defer f.Close()
+	return writeFileAtomic(path, b)
+}
+
+// writeFileAtomic writes data to a temporary file with mode 0600 and renames
+// it into place, so a crash never leaves a truncated file behind.
+func writeFileAtomic(path string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	tmpPath := f.Name()
+	defer os.Remove(tmpPath)
+
+	if err := f.Chmod(0600); err != nil {
+		f.Close()
+		return err
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, path)
+}
+
+// PersistingTokenSource is an oauth2.TokenSource that refreshes expired
+// tokens and saves every new token to a TokenStore, including rotated refresh tokens.
+type PersistingTokenSource struct {
+	source oauth2.TokenSource
+	store  TokenStore
+
+	mu   sync.Mutex
+	last *oauth2.Token
+}
+
+// NewPersistingTokenSource returns a token source that starts from token,
+// refreshes it using cfg, and saves refreshed tokens to store.
+func NewPersistingTokenSource(ctx context.Context, cfg *oauth2.Config, token *oauth2.Token, store TokenStore) *PersistingTokenSource {
+	return &PersistingTokenSource{
+		source: cfg.TokenSource(ctx, token),
+		store:  store,
+		last:   token,
+	}
+}
+
+// Token returns a valid token, refreshing and saving it if needed.
+func (s *PersistingTokenSource) Token() (*oauth2.Token, error) {
+	token, err := s.source.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.last == nil || token.AccessToken != s.last.AccessToken || token.RefreshToken != s.last.RefreshToken {
+		// A failed save should not fail the request that triggered the refresh
+		if err := s.store.Save(token); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to save refreshed token: %v\n", err)
+		}
+		s.last = token
+	}
+
+	return token, nil
+}
 
-	return json.NewEncoder(f).Encode(token)
+// IsInvalidGrant reports whether err is an OAuth invalid_grant error, which
+// means the refresh token was revoked or has expired.
+func IsInvalidGrant(err error) bool {
+	var retrieveErr *oauth2.RetrieveError
+	return errors.As(err, &retrieveErr) && retrieveErr.ErrorCode == "invalid_grant"
 }
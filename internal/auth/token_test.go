@@ -0,0 +1,167 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// newTokenServer returns a fake OAuth token endpoint that hands out numbered
+// access tokens, or fails with the given error code.
+func newTokenServer(t *testing.T, errorCode string, rotateRefresh bool) (*oauth2.Config, *atomic.Int32) {
+	t.Helper()
+
+	var refreshes atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if errorCode != "" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"error":%q}`, errorCode)
+			return
+		}
+
+		n := refreshes.Add(1)
+		if rotateRefresh {
+			fmt.Fprintf(w, `{"access_token":"access-%d","refresh_token":"refresh-%d","token_type":"Bearer","expires_in":3600}`, n, n)
+			return
+		}
+		fmt.Fprintf(w, `{"access_token":"access-%d","token_type":"Bearer","expires_in":3600}`, n)
+	}))
+	t.Cleanup(server.Close)
+
+	return &oauth2.Config{
+		ClientID: "client",
+		Endpoint: oauth2.Endpoint{TokenURL: server.URL},
+	}, &refreshes
+}
+
+func expiredToken() *oauth2.Token {
+	return &oauth2.Token{
+		AccessToken:  "expired",
+		RefreshToken: "refresh-0",
+		TokenType:    "Bearer",
+		Expiry:       time.Now().Add(-time.Hour),
+	}
+}
+
+func TestPersistingTokenSource_SavesRefreshedToken(t *testing.T) {
+	cfg, refreshes := newTokenServer(t, "", false)
+	path := filepath.Join(t.TempDir(), "token.json")
+
+	ts := NewPersistingTokenSource(context.Background(), cfg, expiredToken(), &FileTokenStore{Path: path})
+
+	token, err := ts.Token()
+	if err != nil {
+		t.Fatalf("Token() error = %v", err)
+	}
+	if token.AccessToken != "access-1" {
+		t.Errorf("AccessToken = %q, want access-1", token.AccessToken)
+	}
+
+	saved, err := LoadToken(path)
+	if err != nil {
+		t.Fatalf("LoadToken() error = %v", err)
+	}
+	if saved.AccessToken != "access-1" {
+		t.Errorf("saved AccessToken = %q, want access-1", saved.AccessToken)
+	}
+	// The refresh token is kept when the server doesn't rotate it
+	if saved.RefreshToken != "refresh-0" {
+		t.Errorf("saved RefreshToken = %q, want refresh-0", saved.RefreshToken)
+	}
+
+	// A still-valid token is reused without another refresh or save
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ts.Token(); err != nil {
+		t.Fatalf("Token() error = %v", err)
+	}
+	if got := refreshes.Load(); got != 1 {
+		t.Errorf("refreshes = %d, want 1", got)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("token should not be saved again when unchanged")
+	}
+}
+
+func TestPersistingTokenSource_SavesRotatedRefreshToken(t *testing.T) {
+	cfg, _ := newTokenServer(t, "", true)
+	path := filepath.Join(t.TempDir(), "token.json")
+
+	ts := NewPersistingTokenSource(context.Background(), cfg, expiredToken(), &FileTokenStore{Path: path})
+	if _, err := ts.Token(); err != nil {
+		t.Fatalf("Token() error = %v", err)
+	}
+
+	saved, err := LoadToken(path)
+	if err != nil {
+		t.Fatalf("LoadToken() error = %v", err)
+	}
+	if saved.RefreshToken != "refresh-1" {
+		t.Errorf("saved RefreshToken = %q, want refresh-1", saved.RefreshToken)
+	}
+}
+
+func TestPersistingTokenSource_InvalidGrant(t *testing.T) {
+	cfg, _ := newTokenServer(t, "invalid_grant", false)
+	path := filepath.Join(t.TempDir(), "token.json")
+
+	ts := NewPersistingTokenSource(context.Background(), cfg, expiredToken(), &FileTokenStore{Path: path})
+	_, err := ts.Token()
+	if !IsInvalidGrant(err) {
+		t.Errorf("IsInvalidGrant(%v) = false, want true", err)
+	}
+
+	cfg, _ = newTokenServer(t, "invalid_client", false)
+	ts = NewPersistingTokenSource(context.Background(), cfg, expiredToken(), &FileTokenStore{Path: path})
+	_, err = ts.Token()
+	if err == nil || IsInvalidGrant(err) {
+		t.Errorf("IsInvalidGrant(%v) = true, want false", err)
+	}
+}
+
+func TestSaveToken_ReplacesExistingFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "token.json")
+
+	if err := os.WriteFile(path, []byte(`{"access_token":"old-token-with-a-long-value"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SaveToken(path, &oauth2.Token{AccessToken: "new"}); err != nil {
+		t.Fatalf("SaveToken() error = %v", err)
+	}
+
+	saved, err := LoadToken(path)
+	if err != nil {
+		t.Fatalf("LoadToken() error = %v", err)
+	}
+	if saved.AccessToken != "new" {
+		t.Errorf("AccessToken = %q, want new", saved.AccessToken)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("permissions = %o, want 600", perm)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected only token.json in dir, found %d entries", len(entries))
+	}
+}
@@ -0,0 +1,22 @@
+// Package backend defines the mail sources commands read from, and
+// implements the ones that don't need the Gmail API.
+package backend
+
+import (
+	"context"
+
+	"github.com/bentsolheim/gmail-cli/internal/gmail"
+)
+
+// Backend is a source of threads. *gmail.Client is the default; others read
+// local archives or mirrors.
+type Backend interface {
+	// SearchThreads returns summaries of the threads matching query.
+	SearchThreads(ctx context.Context, query string, opts gmail.SearchOptions) (*gmail.SearchResult, error)
+	// GetThread returns a thread with its messages, oldest first.
+	GetThread(ctx context.Context, threadID string) (*gmail.Thread, error)
+	// DownloadAttachment returns the content of a message's attachment.
+	DownloadAttachment(ctx context.Context, messageID, attachmentID string) ([]byte, error)
+}
+
+var _ Backend = (*gmail.Client)(nil)
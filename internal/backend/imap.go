@@ -0,0 +1,569 @@
+package backend
+
+import (
+	"cmp"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/bentsolheim/gmail-cli/internal/gmail"
+	"github.com/bentsolheim/gmail-cli/internal/imap"
+	"github.com/bentsolheim/gmail-cli/internal/message"
+	"github.com/bentsolheim/gmail-cli/internal/query"
+)
+
+// ErrNoCredentials is returned by OpenIMAP when it has no password or token.
+var ErrNoCredentials = errors.New("no IMAP password or token given")
+
+// IMAPOptions configures OpenIMAP.
+type IMAPOptions struct {
+	// URL is imaps://user@host[:port][/mailbox] to connect with TLS, or
+	// imap://user@host[:port][/mailbox] to upgrade a plain connection with
+	// STARTTLS. The mailbox defaults to All Mail on Gmail and INBOX
+	// elsewhere.
+	URL      string
+	Password string
+	// Token is an OAuth 2 access token, used with XOAUTH2 instead of
+	// Password.
+	Token string
+	// TLSConfig overrides the TLS configuration, e.g. to trust a test
+	// server.
+	TLSConfig *tls.Config
+}
+
+// IMAP reads threads from a mailbox on an IMAP server. The mailbox is opened
+// read-only, so reading doesn't mark messages as read.
+//
+// On Gmail, searches use Gmail's own search (X-GM-RAW) and threads and IDs
+// are Gmail's (X-GM-THRID and X-GM-MSGID), the same as with the API. On
+// other servers, messages are grouped into threads by their References and
+// In-Reply-To headers, IDs are derived from Message-ID headers as for
+// archives, and queries are evaluated locally after a server-side search
+// narrows down the candidates.
+type IMAP struct {
+	client  *imap.Client
+	gmail   bool
+	mailbox string
+	// threads are the mailbox's threads on servers without Gmail's
+	// extensions, read on first use.
+	threads *imapThreads
+}
+
+var _ Backend = (*IMAP)(nil)
+
+// OpenIMAP connects and logs in to an IMAP server and opens the mailbox.
+func OpenIMAP(ctx context.Context, opts IMAPOptions) (*IMAP, error) {
+	u, err := url.Parse(opts.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid IMAP URL: %w", err)
+	}
+
+	dial := imap.DialOptions{TLSConfig: opts.TLSConfig}
+	port := "993"
+	switch u.Scheme {
+	case "imaps":
+	case "imap":
+		dial.StartTLS = true
+		port = "143"
+	default:
+		return nil, fmt.Errorf("invalid IMAP URL %q: must start with imaps:// or imap://", opts.URL)
+	}
+	if u.Hostname() == "" || u.User == nil || u.User.Username() == "" {
+		return nil, fmt.Errorf("invalid IMAP URL %q: must include a user and host, e.g. imaps://me@imap.example.com", opts.URL)
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), port)
+	}
+
+	username := u.User.Username()
+	password := opts.Password
+	if p, ok := u.User.Password(); ok && password == "" {
+		password = p
+	}
+	if opts.Token == "" && password == "" {
+		return nil, ErrNoCredentials
+	}
+
+	client, err := imap.Dial(ctx, addr, dial)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Token != "" {
+		err = client.AuthenticateXOAuth2(ctx, username, opts.Token)
+	} else {
+		err = client.Login(ctx, username, password)
+	}
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("IMAP login failed: %w", err)
+	}
+
+	b := &IMAP{
+		client:  client,
+		gmail:   client.Has("X-GM-EXT-1"),
+		mailbox: strings.TrimPrefix(u.Path, "/"),
+	}
+	if b.mailbox == "" {
+		b.mailbox, err = b.defaultMailbox(ctx)
+		if err != nil {
+			client.Close()
+			return nil, err
+		}
+	}
+	if err := client.Examine(ctx, b.mailbox); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to open mailbox %s: %w", b.mailbox, err)
+	}
+	return b, nil
+}
+
+// defaultMailbox returns All Mail on Gmail, found by its \All attribute as
+// its name depends on the language, and INBOX elsewhere.
+func (b *IMAP) defaultMailbox(ctx context.Context) (string, error) {
+	if !b.gmail {
+		return "INBOX", nil
+	}
+	mailboxes, err := b.client.List(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to list mailboxes: %w", err)
+	}
+	for _, mb := range mailboxes {
+		if mb.HasAttribute(`\All`) {
+			return mb.Name, nil
+		}
+	}
+	return "[Gmail]/All Mail", nil
+}
+
+// Close logs out.
+func (b *IMAP) Close() error {
+	return b.client.Logout(context.Background())
+}
+
+// SearchThreads returns the threads with a message matching the query,
+// newest first. Options other than Limit are ignored.
+func (b *IMAP) SearchThreads(ctx context.Context, queryText string, opts gmail.SearchOptions) (*gmail.SearchResult, error) {
+	if opts.PageToken != "" {
+		return nil, ErrPageToken
+	}
+
+	var threads []*gmail.Thread
+	var err error
+	if b.gmail {
+		threads, err = b.searchGmail(ctx, queryText, opts.Limit)
+	} else {
+		threads, err = b.search(ctx, queryText, opts.Limit)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := &gmail.SearchResult{Threads: []gmail.ThreadSummary{}}
+	for _, thread := range threads {
+		result.Threads = append(result.Threads, thread.Summary())
+	}
+	return result, nil
+}
+
+// GetThread returns a thread by ID.
+func (b *IMAP) GetThread(ctx context.Context, threadID string) (*gmail.Thread, error) {
+	if b.gmail {
+		id, err := strconv.ParseUint(threadID, 16, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid thread ID %q", threadID)
+		}
+		return b.gmailThread(ctx, id)
+	}
+
+	threads, err := b.loadThreads(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, group := range threads.groups {
+		if group[0].id == threadID {
+			return b.fetchThread(ctx, group)
+		}
+	}
+	return nil, fmt.Errorf("thread %s not found in %s", threadID, b.mailbox)
+}
+
+// DownloadAttachment returns the decoded content of an attachment.
+func (b *IMAP) DownloadAttachment(ctx context.Context, messageID, attachmentID string) ([]byte, error) {
+	var uid uint32
+	if b.gmail {
+		id, err := strconv.ParseUint(messageID, 16, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid message ID %q", messageID)
+		}
+		uids, err := b.client.Search(ctx, "X-GM-MSGID", strconv.FormatUint(id, 10))
+		if err != nil {
+			return nil, err
+		}
+		if len(uids) > 0 {
+			uid = uids[0]
+		}
+	} else {
+		threads, err := b.loadThreads(ctx)
+		if err != nil {
+			return nil, err
+		}
+		uid = threads.uids[messageID]
+	}
+	if uid == 0 {
+		return nil, fmt.Errorf("message %s not found in %s", messageID, b.mailbox)
+	}
+
+	fetched, err := b.client.Fetch(ctx, []uint32{uid}, "BODY.PEEK[]")
+	if err != nil {
+		return nil, err
+	}
+	if len(fetched) == 0 {
+		return nil, fmt.Errorf("message %s not found in %s", messageID, b.mailbox)
+	}
+	msg, err := message.Parse(fetched[0].Body)
+	if err != nil {
+		return nil, err
+	}
+	att, ok := msg.Attachment(attachmentID)
+	if !ok {
+		return nil, fmt.Errorf("attachment %s not found in message %s", attachmentID, messageID)
+	}
+	return att.Data, nil
+}
+
+// searchGmail searches with Gmail's search syntax on the server, then
+// fetches the matching threads, most recently active first.
+func (b *IMAP) searchGmail(ctx context.Context, queryText string, limit int64) ([]*gmail.Thread, error) {
+	criteria := []any{"ALL"}
+	if strings.TrimSpace(queryText) != "" {
+		criteria = []any{"X-GM-RAW", imap.Quoted(queryText)}
+	}
+	uids, err := b.client.Search(ctx, criteria...)
+	if err != nil {
+		return nil, fmt.Errorf("search failed: %w", err)
+	}
+	if len(uids) == 0 {
+		return nil, nil
+	}
+
+	fetched, err := b.client.Fetch(ctx, uids, "X-GM-THRID", "INTERNALDATE")
+	if err != nil {
+		return nil, err
+	}
+	latest := make(map[uint64]time.Time)
+	for _, m := range fetched {
+		if m.InternalDate.After(latest[m.ThreadID]) {
+			latest[m.ThreadID] = m.InternalDate
+		}
+	}
+	ids := make([]uint64, 0, len(latest))
+	for id := range latest {
+		ids = append(ids, id)
+	}
+	slices.SortFunc(ids, func(a, b uint64) int {
+		if c := latest[b].Compare(latest[a]); c != 0 {
+			return c
+		}
+		return cmp.Compare(a, b)
+	})
+	if limit > 0 && int64(len(ids)) > limit {
+		ids = ids[:limit]
+	}
+
+	threads := make([]*gmail.Thread, 0, len(ids))
+	for _, id := range ids {
+		thread, err := b.gmailThread(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		threads = append(threads, thread)
+	}
+	return threads, nil
+}
+
+// gmailThread fetches every message of a Gmail thread.
+func (b *IMAP) gmailThread(ctx context.Context, threadID uint64) (*gmail.Thread, error) {
+	uids, err := b.client.Search(ctx, "X-GM-THRID", strconv.FormatUint(threadID, 10))
+	if err != nil {
+		return nil, err
+	}
+	if len(uids) == 0 {
+		return nil, fmt.Errorf("thread %x not found", threadID)
+	}
+
+	fetched, err := b.client.Fetch(ctx, uids, "X-GM-MSGID", "X-GM-LABELS", "FLAGS", "INTERNALDATE", "RFC822.SIZE", "BODY.PEEK[]")
+	if err != nil {
+		return nil, err
+	}
+	messages := b.parseMessages(fetched, func(m *imap.Message) string {
+		return strconv.FormatUint(m.MessageID, 16)
+	})
+	if len(messages) == 0 {
+		return nil, fmt.Errorf("thread %x has no readable messages", threadID)
+	}
+	sortByDate(messages)
+
+	thread := buildThread(messages)
+	thread.ID = strconv.FormatUint(threadID, 16)
+	return thread, nil
+}
+
+// search evaluates a query locally over the threads of the candidate
+// messages found by a server-side search, most recently active first.
+func (b *IMAP) search(ctx context.Context, queryText string, limit int64) ([]*gmail.Thread, error) {
+	q, err := query.Parse(queryText)
+	if err != nil {
+		return nil, err
+	}
+	threads, err := b.loadThreads(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	uids, err := b.client.Search(ctx, searchCriteria(q)...)
+	if err != nil {
+		return nil, fmt.Errorf("search failed: %w", err)
+	}
+	seen := make(map[int]bool)
+	var candidates [][]*localMessage
+	for _, uid := range uids {
+		if i, ok := threads.group[uid]; ok && !seen[i] {
+			seen[i] = true
+			candidates = append(candidates, threads.groups[i])
+		}
+	}
+	slices.SortFunc(candidates, func(a, b []*localMessage) int {
+		if c := b[len(b)-1].msg.Date.Compare(a[len(a)-1].msg.Date); c != 0 {
+			return c
+		}
+		return cmp.Compare(a[0].id, b[0].id)
+	})
+
+	var matched []*gmail.Thread
+	for _, group := range candidates {
+		if limit > 0 && int64(len(matched)) >= limit {
+			break
+		}
+		thread, err := b.fetchThread(ctx, group)
+		if err != nil {
+			return nil, err
+		}
+		if q.Match(thread) {
+			matched = append(matched, thread)
+		}
+	}
+	return matched, nil
+}
+
+// searchCriteria returns IMAP search keys matching a superset of what the
+// query matches, to narrow down the messages to evaluate it on. Only
+// single words and flags that every match must have are sent, as IMAP
+// matches substrings where the query matches words.
+func searchCriteria(q *query.Query) []any {
+	nodes := []query.Node{q.Root}
+	if and, ok := q.Root.(*query.And); ok {
+		nodes = and.Children
+	}
+
+	var criteria []any
+	for _, node := range nodes {
+		t, ok := node.(*query.Term)
+		if !ok {
+			continue
+		}
+		value := imap.Quoted(t.Value)
+		switch t.Field {
+		case "", "from", "to", "cc", "bcc", "subject":
+			if !isWord(t.Value) {
+				continue
+			}
+			switch t.Field {
+			case "":
+				criteria = append(criteria, "TEXT", value)
+			case "to":
+				criteria = append(criteria, "OR", "TO", value, "OR", "CC", value, "BCC", value)
+			default:
+				criteria = append(criteria, strings.ToUpper(t.Field), value)
+			}
+		case "is":
+			switch strings.ToLower(t.Value) {
+			case "unread":
+				criteria = append(criteria, "UNSEEN")
+			case "read":
+				criteria = append(criteria, "SEEN")
+			case "starred":
+				criteria = append(criteria, "FLAGGED")
+			}
+		}
+	}
+	if len(criteria) == 0 {
+		return []any{"ALL"}
+	}
+	return criteria
+}
+
+// isWord reports whether s is a single word of letters and digits.
+func isWord(s string) bool {
+	return s != "" && !strings.ContainsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// imapThreads is the threading of a mailbox.
+type imapThreads struct {
+	// groups are the threads' messages, oldest first, with headers only.
+	groups [][]*localMessage
+	// group maps UIDs to their index in groups.
+	group map[uint32]int
+	// uids maps message IDs to UIDs.
+	uids map[string]uint32
+}
+
+// threadHeaders are the header fields fetched to group messages into
+// threads.
+const threadHeaders = "BODY.PEEK[HEADER.FIELDS (MESSAGE-ID IN-REPLY-TO REFERENCES DATE)]"
+
+// loadThreads groups the messages in the mailbox into threads by their
+// headers, fetching them the first time it is called.
+func (b *IMAP) loadThreads(ctx context.Context) (*imapThreads, error) {
+	if b.threads != nil {
+		return b.threads, nil
+	}
+
+	fetched, err := b.client.Fetch(ctx, nil, "INTERNALDATE", threadHeaders)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", b.mailbox, err)
+	}
+	messages := b.parseMessages(fetched, nil)
+
+	threads := &imapThreads{
+		group: make(map[uint32]int),
+		uids:  make(map[string]uint32),
+	}
+	for i, group := range groupThreads(messages) {
+		threads.groups = append(threads.groups, group)
+		for _, m := range group {
+			threads.group[m.uid] = i
+			threads.uids[m.id] = m.uid
+		}
+	}
+	b.threads = threads
+	return threads, nil
+}
+
+// fetchThread fetches the full messages of a thread found by loadThreads.
+func (b *IMAP) fetchThread(ctx context.Context, group []*localMessage) (*gmail.Thread, error) {
+	ids := make(map[uint32]string, len(group))
+	uids := make([]uint32, 0, len(group))
+	for _, m := range group {
+		ids[m.uid] = m.id
+		uids = append(uids, m.uid)
+	}
+	slices.Sort(uids)
+
+	fetched, err := b.client.Fetch(ctx, uids, "FLAGS", "INTERNALDATE", "RFC822.SIZE", "BODY.PEEK[]")
+	if err != nil {
+		return nil, err
+	}
+	messages := b.parseMessages(fetched, func(m *imap.Message) string { return ids[m.UID] })
+	if len(messages) == 0 {
+		return nil, fmt.Errorf("thread %s has no readable messages", group[0].id)
+	}
+	sortByDate(messages)
+
+	thread := buildThread(messages)
+	thread.ID = group[0].id
+	return thread, nil
+}
+
+// parseMessages parses fetched messages, skipping duplicates and messages
+// that can't be parsed with a warning on stderr. id returns a message's ID;
+// nil derives it from the Message-ID header, or the UID if there is none.
+// Messages without a valid Date header are dated by when they arrived.
+func (b *IMAP) parseMessages(fetched []*imap.Message, id func(*imap.Message) string) []*localMessage {
+	var messages []*localMessage
+	seen := make(map[string]bool)
+	for _, m := range fetched {
+		msg, err := message.Parse(m.Body)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: skipping message with UID %d in %s: %v\n", m.UID, b.mailbox, err)
+			continue
+		}
+		if msg.Date.IsZero() {
+			msg.Date = m.InternalDate
+		}
+
+		var msgID string
+		if id != nil {
+			msgID = id(m)
+		} else {
+			msgID = localID(msg, []byte("uid:"+strconv.FormatUint(uint64(m.UID), 10)))
+		}
+		if seen[msgID] {
+			continue
+		}
+		seen[msgID] = true
+
+		messages = append(messages, &localMessage{
+			id:     msgID,
+			msg:    msg,
+			size:   m.Size,
+			labels: b.labels(m),
+			uid:    m.UID,
+		})
+	}
+	return messages
+}
+
+// gmailSystemLabels maps Gmail's IMAP system labels to their API names.
+var gmailSystemLabels = map[string]string{
+	`\Inbox`:     "INBOX",
+	`\Sent`:      "SENT",
+	`\Important`: "IMPORTANT",
+	`\Starred`:   "STARRED",
+	`\Draft`:     "DRAFT",
+	`\Trash`:     "TRASH",
+	`\Spam`:      "SPAM",
+}
+
+// labels returns a message's labels as the Gmail API names them: Gmail's
+// labels, or else the mailbox, and UNREAD, STARRED and DRAFT from flags.
+func (b *IMAP) labels(m *imap.Message) []string {
+	var labels []string
+	if b.gmail {
+		for _, label := range m.Labels {
+			if name, ok := gmailSystemLabels[label]; ok {
+				label = name
+			} else if strings.HasPrefix(label, `\`) {
+				label = strings.ToUpper(label[1:])
+			}
+			labels = append(labels, label)
+		}
+	} else if strings.EqualFold(b.mailbox, "INBOX") {
+		labels = append(labels, "INBOX")
+	} else {
+		labels = append(labels, b.mailbox)
+	}
+
+	if !m.HasFlag(`\Seen`) {
+		labels = append(labels, "UNREAD")
+	}
+	if m.HasFlag(`\Flagged`) {
+		labels = append(labels, "STARRED")
+	}
+	if m.HasFlag(`\Draft`) {
+		labels = append(labels, "DRAFT")
+	}
+	slices.Sort(labels)
+	return slices.Compact(labels)
+}
@@ -0,0 +1,244 @@
+package backend
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/bentsolheim/gmail-cli/internal/gmail"
+	"github.com/bentsolheim/gmail-cli/internal/imap/imaptest"
+	"github.com/bentsolheim/gmail-cli/internal/message"
+)
+
+// newIMAPServer serves the messages of testdata/archive.mbox: three in the
+// conversion thread, with an attachment, and an unread lunch message. On a
+// Gmail server they are in All Mail, with Gmail thread and message IDs.
+func newIMAPServer(t *testing.T, isGmail bool) *imaptest.Server {
+	t.Helper()
+	raw, err := readMboxFile(filepath.Join("testdata", "archive.mbox"))
+	if err != nil {
+		t.Fatalf("readMboxFile() error = %v", err)
+	}
+
+	mailbox := &imaptest.Mailbox{Name: "INBOX"}
+	for i, data := range raw {
+		msg, err := message.Parse(data)
+		if err != nil {
+			t.Fatalf("Parse() error = %v", err)
+		}
+		m := &imaptest.Message{
+			Data:         data,
+			Flags:        []string{`\Seen`},
+			InternalDate: msg.Date,
+			ThreadID:     0x18c1,
+			MessageID:    uint64(0x18c10 + i),
+			Labels:       []string{`\Inbox`, "Projects/Conversion"},
+		}
+		if strings.HasPrefix(msg.Subject, "Lunch") {
+			m.Flags = nil
+			m.ThreadID = 0x18d0
+			m.Labels = []string{`\Inbox`}
+		}
+		mailbox.Messages = append(mailbox.Messages, m)
+	}
+
+	srv := &imaptest.Server{
+		Username:  "me@example.com",
+		Password:  "secret",
+		Token:     "oauth-token",
+		Gmail:     isGmail,
+		Mailboxes: []*imaptest.Mailbox{mailbox},
+	}
+	if isGmail {
+		mailbox.Name = "[Gmail]/Alle e-poster"
+		mailbox.Attributes = []string{`\All`, `\HasNoChildren`}
+		srv.Mailboxes = []*imaptest.Mailbox{{Name: "INBOX"}, mailbox}
+	}
+	srv.Start()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func openTestIMAP(t *testing.T, srv *imaptest.Server, opts IMAPOptions) *IMAP {
+	t.Helper()
+	opts.URL = "imaps://me%40example.com@" + srv.Addr + opts.URL
+	opts.TLSConfig = srv.ClientTLSConfig()
+	b, err := OpenIMAP(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("OpenIMAP() error = %v", err)
+	}
+	t.Cleanup(func() { b.Close() })
+	return b
+}
+
+func searchSubjects(t *testing.T, b Backend, query string) []string {
+	t.Helper()
+	result, err := b.SearchThreads(context.Background(), query, gmail.SearchOptions{})
+	if err != nil {
+		t.Fatalf("SearchThreads(%q) error = %v", query, err)
+	}
+	var subjects []string
+	for _, thread := range result.Threads {
+		subjects = append(subjects, thread.Subject)
+	}
+	return subjects
+}
+
+func TestIMAP(t *testing.T) {
+	ctx := context.Background()
+	srv := newIMAPServer(t, false)
+	b := openTestIMAP(t, srv, IMAPOptions{Password: "secret"})
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{query: "", want: []string{"Conversion factors", "Lunch?"}},
+		{query: "tacos", want: []string{"Lunch?"}},
+		{query: "from:carol", want: []string{"Lunch?"}},
+		{query: "to:carol", want: []string{"Conversion factors"}},
+		{query: "has:attachment", want: []string{"Conversion factors"}},
+		{query: "factors.csv", want: []string{"Conversion factors"}},
+		{query: "is:unread", want: []string{"Lunch?"}},
+		{query: "in:inbox -from:carol", want: []string{"Conversion factors"}},
+		{query: "tacos from:felipe", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			if got := searchSubjects(t, b, tt.query); !slices.Equal(got, tt.want) {
+				t.Errorf("SearchThreads(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+
+	// Single words narrow the search on the server
+	if cmds := srv.Commands(); !slices.Contains(cmds, `UID SEARCH TEXT "tacos"`) {
+		t.Errorf("commands = %q, want a server-side TEXT search", cmds)
+	}
+
+	// Threads and IDs are the same as when reading the mbox
+	local, err := OpenLocal(filepath.Join("testdata", "archive.mbox"))
+	if err != nil {
+		t.Fatalf("OpenLocal() error = %v", err)
+	}
+	want, err := local.SearchThreads(ctx, "", gmail.SearchOptions{})
+	if err != nil {
+		t.Fatalf("SearchThreads() error = %v", err)
+	}
+	result, err := b.SearchThreads(ctx, "", gmail.SearchOptions{Limit: 1})
+	if err != nil {
+		t.Fatalf("SearchThreads() error = %v", err)
+	}
+	if len(result.Threads) != 1 || result.Threads[0].ID != want.Threads[0].ID || result.Threads[0].MessageCount != 3 {
+		t.Fatalf("SearchThreads() with limit 1 = %+v, want %+v", result.Threads, want.Threads[0])
+	}
+
+	thread, err := b.GetThread(ctx, want.Threads[0].ID)
+	if err != nil {
+		t.Fatalf("GetThread() error = %v", err)
+	}
+	if len(thread.Messages) != 3 || thread.Messages[2].From != "Bob <bob@example.com>" {
+		t.Errorf("GetThread() messages = %+v, want 3 oldest first", thread.Messages)
+	}
+	att := thread.Messages[2].Attachments[0]
+	data, err := b.DownloadAttachment(ctx, att.MessageID, att.ID)
+	if err != nil {
+		t.Fatalf("DownloadAttachment() error = %v", err)
+	}
+	if !strings.HasPrefix(string(data), "unit,factor") {
+		t.Errorf("DownloadAttachment() = %q", data)
+	}
+
+	if _, err := b.GetThread(ctx, "missing"); err == nil {
+		t.Error("GetThread() with unknown ID succeeded, want error")
+	}
+
+	// Reading doesn't mark messages as read
+	for _, cmd := range srv.Commands() {
+		if strings.HasPrefix(cmd, "SELECT") || strings.Contains(cmd, "BODY[") {
+			t.Errorf("command %q may change flags", cmd)
+		}
+	}
+	if flags := srv.Mailboxes[0].Messages[3].Flags; len(flags) != 0 {
+		t.Errorf("unread message flags = %v, want none", flags)
+	}
+}
+
+func TestIMAP_Gmail(t *testing.T) {
+	ctx := context.Background()
+	srv := newIMAPServer(t, true)
+	b := openTestIMAP(t, srv, IMAPOptions{Token: "oauth-token"})
+
+	if cmds := srv.Commands(); !slices.Contains(cmds, `EXAMINE "[Gmail]/Alle e-poster"`) {
+		t.Errorf("commands = %q, want All Mail opened by its \\All attribute", cmds)
+	}
+
+	if got, want := searchSubjects(t, b, "from:carol OR has:attachment"), []string{"Conversion factors", "Lunch?"}; !slices.Equal(got, want) {
+		t.Errorf("SearchThreads() = %v, want %v", got, want)
+	}
+	if cmds := srv.Commands(); !slices.Contains(cmds, `UID SEARCH X-GM-RAW "from:carol OR has:attachment"`) {
+		t.Errorf("commands = %q, want an X-GM-RAW search", cmds)
+	}
+
+	result, err := b.SearchThreads(ctx, "label:projects-conversion", gmail.SearchOptions{})
+	if err != nil {
+		t.Fatalf("SearchThreads() error = %v", err)
+	}
+	if len(result.Threads) != 1 || result.Threads[0].ID != "18c1" || result.Threads[0].MessageCount != 3 {
+		t.Fatalf("SearchThreads() = %+v, want thread 18c1 with 3 messages", result.Threads)
+	}
+
+	thread, err := b.GetThread(ctx, "18c1")
+	if err != nil {
+		t.Fatalf("GetThread() error = %v", err)
+	}
+	if want := []string{"INBOX", "Projects/Conversion"}; !slices.Equal(thread.Labels, want) {
+		t.Errorf("Labels = %v, want %v", thread.Labels, want)
+	}
+	if got := thread.Messages[0].ID; got != "18c10" {
+		t.Errorf("message ID = %q, want Gmail's 18c10", got)
+	}
+	att := thread.Messages[2].Attachments[0]
+	if _, err := b.DownloadAttachment(ctx, att.MessageID, att.ID); err != nil {
+		t.Errorf("DownloadAttachment() error = %v", err)
+	}
+
+	lunch, err := b.GetThread(ctx, "18d0")
+	if err != nil {
+		t.Fatalf("GetThread() error = %v", err)
+	}
+	if want := []string{"INBOX", "UNREAD"}; !slices.Equal(lunch.Labels, want) {
+		t.Errorf("Labels = %v, want %v", lunch.Labels, want)
+	}
+
+	if _, err := b.GetThread(ctx, "not-hex"); err == nil {
+		t.Error("GetThread() with invalid ID succeeded, want error")
+	}
+}
+
+func TestOpenIMAP_Errors(t *testing.T) {
+	srv := newIMAPServer(t, false)
+
+	tests := []struct {
+		name string
+		opts IMAPOptions
+		want string
+	}{
+		{name: "scheme", opts: IMAPOptions{URL: "https://me@" + srv.Addr, Password: "secret"}, want: "must start with imaps://"},
+		{name: "no user", opts: IMAPOptions{URL: "imaps://" + srv.Addr, Password: "secret"}, want: "must include a user"},
+		{name: "no password", opts: IMAPOptions{URL: "imaps://me@" + srv.Addr}, want: "no IMAP password or token"},
+		{name: "wrong password", opts: IMAPOptions{URL: "imaps://me%40example.com@" + srv.Addr, Password: "wrong"}, want: "IMAP login failed"},
+		{name: "mailbox", opts: IMAPOptions{URL: "imaps://me%40example.com:secret@" + srv.Addr + "/Missing"}, want: "failed to open mailbox Missing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.TLSConfig = srv.ClientTLSConfig()
+			_, err := OpenIMAP(context.Background(), tt.opts)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("OpenIMAP() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
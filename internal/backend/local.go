@@ -0,0 +1,379 @@
+package backend
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/bentsolheim/gmail-cli/internal/gmail"
+	"github.com/bentsolheim/gmail-cli/internal/message"
+	"github.com/bentsolheim/gmail-cli/internal/query"
+)
+
+// ErrPageToken is returned when a backend without paging is given a page token.
+var ErrPageToken = errors.New("page tokens are only supported when reading from Gmail")
+
+// Local reads threads from an mbox file or a Maildir. Messages are grouped
+// into threads by their References and In-Reply-To headers. Message IDs are
+// derived from the Message-ID header, so they are stable across runs, and a
+// thread's ID is the ID of its first message.
+type Local struct {
+	threads  map[string]*gmail.Thread
+	messages map[string]*message.Message
+}
+
+var _ Backend = (*Local)(nil)
+
+// OpenLocal reads every message in an mbox file, or in the cur/ and new/
+// directories of a Maildir. Messages that can't be parsed are skipped with
+// a warning on stderr.
+func OpenLocal(path string) (*Local, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+
+	var raw [][]byte
+	if info.IsDir() {
+		raw, err = readMaildir(path)
+	} else {
+		raw, err = readMboxFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	l := &Local{
+		threads:  make(map[string]*gmail.Thread),
+		messages: make(map[string]*message.Message),
+	}
+	var parsed []*localMessage
+	for i, data := range raw {
+		msg, err := message.Parse(data)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: skipping message %d in %s: %v\n", i+1, path, err)
+			continue
+		}
+		id := localID(msg, data)
+		if _, dup := l.messages[id]; dup {
+			continue
+		}
+		l.messages[id] = msg
+		parsed = append(parsed, &localMessage{id: id, msg: msg})
+	}
+
+	for _, group := range groupThreads(parsed) {
+		thread := buildThread(group)
+		l.threads[thread.ID] = thread
+	}
+	return l, nil
+}
+
+// SearchThreads returns the threads with a message matching the query,
+// newest first. Queries use Gmail's search syntax as supported by package
+// query; archives have no labels, so label:, is: and in: match nothing.
+func (l *Local) SearchThreads(ctx context.Context, queryText string, opts gmail.SearchOptions) (*gmail.SearchResult, error) {
+	if opts.PageToken != "" {
+		return nil, ErrPageToken
+	}
+
+	q, err := query.Parse(queryText)
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []*gmail.Thread
+	for _, thread := range l.threads {
+		if q.Match(thread) {
+			matched = append(matched, thread)
+		}
+	}
+	slices.SortFunc(matched, func(a, b *gmail.Thread) int {
+		if c := b.DateRange.End.Compare(a.DateRange.End); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+	if opts.Limit > 0 && int64(len(matched)) > opts.Limit {
+		matched = matched[:opts.Limit]
+	}
+
+	result := &gmail.SearchResult{Threads: []gmail.ThreadSummary{}}
+	for _, thread := range matched {
+		result.Threads = append(result.Threads, thread.Summary())
+	}
+	return result, nil
+}
+
+// GetThread returns a thread by ID.
+func (l *Local) GetThread(ctx context.Context, threadID string) (*gmail.Thread, error) {
+	thread, ok := l.threads[threadID]
+	if !ok {
+		return nil, fmt.Errorf("thread %s not found in archive", threadID)
+	}
+	return thread, nil
+}
+
+// DownloadAttachment returns the decoded content of an attachment.
+func (l *Local) DownloadAttachment(ctx context.Context, messageID, attachmentID string) ([]byte, error) {
+	msg, ok := l.messages[messageID]
+	if !ok {
+		return nil, fmt.Errorf("message %s not found in archive", messageID)
+	}
+	att, ok := msg.Attachment(attachmentID)
+	if !ok {
+		return nil, fmt.Errorf("attachment %s not found in message %s", attachmentID, messageID)
+	}
+	return att.Data, nil
+}
+
+// localMessage is a parsed message with its local ID.
+type localMessage struct {
+	id     string
+	msg    *message.Message
+	size   int64
+	labels []string
+	// uid is the message's UID on an IMAP server.
+	uid uint32
+}
+
+// localID returns a stable ID for a message: a hash of its Message-ID, or
+// of its content if it has none.
+func localID(msg *message.Message, data []byte) string {
+	key := []byte(msg.MessageID)
+	if len(key) == 0 {
+		key = data
+	}
+	sum := sha1.Sum(key)
+	return hex.EncodeToString(sum[:8])
+}
+
+// groupThreads groups messages that reference each other, directly or
+// through other messages, with each group sorted by date.
+func groupThreads(messages []*localMessage) [][]*localMessage {
+	parent := make(map[string]string)
+	var find func(string) string
+	find = func(id string) string {
+		p, ok := parent[id]
+		if !ok || p == id {
+			parent[id] = id
+			return id
+		}
+		root := find(p)
+		parent[id] = root
+		return root
+	}
+	union := func(a, b string) {
+		parent[find(a)] = find(b)
+	}
+
+	keys := make([]string, len(messages))
+	for i, m := range messages {
+		keys[i] = m.msg.MessageID
+		if keys[i] == "" {
+			keys[i] = "local:" + m.id
+		}
+		find(keys[i])
+		for _, ref := range slices.Concat(m.msg.References, m.msg.InReplyTo) {
+			union(ref, keys[i])
+		}
+	}
+
+	groups := make(map[string][]*localMessage)
+	var roots []string
+	for i, m := range messages {
+		root := find(keys[i])
+		if _, ok := groups[root]; !ok {
+			roots = append(roots, root)
+		}
+		groups[root] = append(groups[root], m)
+	}
+
+	result := make([][]*localMessage, 0, len(roots))
+	for _, root := range roots {
+		group := groups[root]
+		sortByDate(group)
+		result = append(result, group)
+	}
+	return result
+}
+
+// sortByDate sorts messages oldest first.
+func sortByDate(messages []*localMessage) {
+	slices.SortStableFunc(messages, func(a, b *localMessage) int {
+		return a.msg.Date.Compare(b.msg.Date)
+	})
+}
+
+// buildThread assembles a thread from its messages, oldest first.
+func buildThread(messages []*localMessage) *gmail.Thread {
+	thread := &gmail.Thread{ID: messages[0].id}
+
+	seen := make(map[string]bool)
+	for _, m := range messages {
+		msg := m.msg
+		if thread.Subject == "" {
+			thread.Subject = msg.Subject
+		}
+		for _, header := range []string{msg.From, msg.To, msg.Cc} {
+			for _, addr := range addresses(header) {
+				if !seen[addr] {
+					seen[addr] = true
+					thread.Participants = append(thread.Participants, addr)
+				}
+			}
+		}
+		if !msg.Date.IsZero() {
+			if thread.DateRange.Start.IsZero() || msg.Date.Before(thread.DateRange.Start) {
+				thread.DateRange.Start = msg.Date
+			}
+			if msg.Date.After(thread.DateRange.End) {
+				thread.DateRange.End = msg.Date
+			}
+		}
+
+		gm := gmail.Message{
+			ID:      m.id,
+			From:    msg.From,
+			To:      msg.To,
+			Cc:      msg.Cc,
+			Subject: msg.Subject,
+			Date:    msg.Date,
+			Body:    msg.Body,
+			Labels:  m.labels,
+			Size:    m.size,
+		}
+		thread.Labels = append(thread.Labels, m.labels...)
+		for _, att := range msg.Attachments {
+			gm.Attachments = append(gm.Attachments, gmail.Attachment{
+				ID:        att.ID,
+				MessageID: m.id,
+				Filename:  att.Filename,
+				MimeType:  att.MimeType,
+				Size:      int64(len(att.Data)),
+			})
+		}
+		thread.Messages = append(thread.Messages, gm)
+	}
+	slices.Sort(thread.Labels)
+	thread.Labels = slices.Compact(thread.Labels)
+	return thread
+}
+
+// addresses returns the email addresses in an address list header.
+func addresses(header string) []string {
+	if strings.TrimSpace(header) == "" {
+		return nil
+	}
+	list, err := mail.ParseAddressList(header)
+	if err != nil {
+		return []string{strings.TrimSpace(header)}
+	}
+	emails := make([]string, 0, len(list))
+	for _, addr := range list {
+		emails = append(emails, addr.Address)
+	}
+	return emails
+}
+
+// readMboxFile reads the messages in an mbox file.
+func readMboxFile(path string) ([][]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer f.Close()
+
+	messages, err := readMbox(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return messages, nil
+}
+
+// readMbox splits an mbox into messages. Lines escaped as ">From " or, in
+// mboxrd files, ">>From " are unescaped by removing one ">".
+func readMbox(r io.Reader) ([][]byte, error) {
+	var messages [][]byte
+	var current *bytes.Buffer
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if bytes.HasPrefix(line, []byte("From ")) {
+			if current != nil {
+				messages = append(messages, trimSeparator(current.Bytes()))
+			}
+			current = &bytes.Buffer{}
+			continue
+		}
+		if current == nil {
+			// Not an mbox, or text before the first message
+			continue
+		}
+		if bytes.HasPrefix(bytes.TrimLeft(line, ">"), []byte("From ")) && line[0] == '>' {
+			line = line[1:]
+		}
+		current.Write(line)
+		current.WriteByte('\n')
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if current != nil {
+		messages = append(messages, trimSeparator(current.Bytes()))
+	}
+	return messages, nil
+}
+
+// trimSeparator removes the blank line that ends each mbox message.
+func trimSeparator(data []byte) []byte {
+	return bytes.TrimSuffix(data, []byte("\n"))
+}
+
+// readMaildir reads the messages in a Maildir's cur/ and new/ directories,
+// in filename order.
+func readMaildir(dir string) ([][]byte, error) {
+	var paths []string
+	found := false
+	for _, sub := range []string{"cur", "new"} {
+		entries, err := os.ReadDir(filepath.Join(dir, sub))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		found = true
+		if err != nil {
+			return nil, fmt.Errorf("failed to read maildir: %w", err)
+		}
+		for _, entry := range entries {
+			if entry.Type().IsRegular() && !strings.HasPrefix(entry.Name(), ".") {
+				paths = append(paths, filepath.Join(dir, sub, entry.Name()))
+			}
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("%s is not a Maildir (no cur/ or new/)", dir)
+	}
+
+	messages := make([][]byte, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read message: %w", err)
+		}
+		messages = append(messages, data)
+	}
+	return messages, nil
+}
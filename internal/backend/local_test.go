@@ -0,0 +1,167 @@
+package backend
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/bentsolheim/gmail-cli/internal/gmail"
+)
+
+func TestOpenLocal_Mbox(t *testing.T) {
+	ctx := context.Background()
+	local, err := OpenLocal(filepath.Join("testdata", "archive.mbox"))
+	if err != nil {
+		t.Fatalf("OpenLocal() error = %v", err)
+	}
+
+	result, err := local.SearchThreads(ctx, "", gmail.SearchOptions{})
+	if err != nil {
+		t.Fatalf("SearchThreads() error = %v", err)
+	}
+	if len(result.Threads) != 2 {
+		t.Fatalf("SearchThreads() returned %d threads, want 2", len(result.Threads))
+	}
+
+	// Replies are grouped by References and In-Reply-To and sorted by date,
+	// whatever their order in the file
+	conv := result.Threads[0]
+	if conv.Subject != "Conversion factors" || conv.MessageCount != 3 || conv.AttachmentCount != 1 {
+		t.Errorf("first thread = %+v, want the 3-message conversion thread", conv)
+	}
+	if want := []string{"Felipe Garcia", "Bob"}; !slices.Equal(conv.Participants, want) {
+		t.Errorf("Participants = %v, want %v", conv.Participants, want)
+	}
+
+	thread, err := local.GetThread(ctx, conv.ID)
+	if err != nil {
+		t.Fatalf("GetThread() error = %v", err)
+	}
+	var from []string
+	for _, msg := range thread.Messages {
+		from = append(from, msg.From)
+	}
+	if want := []string{"Felipe Garcia <felipe@example.com>", "Felipe Garcia <felipe@example.com>", "Bob <bob@example.com>"}; !slices.Equal(from, want) {
+		t.Errorf("message senders = %v, want %v", from, want)
+	}
+	if want := "Here are the conversion factors.\nFrom now on we use the new ones.\n"; thread.Messages[0].Body != want {
+		t.Errorf("Body = %q, want %q (unescaped)", thread.Messages[0].Body, want)
+	}
+	if want := []string{"felipe@example.com", "bob@example.com", "carol@example.com"}; !slices.Equal(thread.Participants, want) {
+		t.Errorf("thread Participants = %v, want %v", thread.Participants, want)
+	}
+
+	// IDs are stable across runs
+	again, err := OpenLocal(filepath.Join("testdata", "archive.mbox"))
+	if err != nil {
+		t.Fatalf("OpenLocal() error = %v", err)
+	}
+	if _, err := again.GetThread(ctx, conv.ID); err != nil {
+		t.Errorf("GetThread() after reopening error = %v", err)
+	}
+
+	att := thread.Messages[2].Attachments[0]
+	if att.Filename != "factors.csv" || att.MimeType != "text/csv" {
+		t.Errorf("attachment = %+v, want factors.csv", att)
+	}
+	data, err := local.DownloadAttachment(ctx, att.MessageID, att.ID)
+	if err != nil {
+		t.Fatalf("DownloadAttachment() error = %v", err)
+	}
+	if want := "unit,factor\nkm,1000\n"; string(data) != want {
+		t.Errorf("DownloadAttachment() = %q, want %q", data, want)
+	}
+	if int(att.Size) != len(data) {
+		t.Errorf("Size = %d, want %d", att.Size, len(data))
+	}
+
+	if _, err := local.GetThread(ctx, "missing"); err == nil {
+		t.Error("GetThread() of missing thread succeeded, want error")
+	}
+	if _, err := local.SearchThreads(ctx, "", gmail.SearchOptions{PageToken: "x"}); err != ErrPageToken {
+		t.Errorf("SearchThreads() with page token error = %v, want ErrPageToken", err)
+	}
+}
+
+func TestLocal_SearchThreads(t *testing.T) {
+	local, err := OpenLocal(filepath.Join("testdata", "archive.mbox"))
+	if err != nil {
+		t.Fatalf("OpenLocal() error = %v", err)
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{query: "tacos", want: []string{"Lunch?"}},
+		{query: "from:carol", want: []string{"Lunch?"}},
+		{query: "to:carol", want: []string{"Conversion factors"}},
+		{query: "subject:conversion", want: []string{"Conversion factors"}},
+		{query: "has:attachment", want: []string{"Conversion factors"}},
+		{query: "factors.csv", want: []string{"Conversion factors"}},
+		{query: "filename:csv", want: []string{"Conversion factors"}},
+		{query: "{from:carol has:attachment}", want: []string{"Conversion factors", "Lunch?"}},
+		{query: "tacos from:felipe", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			result, err := local.SearchThreads(context.Background(), tt.query, gmail.SearchOptions{})
+			if err != nil {
+				t.Fatalf("SearchThreads() error = %v", err)
+			}
+			var got []string
+			for _, thread := range result.Threads {
+				got = append(got, thread.Subject)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("SearchThreads(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+
+	result, err := local.SearchThreads(context.Background(), "", gmail.SearchOptions{Limit: 1})
+	if err != nil {
+		t.Fatalf("SearchThreads() error = %v", err)
+	}
+	if len(result.Threads) != 1 {
+		t.Errorf("SearchThreads() with limit 1 returned %d threads", len(result.Threads))
+	}
+}
+
+func TestOpenLocal_Maildir(t *testing.T) {
+	dir := t.TempDir()
+	for _, sub := range []string{"cur", "new", "tmp"} {
+		if err := os.Mkdir(filepath.Join(dir, sub), 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	messages := map[string]string{
+		"cur/1.a.host:2,S": "Message-ID: <a@example.com>\r\nFrom: alice@example.com\r\nSubject: Hello\r\nDate: Mon, 01 Dec 2025 09:00:00 +0000\r\n\r\nHi\r\n",
+		"new/2.b.host":     "Message-ID: <b@example.com>\r\nFrom: bob@example.com\r\nSubject: Re: Hello\r\nIn-Reply-To: <a@example.com>\r\nDate: Mon, 01 Dec 2025 10:00:00 +0000\r\n\r\nHello\r\n",
+		"tmp/3.c.host":     "Message-ID: <c@example.com>\r\nSubject: In delivery\r\n\r\nIgnored\r\n",
+	}
+	for name, content := range messages {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	local, err := OpenLocal(dir)
+	if err != nil {
+		t.Fatalf("OpenLocal() error = %v", err)
+	}
+	result, err := local.SearchThreads(context.Background(), "", gmail.SearchOptions{})
+	if err != nil {
+		t.Fatalf("SearchThreads() error = %v", err)
+	}
+	if len(result.Threads) != 1 || result.Threads[0].MessageCount != 2 {
+		t.Errorf("SearchThreads() = %+v, want one thread of 2 messages", result.Threads)
+	}
+
+	if _, err := OpenLocal(t.TempDir()); err == nil {
+		t.Error("OpenLocal() of a directory that is not a Maildir succeeded, want error")
+	}
+}
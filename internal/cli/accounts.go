@@ -0,0 +1,110 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/bentsolheim/gmail-cli/internal/auth"
+	"github.com/bentsolheim/gmail-cli/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var accountsCmd = &cobra.Command{
+	Use:   "accounts",
+	Short: "Manage account profiles",
+	Long: `Manage account profiles.
+
+Each account has its own token, and optionally its own credentials.json and
+config.yaml in ~/.config/gmail-cli/accounts/<name>/. Accounts without their
+own credentials use ~/.config/gmail-cli/credentials.json, and their own
+settings override those in ~/.config/gmail-cli/config.yaml. The "default" account keeps its
+files directly in ~/.config/gmail-cli/.
+
+Select an account with --account or GMAIL_CLI_ACCOUNT, or save a default
+with 'accounts use'.
+
+Examples:
+  gmail-cli auth --account work
+  gmail-cli search "is:unread" --account work
+  gmail-cli accounts list
+  gmail-cli accounts use work`,
+}
+
+var accountsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List account profiles",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		names, err := config.Accounts()
+		if err != nil {
+			return fmt.Errorf("failed to list accounts: %w", err)
+		}
+
+		active := config.Account()
+		for _, name := range names {
+			marker := " "
+			if name == active {
+				marker = "*"
+			}
+
+			token := tokenStatus(name)
+
+			credentials := "shared credentials"
+			if config.HasOwnCredentials(name) {
+				credentials = "own credentials"
+			}
+
+			fmt.Printf("%s %s (%s, %s)\n", marker, name, token, credentials)
+		}
+
+		return nil
+	},
+}
+
+var accountsUseCmd = &cobra.Command{
+	Use:   "use <name>",
+	Short: "Set the default account profile",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		if err := config.ValidateAccountName(name); err != nil {
+			return err
+		}
+
+		// Create the account directory so the account shows up in 'accounts list'
+		if err := os.MkdirAll(config.AccountDir(name), 0700); err != nil {
+			return fmt.Errorf("failed to create account directory: %w", err)
+		}
+
+		if err := config.SetDefaultAccount(name); err != nil {
+			return fmt.Errorf("failed to save default account: %w", err)
+		}
+
+		fmt.Printf("Default account set to: %s\n", name)
+		return nil
+	},
+}
+
+// tokenStatus describes an account's stored token: "authenticated", "no
+// token", or the error if the token exists but can't be read or decrypted.
+func tokenStatus(name string) string {
+	store, err := auth.NewAccountTokenStore(name)
+	if err != nil {
+		return fmt.Sprintf("token error: %v", err)
+	}
+	_, err = store.Load()
+	switch {
+	case err == nil:
+		return "authenticated"
+	case errors.Is(err, os.ErrNotExist):
+		return "no token"
+	default:
+		return fmt.Sprintf("token error: %v", err)
+	}
+}
+
+func init() {
+	accountsCmd.AddCommand(accountsListCmd, accountsUseCmd)
+	rootCmd.AddCommand(accountsCmd)
+}
@@ -0,0 +1,35 @@
+package cli
+
+import (
+	"os"
+	"testing"
+
+	"github.com/bentsolheim/gmail-cli/internal/config"
+)
+
+func TestAccountsList_TokenStatus(t *testing.T) {
+	setupCLI(t)
+
+	for name, token := range map[string]string{
+		"work":     `{"access_token":"abc","token_type":"Bearer"}`,
+		"personal": `{"access_token":`,
+	} {
+		if err := os.MkdirAll(config.AccountDir(name), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(config.AccountTokenPath(name), []byte(token), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.MkdirAll(config.AccountDir("spare"), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := runCLI(t, "accounts", "list")
+	if err != nil {
+		t.Fatalf("accounts list error = %v", err)
+	}
+	mustContain(t, out, "work (authenticated,")
+	mustContain(t, out, "personal (token error: unexpected EOF,")
+	mustContain(t, out, "spare (no token,")
+}
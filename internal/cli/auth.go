@@ -3,20 +3,61 @@ package cli
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/bentsolheim/gmail-cli/internal/auth"
+	"github.com/bentsolheim/gmail-cli/internal/config"
 	"github.com/spf13/cobra"
 )
 
+var (
+	authNoBrowser    bool
+	authListenAddr   string
+	authTokenInfoURL string
+	authRevokeURL    string
+	authSubject      string
+)
+
 var authCmd = &cobra.Command{
 	Use:   "auth",
 	Short: "Authenticate with Gmail",
-	Long:  `Triggers the OAuth authentication flow with Gmail. Opens a browser for authorization.`,
+	Long: `Triggers the OAuth authentication flow with Gmail. Opens a browser for authorization.
+
+On a remote machine (SSH, containers), use --no-browser to print the
+authorization URL and paste the redirect URL back into the terminal, or
+forward a fixed callback port with --listen-addr.
+
+If credentials.json is a service account key with domain-wide delegation, no
+browser is involved: the service account impersonates the user given with
+--subject (saved for later runs) or GMAIL_CLI_SUBJECT.
+
+Examples:
+  gmail-cli auth
+  gmail-cli auth --no-browser
+  gmail-cli auth --listen-addr localhost:8085   # ssh -L 8085:localhost:8085 host
+  gmail-cli auth --subject support@example.com  # service account
+  gmail-cli auth status
+  gmail-cli auth revoke
+  gmail-cli auth logout`,
+	Args: cobra.NoArgs,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		ctx := context.Background()
 
+		opts := auth.DefaultServiceOptions()
+		opts.Verbose = verbose
+		opts.Flow = auth.FlowOptions{
+			NoBrowser:  authNoBrowser,
+			ListenAddr: authListenAddr,
+		}
+
+		if authSubject != "" {
+			if err := config.SetServiceAccountSubject(authSubject); err != nil {
+				return fmt.Errorf("failed to save subject: %w", err)
+			}
+		}
+
 		fmt.Println("Starting authentication...")
-		service, err := auth.ForceReauth(ctx)
+		service, err := auth.ForceReauth(ctx, opts)
 		if err != nil {
 			return fmt.Errorf("authentication failed: %w", err)
 		}
@@ -32,6 +73,93 @@ var authCmd = &cobra.Command{
 	},
 }
 
+var authStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show authentication status",
+	Long: `Shows the stored token, its expiry, granted scopes and the authenticated account.
+
+Refreshes the access token if it has expired, but never starts the browser flow.
+Exits with an error if the stored credentials don't work.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+
+		opts := auth.DefaultServiceOptions()
+		opts.Verbose = verbose
+
+		status, err := auth.GetStatus(ctx, authTokenInfoURL, opts)
+		if status != nil {
+			printAuthStatus(status)
+		}
+		if err != nil {
+			return err
+		}
+
+		fmt.Println("Status: OK")
+		return nil
+	},
+}
+
+var authRevokeCmd = &cobra.Command{
+	Use:   "revoke",
+	Short: "Revoke the token with Google and delete it locally",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := auth.Revoke(context.Background(), authRevokeURL); err != nil {
+			return err
+		}
+
+		fmt.Println("Token revoked and deleted.")
+		return nil
+	},
+}
+
+var authLogoutCmd = &cobra.Command{
+	Use:   "logout",
+	Short: "Delete the locally stored token",
+	Long:  `Deletes the locally stored token without revoking it with Google.`,
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := auth.Logout(); err != nil {
+			return err
+		}
+
+		fmt.Println("Logged out.")
+		return nil
+	},
+}
+
+// printAuthStatus prints the known parts of an authentication status.
+func printAuthStatus(status *auth.Status) {
+	fmt.Printf("Token: %s\n", status.TokenLocation)
+
+	if status.Expiry.IsZero() {
+		fmt.Println("Expires: never")
+	} else {
+		fmt.Printf("Expires: %s\n", status.Expiry.Local().Format("Jan 2, 2006 3:04 PM MST"))
+	}
+
+	refresh := "no"
+	if status.HasRefreshToken {
+		refresh = "yes"
+	}
+	fmt.Printf("Refresh token: %s\n", refresh)
+
+	if len(status.Scopes) > 0 {
+		fmt.Printf("Scopes: %s\n", strings.Join(status.Scopes, ", "))
+	}
+	if status.Email != "" {
+		fmt.Printf("Account: %s\n", status.Email)
+	}
+}
+
 func init() {
+	authStatusCmd.Flags().StringVar(&authTokenInfoURL, "tokeninfo-url", auth.DefaultTokenInfoURL, "OAuth token info endpoint")
+	authRevokeCmd.Flags().StringVar(&authRevokeURL, "revoke-url", auth.DefaultRevokeURL, "OAuth token revocation endpoint")
+	authCmd.AddCommand(authStatusCmd, authRevokeCmd, authLogoutCmd)
+
+	authCmd.Flags().StringVar(&authSubject, "subject", "", "User to impersonate with service account credentials")
+	authCmd.Flags().BoolVar(&authNoBrowser, "no-browser", false, "Print the authorization URL and read the redirect URL or code from stdin")
+	authCmd.Flags().StringVar(&authListenAddr, "listen-addr", "", "Address for the local callback server, e.g. localhost:8085 (default: random port)")
 	rootCmd.AddCommand(authCmd)
 }
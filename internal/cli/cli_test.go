@@ -0,0 +1,88 @@
+package cli
+
+import (
+	"bytes"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bentsolheim/gmail-cli/internal/config"
+	"github.com/bentsolheim/gmail-cli/internal/fakegmail"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// setupCLI points the config and data directories at temporary ones and
+// returns the URL of a fake Gmail server for the fakegmail fixtures.
+func setupCLI(t *testing.T) string {
+	t.Helper()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	t.Setenv("GMAIL_CLI_ACCOUNT", "")
+	// --account selects the account for the rest of the process
+	t.Cleanup(func() { config.SetAccount(config.DefaultAccount) })
+
+	srv, err := fakegmail.New(filepath.Join("..", "fakegmail", "testdata"))
+	if err != nil {
+		t.Fatalf("fakegmail.New() error = %v", err)
+	}
+	server := httptest.NewServer(srv)
+	t.Cleanup(server.Close)
+	return server.URL + "/"
+}
+
+// runCLI runs gmail-cli with args and returns what it wrote to stdout. Flags
+// are reset first, since they keep their values between runs.
+func runCLI(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	resetFlags(rootCmd)
+
+	stdout := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Stdout = w
+	done := make(chan []byte)
+	go func() {
+		data, _ := io.ReadAll(r)
+		done <- data
+	}()
+
+	rootCmd.SetArgs(args)
+	rootCmd.SetOut(io.Discard)
+	rootCmd.SetErr(io.Discard)
+	err = rootCmd.Execute()
+
+	w.Close()
+	os.Stdout = stdout
+	return string(<-done), err
+}
+
+// resetFlags sets the flags of cmd and its subcommands back to their
+// defaults, as not changed.
+func resetFlags(cmd *cobra.Command) {
+	reset := func(f *pflag.Flag) {
+		if s, ok := f.Value.(pflag.SliceValue); ok {
+			s.Replace(nil)
+		} else {
+			f.Value.Set(f.DefValue)
+		}
+		f.Changed = false
+	}
+	cmd.Flags().VisitAll(reset)
+	cmd.PersistentFlags().VisitAll(reset)
+	for _, c := range cmd.Commands() {
+		resetFlags(c)
+	}
+}
+
+// mustContain fails the test if out doesn't contain want.
+func mustContain(t *testing.T, out, want string) {
+	t.Helper()
+	if !bytes.Contains([]byte(out), []byte(want)) {
+		t.Errorf("output = %q, want it to contain %q", out, want)
+	}
+}
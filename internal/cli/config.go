@@ -0,0 +1,172 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bentsolheim/gmail-cli/internal/config"
+	"github.com/bentsolheim/gmail-cli/internal/output"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// globalSettingFlags maps settings to the root flags they provide defaults for.
+// Other settings are named <command>.<flag>, e.g. search.limit for
+// 'search --limit', and apply to that command's flag.
+var globalSettingFlags = map[string]string{
+	"user":            "user",
+	"output.format":   "format",
+	"output.template": "template",
+	"output.timezone": "timezone",
+	"imap.url":        "imap",
+	"api.endpoint":    "api-endpoint",
+	"api.no_auth":     "no-auth",
+}
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Show and change settings",
+	Long: `Show and change the defaults stored in ~/.config/gmail-cli/config.yaml.
+
+Each account can have its own config.yaml in
+~/.config/gmail-cli/accounts/<name>/, whose settings override the global
+ones. With an account selected by --account, GMAIL_CLI_ACCOUNT or
+'accounts use', set and unset change that account's file; use
+--account default to change the global file.
+
+Every setting can be overridden with an environment variable named after its
+key, e.g. GMAIL_CLI_SEARCH_LIMIT for search.limit, and flags override all of
+these. List settings take one value per line in the environment.
+
+Examples:
+  gmail-cli config list
+  gmail-cli config set search.limit 100
+  gmail-cli config set download.messages_only true
+  gmail-cli config set output.quote_patterns "^Le .+ a écrit :$" "^Am .+ schrieb .+:$"
+  gmail-cli --account work config set imap.url imaps://me@work.example.com@imap.work.example.com
+  gmail-cli config get output.timezone
+  gmail-cli config unset search.limit`,
+	// Settings are not applied to config subcommands, so a broken config.yaml
+	// can still be located and repaired. The account still selects which
+	// config.yaml they read and change.
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		return selectAccount()
+	},
+}
+
+var configListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List all settings with their effective values and sources",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		for _, s := range config.Settings() {
+			values, source, err := config.Lookup(s.Key)
+			if err != nil {
+				return err
+			}
+			if source == config.SourceAccountFile {
+				source = fmt.Sprintf("%s of %s", source, config.Account())
+			}
+			fmt.Printf("%s = %s (%s)\n", s.Key, strings.Join(values, ", "), source)
+		}
+		return nil
+	},
+}
+
+var configGetCmd = &cobra.Command{
+	Use:   "get <key>",
+	Short: "Print the effective value of a setting",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		values, _, err := config.Lookup(args[0])
+		if err != nil {
+			return err
+		}
+		for _, v := range values {
+			fmt.Println(v)
+		}
+		return nil
+	},
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set <key> <value>...",
+	Short: "Save a setting to the active account's config.yaml",
+	Args:  cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := config.SetSetting(args[0], args[1:]); err != nil {
+			return err
+		}
+		fmt.Printf("Set %s in %s\n", args[0], config.SettingsPath())
+		return nil
+	},
+}
+
+var configUnsetCmd = &cobra.Command{
+	Use:   "unset <key>",
+	Short: "Remove a setting from the active account's config.yaml",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return config.UnsetSetting(args[0])
+	},
+}
+
+var configPathCmd = &cobra.Command{
+	Use:   "path",
+	Short: "Print the path to the active account's config.yaml",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Println(config.SettingsPath())
+	},
+}
+
+// applySettings uses settings from the environment and config.yaml as
+// defaults for flags not given on the command line.
+func applySettings(cmd *cobra.Command) error {
+	for _, s := range config.Settings() {
+		values, source, err := config.Lookup(s.Key)
+		if err != nil {
+			return err
+		}
+		if source == config.SourceDefault {
+			continue
+		}
+
+		if s.Key == "output.quote_patterns" {
+			if err := output.AddQuotePatterns(values); err != nil {
+				return err
+			}
+			continue
+		}
+
+		flag := settingFlag(cmd, s.Key)
+		if flag == nil || flag.Changed {
+			continue
+		}
+		for _, v := range values {
+			if err := flag.Value.Set(v); err != nil {
+				return fmt.Errorf("invalid %s from %s: %w", s.Key, source, err)
+			}
+		}
+	}
+	return nil
+}
+
+// settingFlag returns the flag of cmd that a setting provides the default for,
+// or nil if the setting doesn't apply to cmd.
+func settingFlag(cmd *cobra.Command, key string) *pflag.Flag {
+	if name, ok := globalSettingFlags[key]; ok {
+		return cmd.Root().PersistentFlags().Lookup(name)
+	}
+
+	command, name, ok := strings.Cut(key, ".")
+	if !ok || command != cmd.Name() {
+		return nil
+	}
+	return cmd.Flags().Lookup(strings.ReplaceAll(name, "_", "-"))
+}
+
+func init() {
+	configCmd.AddCommand(configListCmd, configGetCmd, configSetCmd, configUnsetCmd, configPathCmd)
+	rootCmd.AddCommand(configCmd)
+}
@@ -0,0 +1,52 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/bentsolheim/gmail-cli/internal/config"
+)
+
+func TestConfig_AccountSettings(t *testing.T) {
+	setupCLI(t)
+
+	if _, err := runCLI(t, "config", "set", "search.limit", "100"); err != nil {
+		t.Fatalf("config set error = %v", err)
+	}
+	out, err := runCLI(t, "--account", "work", "config", "set", "search.limit", "5")
+	if err != nil {
+		t.Fatalf("config set --account work error = %v", err)
+	}
+	mustContain(t, out, "accounts/work/config.yaml")
+
+	out, err = runCLI(t, "--account", "work", "config", "list")
+	if err != nil {
+		t.Fatalf("config list error = %v", err)
+	}
+	mustContain(t, out, "search.limit = 5 (account config file of work)")
+
+	// The global file is unchanged
+	out, err = runCLI(t, "--account", config.DefaultAccount, "config", "list")
+	if err != nil {
+		t.Fatalf("config list error = %v", err)
+	}
+	mustContain(t, out, "search.limit = 100 (config file)")
+}
+
+func TestConfig_AccountIMAPURL(t *testing.T) {
+	setupCLI(t)
+
+	if _, err := runCLI(t, "--account", "work", "config", "set", "imap.url", "imaps://me@imap.example.com"); err != nil {
+		t.Fatalf("config set error = %v", err)
+	}
+	out, err := runCLI(t, "--account", "work", "config", "get", "imap.url")
+	if err != nil {
+		t.Fatalf("config get error = %v", err)
+	}
+	mustContain(t, out, "imaps://me@imap.example.com")
+
+	// Other accounts still read Gmail
+	out, err = runCLI(t, "--account", "personal", "config", "get", "imap.url")
+	if err != nil || out != "" {
+		t.Errorf("config get for another account = %q, %v; want no value", out, err)
+	}
+}
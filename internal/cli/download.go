@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"slices"
 
 	"github.com/bentsolheim/gmail-cli/internal/gmail"
 	"github.com/bentsolheim/gmail-cli/internal/output"
@@ -15,6 +16,10 @@ var (
 	downloadNoAttach    bool
 	downloadReverse     bool
 	downloadMessagesOnly bool
+	downloadRaw         bool
+	downloadMessageID   string
+	downloadOffline     bool
+	downloadLinkBase    string
 )
 
 var downloadCmd = &cobra.Command{
@@ -24,10 +29,35 @@ var downloadCmd = &cobra.Command{
 
 Thread content is written to stdout. Attachments are saved to --output-dir.
 
+With --raw, each message is saved byte-for-byte as <message-id>.eml in
+--output-dir, with all headers, MIME parts and attachments. Without
+--output-dir, a single message (the only one in the thread, or the one
+selected with --message) is written to stdout.
+
+With --offline, the thread is read from the local mirror kept by
+'gmail-cli sync'. Attachments and original messages are not mirrored, so
+--output-dir and --raw cannot be used offline.
+
+With --format markdown, saved attachments are linked relative to the
+current directory, or to --link-base, such as the directory the Markdown
+file is saved in.
+
+With --archive, the thread is read from a local mbox file or Maildir, using
+a thread ID printed by searching the same archive.
+
+With --imap, the thread and its attachments are read from an IMAP mailbox,
+using a thread ID printed by searching the same mailbox.
+
 Examples:
   gmail-cli download 18c1234abcd5678 --output-dir ./emails
   gmail-cli download 18c1234abcd5678 --no-attachments
-  gmail-cli download 18c1234abcd5678 --reverse --messages-only`,
+  gmail-cli download 18c1234abcd5678 --reverse --messages-only
+  gmail-cli download 18c1234abcd5678 --format json --no-attachments
+  gmail-cli download 18c1234abcd5678 --format markdown -o attachments > thread.md
+  gmail-cli download 18c1234abcd5678 --format markdown -o vault/att --link-base vault > vault/thread.md
+  gmail-cli download 18c1234abcd5678 --raw -o ./evidence
+  gmail-cli download 18c1234abcd5678 --raw --message 18c1234abcd9999 > message.eml
+  gmail-cli download 18c1234abcd5678 --offline`,
 	Args: cobra.ExactArgs(1),
 	RunE: runDownload,
 }
@@ -37,6 +67,10 @@ func init() {
 	downloadCmd.Flags().BoolVar(&downloadNoAttach, "no-attachments", false, "Skip downloading attachments")
 	downloadCmd.Flags().BoolVarP(&downloadReverse, "reverse", "r", false, "Display messages in reverse order (newest first)")
 	downloadCmd.Flags().BoolVarP(&downloadMessagesOnly, "messages-only", "m", false, "Strip quoted content, showing only new message text")
+	downloadCmd.Flags().BoolVar(&downloadRaw, "raw", false, "Download the original messages as .eml files")
+	downloadCmd.Flags().StringVar(&downloadMessageID, "message", "", "With --raw, download only this message of the thread")
+	downloadCmd.Flags().BoolVar(&downloadOffline, "offline", false, "Read the thread from the local mirror instead of Gmail (see 'gmail-cli sync')")
+	downloadCmd.Flags().StringVar(&downloadLinkBase, "link-base", "", "With --format markdown, link attachments relative to this directory")
 	rootCmd.AddCommand(downloadCmd)
 }
 
@@ -44,16 +78,44 @@ func runDownload(cmd *cobra.Command, args []string) error {
 	threadID := args[0]
 	ctx := context.Background()
 
-	client, err := gmail.NewClient(ctx)
+	// A download.output_dir from config.yaml is ignored offline, as there
+	// are no attachments to save
+	if downloadOffline && (downloadRaw || cmd.Flags().Changed("output-dir")) {
+		return fmt.Errorf("--raw and --output-dir cannot be used with --offline; attachments and original messages are not mirrored")
+	}
+
+	if downloadRaw {
+		// A configured imap.url gives way to --raw, as in newBackend
+		if cmd.Flags().Changed("archive") || cmd.Flags().Changed("imap") {
+			return fmt.Errorf("--raw cannot be used with --archive or --imap")
+		}
+		client, err := gmail.NewClient(ctx, clientOptions())
+		if err != nil {
+			return fmt.Errorf("failed to create Gmail client: %w", err)
+		}
+		return downloadRawMessages(ctx, client, threadID)
+	}
+	if downloadMessageID != "" {
+		return fmt.Errorf("--message requires --raw")
+	}
+	if downloadLinkBase != "" && (outputFormat != "markdown" || templateName != "") {
+		return fmt.Errorf("--link-base requires --format markdown")
+	}
+
+	b, err := newBackend(ctx, cmd, downloadOffline)
 	if err != nil {
-		return fmt.Errorf("failed to create Gmail client: %w", err)
+		return err
 	}
+	defer closeBackend(b)
 
-	thread, err := client.GetThread(ctx, threadID)
+	thread, err := b.GetThread(ctx, threadID)
 	if err != nil {
 		return fmt.Errorf("failed to get thread: %w", err)
 	}
 
+	// The mirror has no attachments to download
+	noAttachments := downloadNoAttach || downloadOffline
+
 	savedAttachments := make(map[string]string)
 
 	// Check if thread has attachments
@@ -66,15 +128,15 @@ func runDownload(cmd *cobra.Command, args []string) error {
 	}
 
 	// Validate output-dir is provided if there are attachments
-	if hasAttachments && !downloadNoAttach && downloadOutputDir == "" {
+	if hasAttachments && !noAttachments && downloadOutputDir == "" {
 		return fmt.Errorf("thread has attachments; specify --output-dir or use --no-attachments")
 	}
 
 	// Download attachments if not disabled
-	if !downloadNoAttach && downloadOutputDir != "" {
+	if !noAttachments && downloadOutputDir != "" {
 		for _, msg := range thread.Messages {
 			for _, att := range msg.Attachments {
-				data, err := client.DownloadAttachment(ctx, msg.ID, att.ID)
+				data, err := b.DownloadAttachment(ctx, msg.ID, att.ID)
 				if err != nil {
 					fmt.Fprintf(os.Stderr, "Warning: failed to download %s: %v\n", att.Filename, err)
 					continue
@@ -91,12 +153,60 @@ func runDownload(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	formatter := output.NewTextFormatter()
+	formatter, err := newFormatter()
+	if err != nil {
+		return err
+	}
+	if md, ok := formatter.(*output.MarkdownFormatter); ok {
+		md.BaseDir = downloadLinkBase
+	}
 	opts := output.FormatOptions{
 		Reverse:      downloadReverse,
 		MessagesOnly: downloadMessagesOnly,
 	}
-	fmt.Print(formatter.FormatThread(thread, savedAttachments, opts))
+	return printFormatted(formatter, formatter.FormatThread(thread, savedAttachments, opts))
+}
+
+// downloadRawMessages saves the thread's messages as .eml files in
+// --output-dir, or writes a single message to stdout.
+func downloadRawMessages(ctx context.Context, client *gmail.Client, threadID string) error {
+	messageIDs, err := client.ThreadMessageIDs(ctx, threadID)
+	if err != nil {
+		return fmt.Errorf("failed to get thread: %w", err)
+	}
+
+	if downloadMessageID != "" {
+		if !slices.Contains(messageIDs, downloadMessageID) {
+			return fmt.Errorf("message %s is not in thread %s", downloadMessageID, threadID)
+		}
+		messageIDs = []string{downloadMessageID}
+	}
+
+	if downloadOutputDir == "" {
+		if len(messageIDs) != 1 {
+			return fmt.Errorf("thread has %d messages; specify --output-dir, or --message to write one to stdout", len(messageIDs))
+		}
+
+		msg, err := client.GetRawMessage(ctx, messageIDs[0])
+		if err != nil {
+			return fmt.Errorf("failed to get message: %w", err)
+		}
+		_, err = os.Stdout.Write(msg.Data)
+		return err
+	}
+
+	for _, id := range messageIDs {
+		msg, err := client.GetRawMessage(ctx, id)
+		if err != nil {
+			return fmt.Errorf("failed to get message %s: %w", id, err)
+		}
+
+		savedPath, err := gmail.SaveRawMessage(msg.Data, downloadOutputDir, id)
+		if err != nil {
+			return err
+		}
+		fmt.Println(savedPath)
+	}
 
 	return nil
 }
@@ -0,0 +1,91 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDownload_OfflineWithConfiguredOutputDir(t *testing.T) {
+	endpoint := setupCLI(t)
+	if _, err := runCLI(t, "--api-endpoint", endpoint, "--no-auth", "sync"); err != nil {
+		t.Fatalf("sync error = %v", err)
+	}
+	out, err := runCLI(t, "search", "--offline", "--format", "json", "subject:conversion")
+	if err != nil {
+		t.Fatalf("search --offline error = %v", err)
+	}
+	_, rest, _ := strings.Cut(out, `"id": "`)
+	threadID, _, _ := strings.Cut(rest, `"`)
+
+	// A configured directory doesn't conflict with --offline
+	if _, err := runCLI(t, "config", "set", "download.output_dir", t.TempDir()); err != nil {
+		t.Fatalf("config set error = %v", err)
+	}
+	out, err = runCLI(t, "download", "--offline", threadID)
+	if err != nil {
+		t.Fatalf("download --offline error = %v", err)
+	}
+	mustContain(t, out, "Conversion factors")
+
+	// Asking for one on the command line still does
+	if _, err := runCLI(t, "download", "--offline", "-o", t.TempDir(), threadID); err == nil {
+		t.Error("download --offline --output-dir succeeded, want error")
+	}
+}
+
+func TestDownload_RawWithConfiguredIMAP(t *testing.T) {
+	endpoint := setupCLI(t)
+	out, err := runCLI(t, "--api-endpoint", endpoint, "--no-auth", "search", "--format", "json", "subject:lunch")
+	if err != nil {
+		t.Fatalf("search error = %v", err)
+	}
+	_, rest, _ := strings.Cut(out, `"id": "`)
+	threadID, _, _ := strings.Cut(rest, `"`)
+
+	if _, err := runCLI(t, "config", "set", "imap.url", "imaps://me@imap.example.com"); err != nil {
+		t.Fatalf("config set error = %v", err)
+	}
+
+	// A configured mailbox gives way to --raw
+	dir := t.TempDir()
+	if _, err := runCLI(t, "--api-endpoint", endpoint, "--no-auth", "download", "--raw", "-o", dir, threadID); err != nil {
+		t.Fatalf("download --raw error = %v", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || filepath.Ext(entries[0].Name()) != ".eml" {
+		t.Errorf("download --raw wrote %v, want one .eml file", entries)
+	}
+
+	// Giving --imap on the command line still conflicts
+	if _, err := runCLI(t, "--imap", "imaps://me@imap.example.com", "download", "--raw", "-o", dir, threadID); err == nil {
+		t.Error("download --imap --raw succeeded, want error")
+	}
+}
+
+func TestDownload_MarkdownLinkBase(t *testing.T) {
+	endpoint := setupCLI(t)
+	out, err := runCLI(t, "--api-endpoint", endpoint, "--no-auth", "search", "--format", "json", "filename:csv")
+	if err != nil {
+		t.Fatalf("search error = %v", err)
+	}
+	_, rest, _ := strings.Cut(out, `"id": "`)
+	threadID, _, _ := strings.Cut(rest, `"`)
+
+	vault := t.TempDir()
+	out, err = runCLI(t, "--api-endpoint", endpoint, "--no-auth", "--format", "markdown",
+		"download", "-o", filepath.Join(vault, "att"), "--link-base", vault, threadID)
+	if err != nil {
+		t.Fatalf("download error = %v", err)
+	}
+	mustContain(t, out, "](att/factors.csv)")
+
+	if _, err := runCLI(t, "--api-endpoint", endpoint, "--no-auth",
+		"download", "--no-attachments", "--link-base", vault, threadID); err == nil {
+		t.Error("download --link-base without --format markdown succeeded, want error")
+	}
+}
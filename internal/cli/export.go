@@ -0,0 +1,159 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/bentsolheim/gmail-cli/internal/export"
+	"github.com/bentsolheim/gmail-cli/internal/gmail"
+	"github.com/spf13/cobra"
+)
+
+// Export formats
+const (
+	exportFormatMbox    = "mbox"
+	exportFormatMaildir = "maildir"
+)
+
+var (
+	exportFormat      string
+	exportConcurrency int
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export <query> [file|dir]",
+	Short: "Export messages to a local mailbox",
+	Long: `Export every message matching a Gmail query to a local mailbox.
+
+Messages are fetched in their original form and written as they arrive, so
+memory use stays flat for large exports.
+
+Mailbox formats, selected with --mailbox-format:
+  mbox     An mboxrd file, written to [file] or stdout
+  maildir  A Maildir at <dir>. Messages go into cur/ with flags from their
+           labels: S unless UNREAD, F for STARRED, D for DRAFT, T for TRASH.
+           Exported message IDs are kept in a manifest in <dir>, so running
+           the same export again only fetches new messages.
+
+Examples:
+  gmail-cli export "label:projects/conversion" conversion.mbox
+  gmail-cli export "from:felipe after:2025/01/01" > felipe.mbox
+  gmail-cli export --mailbox-format maildir "label:work" ~/Mail/work`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: runExport,
+}
+
+func init() {
+	exportCmd.Flags().StringVar(&exportFormat, "mailbox-format", exportFormatMbox, "Mailbox format: mbox or maildir")
+	exportCmd.Flags().IntVar(&exportConcurrency, "concurrency", gmail.DefaultConcurrency, "Number of messages to fetch in parallel")
+	rootCmd.AddCommand(exportCmd)
+}
+
+func runExport(cmd *cobra.Command, args []string) error {
+	query := args[0]
+	target := ""
+	if len(args) > 1 {
+		target = args[1]
+	}
+
+	switch exportFormat {
+	case exportFormatMbox:
+	case exportFormatMaildir:
+		if target == "" {
+			return fmt.Errorf("maildir export requires a directory")
+		}
+	default:
+		return fmt.Errorf("unknown mailbox format %q (valid: %s, %s)", exportFormat, exportFormatMbox, exportFormatMaildir)
+	}
+
+	ctx := context.Background()
+	client, err := gmail.NewClient(ctx, clientOptions())
+	if err != nil {
+		return fmt.Errorf("failed to create Gmail client: %w", err)
+	}
+
+	if exportFormat == exportFormatMaildir {
+		return exportMaildir(ctx, client, query, target)
+	}
+	return exportMbox(ctx, client, query, target)
+}
+
+// exportMbox writes the messages matching query to an mbox file, or stdout if
+// path is empty or "-". The file is only put in place once the export succeeds.
+func exportMbox(ctx context.Context, client *gmail.Client, query, path string) error {
+	var out io.Writer = os.Stdout
+	var file *os.File
+	if path != "" && path != "-" {
+		var err error
+		file, err = os.CreateTemp(filepath.Dir(path), ".export-*.mbox")
+		if err != nil {
+			return fmt.Errorf("failed to create mbox file: %w", err)
+		}
+		defer os.Remove(file.Name())
+		defer file.Close()
+		out = file
+	}
+
+	mbox := export.NewMboxWriter(out)
+	count := 0
+	err := client.ForEachRawMessage(ctx, query, gmail.RawMessageOptions{Concurrency: exportConcurrency}, func(msg *gmail.RawMessage) error {
+		count++
+		return mbox.WriteMessage(msg)
+	})
+	if err != nil {
+		return fmt.Errorf("export failed after %d messages: %w", count, err)
+	}
+	if err := mbox.Flush(); err != nil {
+		return fmt.Errorf("failed to write mbox: %w", err)
+	}
+
+	if file != nil {
+		if err := file.Close(); err != nil {
+			return fmt.Errorf("failed to write mbox: %w", err)
+		}
+		if err := os.Rename(file.Name(), path); err != nil {
+			return fmt.Errorf("failed to save mbox: %w", err)
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "Exported %d messages\n", count)
+	return nil
+}
+
+// exportMaildir delivers the messages matching query into the Maildir at dir,
+// skipping messages exported there before.
+func exportMaildir(ctx context.Context, client *gmail.Client, query, dir string) error {
+	maildir, err := export.OpenMaildir(dir)
+	if err != nil {
+		return err
+	}
+	defer maildir.Close()
+
+	previous := maildir.Count()
+	opts := gmail.RawMessageOptions{
+		Concurrency: exportConcurrency,
+		Skip:        maildir.Exported,
+	}
+
+	count := 0
+	err = client.ForEachRawMessage(ctx, query, opts, func(msg *gmail.RawMessage) error {
+		if _, err := maildir.WriteMessage(msg); err != nil {
+			return err
+		}
+		count++
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("export failed after %d new messages (rerun to resume): %w", count, err)
+	}
+
+	if err := maildir.Close(); err != nil {
+		return fmt.Errorf("failed to update manifest: %w", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "Exported %d new messages (%d previously exported)\n", count, previous)
+	return nil
+}
@@ -0,0 +1,30 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExport_MailboxFormat(t *testing.T) {
+	endpoint := setupCLI(t)
+	dir := filepath.Join(t.TempDir(), "work")
+
+	// The root --format is for output and doesn't select the mailbox format
+	if _, err := runCLI(t, "--api-endpoint", endpoint, "--no-auth", "--format", "json",
+		"export", "--mailbox-format", "maildir", "subject:conversion", dir); err != nil {
+		t.Fatalf("export error = %v", err)
+	}
+	entries, err := os.ReadDir(filepath.Join(dir, "cur"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Errorf("exported %d messages, want 3", len(entries))
+	}
+
+	if _, err := runCLI(t, "--api-endpoint", endpoint, "--no-auth",
+		"export", "--mailbox-format", "json", "subject:conversion", dir); err == nil {
+		t.Error("export --mailbox-format json succeeded, want error")
+	}
+}
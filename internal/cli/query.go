@@ -0,0 +1,42 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bentsolheim/gmail-cli/internal/query"
+	"github.com/spf13/cobra"
+)
+
+var queryCmd = &cobra.Command{
+	Use:   "query",
+	Short: "Inspect search queries",
+}
+
+var queryExplainCmd = &cobra.Command{
+	Use:   "explain <query>",
+	Short: "Print the parsed tree of a search query",
+	Long: `Print how a search query is parsed, as used by --offline, --archive and
+--imap with servers other than Gmail.
+
+Each line is a node: AND, OR and NOT with their terms indented below, or a
+term as operator:value. Words without an operator are shown as text:.
+
+Examples:
+  gmail-cli query explain "from:felipe (subject:conversion OR has:attachment)"
+  gmail-cli query explain "{label:work label:projects} -is:read newer_than:7d"`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		q, err := query.Parse(strings.Join(args, " "))
+		if err != nil {
+			return fmt.Errorf("invalid query: %w", err)
+		}
+		fmt.Print(q.Explain())
+		return nil
+	},
+}
+
+func init() {
+	queryCmd.AddCommand(queryExplainCmd)
+	rootCmd.AddCommand(queryCmd)
+}
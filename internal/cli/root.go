@@ -1,15 +1,47 @@
 package cli
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bentsolheim/gmail-cli/internal/backend"
+	"github.com/bentsolheim/gmail-cli/internal/config"
+	"github.com/bentsolheim/gmail-cli/internal/gmail"
+	"github.com/bentsolheim/gmail-cli/internal/output"
 	"github.com/bentsolheim/gmail-cli/pkg/version"
 	"github.com/spf13/cobra"
 )
 
+var (
+	verbose      bool
+	account      string
+	userID       string
+	outputFormat string
+	templateName string
+	timezone     string
+	archive      string
+	imapURL      string
+	apiEndpoint  string
+	noAuth       bool
+)
+
 var rootCmd = &cobra.Command{
 	Use:     "gmail-cli",
 	Short:   "A read-only Gmail CLI tool",
 	Long:    `gmail-cli is a read-only Gmail CLI tool designed for agent/LLM consumption. It enables searching Gmail, listing results, and downloading complete email threads with attachments.`,
 	Version: version.String(),
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		// The account comes first, as it may have its own settings
+		if err := selectAccount(); err != nil {
+			return err
+		}
+		return applySettings(cmd)
+	},
 }
 
 // Execute runs the root command.
@@ -17,6 +49,131 @@ func Execute() error {
 	return rootCmd.Execute()
 }
 
+// selectAccount activates the account from --account or GMAIL_CLI_ACCOUNT.
+func selectAccount() error {
+	name := account
+	if name == "" {
+		name = os.Getenv(config.AccountEnv)
+	}
+	if name == "" {
+		return nil
+	}
+	return config.SetAccount(name)
+}
+
+// clientOptions returns Gmail client options built from the global flags.
+func clientOptions() gmail.ClientOptions {
+	return gmail.ClientOptions{
+		Verbose:  verbose,
+		UserID:   userID,
+		Endpoint: apiEndpoint,
+		NoAuth:   noAuth,
+	}
+}
+
+// newBackend returns where threads are read from: the local mirror if
+// offline is set, the --archive mbox or Maildir, the --imap mailbox, or else
+// Gmail. Only flags given on the command line conflict; an imap.url from
+// config.yaml gives way to --offline, --local and --archive. Close it with
+// closeBackend.
+func newBackend(ctx context.Context, cmd *cobra.Command, offline bool) (backend.Backend, error) {
+	archiveSet := cmd.Flags().Changed("archive")
+	imapSet := cmd.Flags().Changed("imap")
+	switch {
+	case archiveSet && offline:
+		return nil, fmt.Errorf("--archive cannot be combined with --offline or --local")
+	case imapSet && (archiveSet || offline):
+		return nil, fmt.Errorf("--imap cannot be combined with --archive, --offline or --local")
+	case offline:
+		return openMirror()
+	case archive != "":
+		return backend.OpenLocal(archive)
+	case imapURL != "":
+		return openIMAP(ctx)
+	}
+
+	client, err := gmail.NewClient(ctx, clientOptions())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Gmail client: %w", err)
+	}
+	return client, nil
+}
+
+// openIMAP connects to the --imap mailbox with the password or token from
+// the environment.
+func openIMAP(ctx context.Context) (*backend.IMAP, error) {
+	opts := backend.IMAPOptions{
+		URL:      imapURL,
+		Password: os.Getenv(config.IMAPPasswordEnv),
+		Token:    os.Getenv(config.IMAPTokenEnv),
+	}
+	b, err := backend.OpenIMAP(ctx, opts)
+	if errors.Is(err, backend.ErrNoCredentials) {
+		return nil, fmt.Errorf("set %s, or %s to log in with OAuth", config.IMAPPasswordEnv, config.IMAPTokenEnv)
+	}
+	return b, err
+}
+
+// closeBackend closes backends that hold a connection, such as IMAP.
+func closeBackend(b backend.Backend) {
+	if c, ok := b.(io.Closer); ok {
+		c.Close()
+	}
+}
+
+// newFormatter returns the formatter selected with --template or --format,
+// showing dates in the --timezone zone.
+func newFormatter() (output.Formatter, error) {
+	var loc *time.Location
+	if timezone != "" {
+		var err error
+		loc, err = time.LoadLocation(timezone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --timezone: %w", err)
+		}
+	}
+
+	if templateName != "" {
+		formatter, err := output.LoadTemplate(config.TemplatePath(templateName))
+		if err != nil {
+			return nil, err
+		}
+		formatter.Location = loc
+		return formatter, nil
+	}
+
+	switch outputFormat {
+	case "text":
+		return &output.TextFormatter{Location: loc}, nil
+	case "json":
+		return &output.JSONFormatter{Location: loc}, nil
+	case "markdown":
+		return &output.MarkdownFormatter{Location: loc}, nil
+	default:
+		return nil, fmt.Errorf("unknown format %q (valid: %s)", outputFormat, strings.Join(config.OutputFormats, ", "))
+	}
+}
+
+// printFormatted prints formatted output, returning any error the formatter
+// reported while rendering it.
+func printFormatted(formatter output.Formatter, out string) error {
+	if r, ok := formatter.(interface{ Err() error }); ok && r.Err() != nil {
+		return r.Err()
+	}
+	fmt.Print(out)
+	return nil
+}
+
 func init() {
 	rootCmd.SetVersionTemplate("{{.Name}} {{.Version}}\n")
+	rootCmd.PersistentFlags().StringVar(&account, "account", "", "Account profile to use (default: $GMAIL_CLI_ACCOUNT or the saved default)")
+	rootCmd.PersistentFlags().StringVar(&userID, "user", "", "Mailbox to read, e.g. a delegated or shared mailbox (default: your own)")
+	rootCmd.PersistentFlags().StringVar(&archive, "archive", "", "Read from a local mbox file or Maildir instead of Gmail")
+	rootCmd.PersistentFlags().StringVar(&imapURL, "imap", "", "Read from an IMAP mailbox instead of Gmail, e.g. imaps://me@imap.fastmail.com/INBOX")
+	rootCmd.PersistentFlags().StringVar(&apiEndpoint, "api-endpoint", "", "Gmail API endpoint, e.g. a local gmail-cli fake-server (default: Google's)")
+	rootCmd.PersistentFlags().BoolVar(&noAuth, "no-auth", false, "Send API requests without credentials; requires --api-endpoint")
+	rootCmd.PersistentFlags().StringVar(&outputFormat, "format", "text", "Output format: text, json or markdown")
+	rootCmd.PersistentFlags().StringVar(&templateName, "template", "", "Render output with a Go text/template file, or a named template from ~/.config/gmail-cli/templates/")
+	rootCmd.PersistentFlags().StringVar(&timezone, "timezone", "", "Time zone for displayed dates, e.g. Europe/Oslo (default: local)")
+	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Report retried API requests on stderr")
 }
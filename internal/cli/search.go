@@ -8,14 +8,21 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/bentsolheim/gmail-cli/internal/backend"
 	"github.com/bentsolheim/gmail-cli/internal/gmail"
+	"github.com/bentsolheim/gmail-cli/internal/mirror"
 	"github.com/bentsolheim/gmail-cli/internal/output"
 	"github.com/spf13/cobra"
 )
 
 var (
-	interactive bool
-	outputDir   string
+	interactive       bool
+	outputDir         string
+	searchLimit       string
+	searchPageToken   string
+	searchConcurrency int
+	searchOffline     bool
+	searchLocal       bool
 )
 
 var searchCmd = &cobra.Command{
@@ -27,8 +34,35 @@ Uses Gmail's search syntax. Examples:
   gmail-cli search "from:felipe subject:conversion"
   gmail-cli search "after:2025/12/01 has:attachment"
   gmail-cli search "is:unread from:me"
-
-With --interactive, prompts to select and download a thread after search.`,
+  gmail-cli search "label:important" --limit 100
+  gmail-cli search "label:important" --limit all
+
+Results are fetched in pages. When more results are available, a page token
+is printed after the results; pass it with --page-token to fetch the next page.
+
+With --interactive, prompts to select and download a thread after search.
+
+With --offline, searches the local mirror kept by 'gmail-cli sync' instead.
+Local searches support words and quoted phrases, from:, to:, cc:, bcc:,
+subject:, label:, is:, in:, has:attachment, filename:, after:, before:,
+newer_than:, older_than:, larger:, smaller:, OR, -term, (groups) and
+{OR groups}. Use 'gmail-cli query explain' to see how a query is parsed.
+
+With --local, ranks the threads in the local mirror by relevance to the
+query's words instead (BM25 over subject, body, participants and attachment
+names), and shows each result's score and a snippet with the matching words
+highlighted. Threads matching any of the words are returned, best first:
+  gmail-cli search --local "conversion factors pipeline"
+
+With --archive, searches a local mbox file or Maildir instead, such as one
+written by 'gmail-cli export', with the same syntax as --offline:
+  gmail-cli --archive conversion.mbox search "from:felipe"
+
+With --imap, searches an IMAP mailbox instead. Gmail over IMAP runs the query
+on the server as usual; other servers use the same syntax as --offline. The
+password is read from GMAIL_CLI_IMAP_PASSWORD, or an OAuth access token from
+GMAIL_CLI_IMAP_TOKEN:
+  gmail-cli --imap imaps://me@fastmail.com@imap.fastmail.com search "from:felipe"`,
 	Args: cobra.MinimumNArgs(1),
 	RunE: runSearch,
 }
@@ -36,6 +70,11 @@ With --interactive, prompts to select and download a thread after search.`,
 func init() {
 	searchCmd.Flags().BoolVarP(&interactive, "interactive", "i", false, "Interactive mode: select and download a thread after search")
 	searchCmd.Flags().StringVarP(&outputDir, "output-dir", "o", "", "Output directory for attachments (used with --interactive)")
+	searchCmd.Flags().StringVarP(&searchLimit, "limit", "n", "25", "Maximum number of results to return, or \"all\"")
+	searchCmd.Flags().StringVar(&searchPageToken, "page-token", "", "Page token from a previous search to continue from")
+	searchCmd.Flags().IntVar(&searchConcurrency, "concurrency", gmail.DefaultConcurrency, "Number of threads to fetch in parallel")
+	searchCmd.Flags().BoolVar(&searchOffline, "offline", false, "Search the local mirror instead of Gmail (see 'gmail-cli sync')")
+	searchCmd.Flags().BoolVar(&searchLocal, "local", false, "Rank threads in the local mirror by relevance to the query's words")
 	rootCmd.AddCommand(searchCmd)
 }
 
@@ -43,18 +82,48 @@ func runSearch(cmd *cobra.Command, args []string) error {
 	query := strings.Join(args, " ")
 	ctx := context.Background()
 
-	client, err := gmail.NewClient(ctx)
+	// Interactive prompts would corrupt machine-readable output
+	if interactive && (outputFormat != "text" || templateName != "") {
+		return fmt.Errorf("--interactive requires --format text and no --template")
+	}
+
+	limit, err := parseLimit(searchLimit)
 	if err != nil {
-		return fmt.Errorf("failed to create Gmail client: %w", err)
+		return err
 	}
 
-	results, err := client.SearchThreads(ctx, query, 25)
+	b, err := newBackend(ctx, cmd, searchOffline || searchLocal)
+	if err != nil {
+		return err
+	}
+	defer closeBackend(b)
+
+	var searchResult *gmail.SearchResult
+	switch {
+	case searchLocal && searchPageToken != "":
+		// Ranked results aren't paged, as with the other local backends
+		err = backend.ErrPageToken
+	case searchLocal:
+		searchResult, err = b.(*mirror.Store).RankedSearch(query, limit)
+	default:
+		searchResult, err = b.SearchThreads(ctx, query, gmail.SearchOptions{
+			Limit:       limit,
+			PageToken:   searchPageToken,
+			Concurrency: searchConcurrency,
+		})
+	}
 	if err != nil {
 		return fmt.Errorf("search failed: %w", err)
 	}
+	results := searchResult.Threads
 
-	formatter := output.NewTextFormatter()
-	fmt.Print(formatter.FormatSearchResults(results))
+	formatter, err := newFormatter()
+	if err != nil {
+		return err
+	}
+	if err := printFormatted(formatter, formatter.FormatSearchResults(searchResult)); err != nil {
+		return err
+	}
 
 	if !interactive || len(results) == 0 {
 		return nil
@@ -73,8 +142,22 @@ func runSearch(cmd *cobra.Command, args []string) error {
 
 	selectedThread := results[selection-1]
 
-	// Download the selected thread
-	return downloadThread(ctx, client, selectedThread.ID, outputDir, false)
+	// Download the selected thread. The mirror has no attachments.
+	return downloadThread(ctx, b, selectedThread.ID, outputDir, searchOffline || searchLocal)
+}
+
+// parseLimit parses the --limit flag. "all" means no limit and is returned as 0.
+func parseLimit(value string) (int64, error) {
+	if strings.EqualFold(value, "all") {
+		return 0, nil
+	}
+
+	limit, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || limit < 1 {
+		return 0, fmt.Errorf("invalid --limit %q: must be a positive number or \"all\"", value)
+	}
+
+	return limit, nil
 }
 
 func promptSelection(max int) (int, error) {
@@ -103,8 +186,8 @@ func promptSelection(max int) (int, error) {
 	}
 }
 
-func downloadThread(ctx context.Context, client *gmail.Client, threadID, outDir string, noAttachments bool) error {
-	thread, err := client.GetThread(ctx, threadID)
+func downloadThread(ctx context.Context, b backend.Backend, threadID, outDir string, noAttachments bool) error {
+	thread, err := b.GetThread(ctx, threadID)
 	if err != nil {
 		return fmt.Errorf("failed to get thread: %w", err)
 	}
@@ -131,7 +214,7 @@ func downloadThread(ctx context.Context, client *gmail.Client, threadID, outDir
 					break
 				}
 
-				data, err := client.DownloadAttachment(ctx, msg.ID, att.ID)
+				data, err := b.DownloadAttachment(ctx, msg.ID, att.ID)
 				if err != nil {
 					fmt.Fprintf(os.Stderr, "Warning: failed to download %s: %v\n", att.Filename, err)
 					continue
@@ -151,8 +234,9 @@ func downloadThread(ctx context.Context, client *gmail.Client, threadID, outDir
 		}
 	}
 
-	formatter := output.NewTextFormatter()
-	fmt.Print(formatter.FormatThread(thread, savedAttachments, output.FormatOptions{}))
-
-	return nil
+	formatter, err := newFormatter()
+	if err != nil {
+		return err
+	}
+	return printFormatted(formatter, formatter.FormatThread(thread, savedAttachments, output.FormatOptions{}))
 }
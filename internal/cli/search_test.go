@@ -0,0 +1,47 @@
+package cli
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bentsolheim/gmail-cli/internal/backend"
+)
+
+func TestSearch_OfflineWithConfiguredIMAP(t *testing.T) {
+	endpoint := setupCLI(t)
+	if _, err := runCLI(t, "--api-endpoint", endpoint, "--no-auth", "sync"); err != nil {
+		t.Fatalf("sync error = %v", err)
+	}
+	if _, err := runCLI(t, "config", "set", "imap.url", "imaps://me@imap.example.com"); err != nil {
+		t.Fatalf("config set error = %v", err)
+	}
+
+	// A configured mailbox gives way to --offline and --local
+	for _, flag := range []string{"--offline", "--local"} {
+		out, err := runCLI(t, "search", flag, "subject:conversion")
+		if err != nil {
+			t.Fatalf("search %s error = %v", flag, err)
+		}
+		mustContain(t, out, "Conversion factors")
+	}
+
+	// Giving --imap on the command line still conflicts
+	if _, err := runCLI(t, "--imap", "imaps://me@imap.example.com", "search", "--offline", "subject:conversion"); err == nil {
+		t.Error("search --imap --offline succeeded, want error")
+	}
+}
+
+func TestSearch_LocalPageToken(t *testing.T) {
+	endpoint := setupCLI(t)
+	if _, err := runCLI(t, "--api-endpoint", endpoint, "--no-auth", "sync"); err != nil {
+		t.Fatalf("sync error = %v", err)
+	}
+
+	// Ranked results aren't paged, so a page token is rejected like offline
+	for _, flag := range []string{"--offline", "--local"} {
+		_, err := runCLI(t, "search", flag, "--page-token", "bogus", "conversion")
+		if !errors.Is(err, backend.ErrPageToken) {
+			t.Errorf("search %s --page-token error = %v, want %v", flag, err, backend.ErrPageToken)
+		}
+	}
+}
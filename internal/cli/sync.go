@@ -0,0 +1,91 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/bentsolheim/gmail-cli/internal/config"
+	"github.com/bentsolheim/gmail-cli/internal/gmail"
+	"github.com/bentsolheim/gmail-cli/internal/mirror"
+	"github.com/spf13/cobra"
+)
+
+var (
+	syncFull        bool
+	syncConcurrency int
+)
+
+var syncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Sync the mailbox to a local mirror",
+	Long: `Keep a local mirror of the mailbox for offline reads.
+
+The first sync fetches every message. Later syncs only apply what changed
+since the last one (new and deleted messages and label changes), using the
+Gmail History API. If the last sync is too old for Gmail's history, the
+mailbox is synced in full again. An interrupted full sync resumes where it
+stopped.
+
+Each account and --user mailbox has its own mirror in
+~/.local/share/gmail-cli/mirror/. Attachments are not mirrored.
+
+Read from the mirror with --offline:
+  gmail-cli sync
+  gmail-cli search --offline "from:felipe subject:conversion"
+  gmail-cli download --offline 18c1234abcd5678`,
+	Args: cobra.NoArgs,
+	RunE: runSync,
+}
+
+func init() {
+	syncCmd.Flags().BoolVar(&syncFull, "full", false, "Sync the whole mailbox instead of only recent changes")
+	syncCmd.Flags().IntVar(&syncConcurrency, "concurrency", gmail.DefaultConcurrency, "Number of messages to fetch in parallel")
+	rootCmd.AddCommand(syncCmd)
+}
+
+func runSync(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	store, err := openMirror()
+	if err != nil {
+		return err
+	}
+
+	client, err := gmail.NewClient(ctx, clientOptions())
+	if err != nil {
+		return fmt.Errorf("failed to create Gmail client: %w", err)
+	}
+
+	result, err := store.Sync(ctx, client, mirror.SyncOptions{
+		Concurrency: syncConcurrency,
+		Full:        syncFull,
+	})
+	if err != nil {
+		return fmt.Errorf("sync failed (rerun to resume): %w", err)
+	}
+
+	kind := "Synced"
+	switch {
+	case result.Expired:
+		kind = "History expired, synced in full"
+	case result.Full:
+		kind = "Synced in full"
+	}
+	fmt.Fprintf(os.Stderr, "%s: %d added, %d deleted, %d updated (%d messages in mirror)\n",
+		kind, result.Added, result.Deleted, result.Updated, store.MessageCount())
+	return nil
+}
+
+// openMirror opens the local mirror of the --user mailbox for the active
+// account.
+func openMirror() (*mirror.Store, error) {
+	store, err := mirror.Open(config.MirrorDir(config.Account(), userID))
+	if err != nil {
+		return nil, err
+	}
+	if userID != gmail.DefaultUserID {
+		store.Mailbox = userID
+	}
+	return store, nil
+}
@@ -0,0 +1,117 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	// DefaultAccount is the account whose files live directly in the config directory.
+	DefaultAccount = "default"
+
+	// AccountEnv selects the account when --account is not given.
+	AccountEnv = "GMAIL_CLI_ACCOUNT"
+
+	accountsDir        = "accounts"
+	defaultAccountFile = "account"
+)
+
+var accountNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// account is the account selected with SetAccount, overriding the environment
+// and the saved default.
+var account string
+
+// SetAccount selects the account used by the path functions in this package.
+func SetAccount(name string) error {
+	if err := ValidateAccountName(name); err != nil {
+		return err
+	}
+	account = name
+	return nil
+}
+
+// ValidateAccountName checks that name can be used as an account directory.
+func ValidateAccountName(name string) error {
+	if !accountNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid account name %q: use letters, digits, '.', '_' and '-'", name)
+	}
+	return nil
+}
+
+// Account returns the active account name. The account is taken from
+// SetAccount, then GMAIL_CLI_ACCOUNT, then the saved default account.
+func Account() string {
+	if account != "" {
+		return account
+	}
+	if name := os.Getenv(AccountEnv); name != "" && ValidateAccountName(name) == nil {
+		return name
+	}
+	return SavedDefaultAccount()
+}
+
+// SavedDefaultAccount returns the account saved with SetDefaultAccount,
+// or DefaultAccount if none is saved.
+func SavedDefaultAccount() string {
+	b, err := os.ReadFile(filepath.Join(ConfigDir(), defaultAccountFile))
+	if err != nil {
+		return DefaultAccount
+	}
+	if name := strings.TrimSpace(string(b)); ValidateAccountName(name) == nil {
+		return name
+	}
+	return DefaultAccount
+}
+
+// SetDefaultAccount saves the account used when no account is selected.
+func SetDefaultAccount(name string) error {
+	if err := ValidateAccountName(name); err != nil {
+		return err
+	}
+	if err := EnsureConfigDir(); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(ConfigDir(), defaultAccountFile), []byte(name+"\n"), 0600)
+}
+
+// AccountDir returns the directory holding an account's files.
+// The default account uses the config directory itself.
+func AccountDir(name string) string {
+	if name == "" || name == DefaultAccount {
+		return ConfigDir()
+	}
+	return filepath.Join(ConfigDir(), accountsDir, name)
+}
+
+// Accounts returns the names of all accounts with a directory or a token,
+// sorted, always including the default account.
+func Accounts() ([]string, error) {
+	names := []string{DefaultAccount}
+
+	entries, err := os.ReadDir(filepath.Join(ConfigDir(), accountsDir))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() && ValidateAccountName(entry.Name()) == nil && entry.Name() != DefaultAccount {
+			names = append(names, entry.Name())
+		}
+	}
+
+	sort.Strings(names[1:])
+	return names, nil
+}
+
+// HasOwnCredentials reports whether an account has its own credentials file.
+func HasOwnCredentials(name string) bool {
+	if name == "" || name == DefaultAccount {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(AccountDir(name), credentialsFile))
+	return err == nil
+}
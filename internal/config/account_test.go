@@ -0,0 +1,89 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAccountPaths(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv(AccountEnv, "")
+	t.Cleanup(func() { account = "" })
+	configDir := filepath.Join(dir, appName)
+
+	// Default account keeps its files in the config directory
+	if got, want := TokenPath(), filepath.Join(configDir, "token.json"); got != want {
+		t.Errorf("TokenPath() = %q, want %q", got, want)
+	}
+
+	// Environment selects an account
+	t.Setenv(AccountEnv, "personal")
+	if got, want := TokenPath(), filepath.Join(configDir, "accounts", "personal", "token.json"); got != want {
+		t.Errorf("TokenPath() = %q, want %q", got, want)
+	}
+
+	// SetAccount overrides the environment
+	if err := SetAccount("work"); err != nil {
+		t.Fatal(err)
+	}
+	workDir := filepath.Join(configDir, "accounts", "work")
+	if got, want := TokenPath(), filepath.Join(workDir, "token.json"); got != want {
+		t.Errorf("TokenPath() = %q, want %q", got, want)
+	}
+
+	// Credentials are shared until the account has its own
+	if got, want := CredentialsPath(), filepath.Join(configDir, "credentials.json"); got != want {
+		t.Errorf("CredentialsPath() = %q, want %q", got, want)
+	}
+	if err := os.MkdirAll(workDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(workDir, "credentials.json"), []byte("{}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := CredentialsPath(), filepath.Join(workDir, "credentials.json"); got != want {
+		t.Errorf("CredentialsPath() = %q, want %q", got, want)
+	}
+}
+
+func TestSavedDefaultAccount(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv(AccountEnv, "")
+
+	if got := Account(); got != DefaultAccount {
+		t.Errorf("Account() = %q, want %q", got, DefaultAccount)
+	}
+
+	if err := SetDefaultAccount("work"); err != nil {
+		t.Fatalf("SetDefaultAccount() error = %v", err)
+	}
+	if got := Account(); got != "work" {
+		t.Errorf("Account() = %q, want work", got)
+	}
+
+	if err := os.MkdirAll(AccountDir("personal"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	names, err := Accounts()
+	if err != nil {
+		t.Fatalf("Accounts() error = %v", err)
+	}
+	if len(names) != 2 || names[0] != DefaultAccount || names[1] != "personal" {
+		t.Errorf("Accounts() = %v, want [default personal]", names)
+	}
+}
+
+func TestValidateAccountName(t *testing.T) {
+	for _, name := range []string{"work", "my.account", "team-2", "a_b"} {
+		if err := ValidateAccountName(name); err != nil {
+			t.Errorf("ValidateAccountName(%q) error = %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "..", "../etc", "a/b", ".hidden", "with space"} {
+		if err := ValidateAccountName(name); err == nil {
+			t.Errorf("ValidateAccountName(%q) should fail", name)
+		}
+	}
+}
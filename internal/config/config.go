@@ -3,14 +3,39 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 const (
-	appName          = "gmail-cli"
-	credentialsFile  = "credentials.json"
-	tokenFile        = "token.json"
+	appName            = "gmail-cli"
+	credentialsFile    = "credentials.json"
+	tokenFile          = "token.json"
+	encryptedTokenFile = "token.json.enc"
+	subjectFile        = "subject"
+	templatesDir       = "templates"
 )
 
+// Token store backends
+const (
+	TokenStoreFile      = "file"
+	TokenStoreEncrypted = "encrypted"
+	TokenStoreKeyring   = "keyring"
+)
+
+const (
+	// TokenStoreEnv selects the token store backend.
+	TokenStoreEnv = "GMAIL_CLI_TOKEN_STORE"
+	// TokenPassphraseEnv holds the passphrase for the encrypted token store.
+	TokenPassphraseEnv = "GMAIL_CLI_TOKEN_PASSPHRASE"
+	// SubjectEnv holds the user a service account impersonates.
+	SubjectEnv = "GMAIL_CLI_SUBJECT"
+)
+
+// AppName returns the application name.
+func AppName() string {
+	return appName
+}
+
 // ConfigDir returns the path to the config directory.
 // Uses XDG_CONFIG_HOME if set, otherwise ~/.config/gmail-cli/
 func ConfigDir() string {
@@ -24,17 +49,103 @@ func ConfigDir() string {
 	return filepath.Join(home, ".config", appName)
 }
 
+// DataDir returns the path to the data directory for local mail.
+// Uses XDG_DATA_HOME if set, otherwise ~/.local/share/gmail-cli/
+func DataDir() string {
+	if xdgData := os.Getenv("XDG_DATA_HOME"); xdgData != "" {
+		return filepath.Join(xdgData, appName)
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(".", appName, "data")
+	}
+	return filepath.Join(home, ".local", "share", appName)
+}
+
+// MirrorDir returns the directory of the local mirror of a mailbox for an
+// account. An empty mailbox is the account's own.
+func MirrorDir(account, mailbox string) string {
+	if mailbox == "" {
+		mailbox = "me"
+	}
+	return filepath.Join(DataDir(), "mirror", account, mailbox)
+}
+
 // EnsureConfigDir creates the config directory if it doesn't exist.
 func EnsureConfigDir() error {
 	return os.MkdirAll(ConfigDir(), 0700)
 }
 
-// CredentialsPath returns the path to the OAuth credentials file.
+// CredentialsPath returns the path to the OAuth credentials file for the active account.
+// Accounts without their own credentials.json share the one in the config directory.
 func CredentialsPath() string {
+	if name := Account(); HasOwnCredentials(name) {
+		return filepath.Join(AccountDir(name), credentialsFile)
+	}
 	return filepath.Join(ConfigDir(), credentialsFile)
 }
 
-// TokenPath returns the path to the stored OAuth token.
+// TemplatePath resolves a --template value: a path to a template file, or
+// the name of a template in ~/.config/gmail-cli/templates/, with or without
+// the .tmpl extension.
+func TemplatePath(name string) string {
+	if strings.ContainsRune(name, os.PathSeparator) || strings.Contains(name, "/") {
+		return name
+	}
+	if _, err := os.Stat(name); err == nil {
+		return name
+	}
+	if filepath.Ext(name) == "" {
+		name += ".tmpl"
+	}
+	return filepath.Join(ConfigDir(), templatesDir, name)
+}
+
+// TokenPath returns the path to the stored OAuth token for the active account.
 func TokenPath() string {
-	return filepath.Join(ConfigDir(), tokenFile)
+	return AccountTokenPath(Account())
+}
+
+// AccountTokenPath returns the path to the stored OAuth token for an account.
+func AccountTokenPath(name string) string {
+	return filepath.Join(AccountDir(name), tokenFile)
+}
+
+// AccountEncryptedTokenPath returns the path to the encrypted OAuth token for an account.
+func AccountEncryptedTokenPath(name string) string {
+	return filepath.Join(AccountDir(name), encryptedTokenFile)
+}
+
+// TokenStoreBackend returns the token store backend from GMAIL_CLI_TOKEN_STORE
+// or the token_store setting. Defaults to TokenStoreFile.
+func TokenStoreBackend() string {
+	if backend := os.Getenv(TokenStoreEnv); backend != "" {
+		return backend
+	}
+	if backend := LookupString("token_store"); backend != "" {
+		return backend
+	}
+	return TokenStoreFile
+}
+
+// ServiceAccountSubject returns the user a service account impersonates for
+// the active account, from GMAIL_CLI_SUBJECT or the saved subject.
+func ServiceAccountSubject() string {
+	if subject := os.Getenv(SubjectEnv); subject != "" {
+		return subject
+	}
+	b, err := os.ReadFile(filepath.Join(AccountDir(Account()), subjectFile))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+// SetServiceAccountSubject saves the user a service account impersonates for the active account.
+func SetServiceAccountSubject(subject string) error {
+	dir := AccountDir(Account())
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, subjectFile), []byte(subject+"\n"), 0600)
 }
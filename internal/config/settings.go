@@ -0,0 +1,431 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const settingsFile = "config.yaml"
+
+// Sources of a setting's effective value
+const (
+	SourceDefault = "default"
+	SourceFile    = "config file"
+	// SourceAccountFile is the active account's own config.yaml.
+	SourceAccountFile = "account config file"
+	SourceEnv         = "environment"
+)
+
+// IMAP credentials are only read from the environment, never config.yaml.
+const (
+	IMAPPasswordEnv = "GMAIL_CLI_IMAP_PASSWORD"
+	// IMAPTokenEnv holds an OAuth 2 access token, used instead of a password.
+	IMAPTokenEnv = "GMAIL_CLI_IMAP_TOKEN"
+)
+
+// OutputFormats lists the valid values of the output.format setting.
+var OutputFormats = []string{"text", "json", "markdown"}
+
+// settingKind determines how a setting is validated and written to config.yaml.
+type settingKind int
+
+const (
+	kindString settingKind = iota
+	kindInt
+	kindBool
+	kindList
+)
+
+// Setting describes a key that can be set in config.yaml.
+// Keys are dotted paths into the YAML document, e.g. "search.limit".
+type Setting struct {
+	Key         string
+	Description string
+	// Default is the built-in value, empty if there is none.
+	Default string
+
+	kind     settingKind
+	validate func(value string) error
+}
+
+// List reports whether the setting holds several values.
+func (s Setting) List() bool {
+	return s.kind == kindList
+}
+
+// EnvVar returns the environment variable that overrides the setting,
+// e.g. GMAIL_CLI_SEARCH_LIMIT for search.limit.
+// List values are separated by newlines.
+func (s Setting) EnvVar() string {
+	return "GMAIL_CLI_" + strings.ToUpper(strings.ReplaceAll(s.Key, ".", "_"))
+}
+
+var settings = []Setting{
+	{Key: "user", Description: "Mailbox to read, e.g. a delegated or shared mailbox", kind: kindString},
+	{Key: "imap.url", Description: "IMAP mailbox to read instead of Gmail, e.g. imaps://me@imap.fastmail.com/INBOX", kind: kindString, validate: validateIMAPURL},
+	{Key: "api.endpoint", Description: "Gmail API endpoint, e.g. http://127.0.0.1:8025/ for gmail-cli fake-server (default: Google's)", kind: kindString, validate: validateEndpoint},
+	{Key: "api.no_auth", Description: "Send API requests without credentials, only with api.endpoint", Default: "false", kind: kindBool},
+	{Key: "token_store", Description: "Token store backend: file, encrypted or keyring", Default: TokenStoreFile, kind: kindString, validate: validateTokenStore},
+	{Key: "search.limit", Description: "Maximum number of search results, or \"all\"", Default: "25", kind: kindString, validate: validateLimit},
+	{Key: "search.concurrency", Description: "Number of threads to fetch in parallel", Default: "8", kind: kindInt, validate: validatePositive},
+	{Key: "download.output_dir", Description: "Directory to save attachments", kind: kindString},
+	{Key: "download.messages_only", Description: "Strip quoted content from messages", Default: "false", kind: kindBool},
+	{Key: "download.reverse", Description: "Display messages newest first", Default: "false", kind: kindBool},
+	{Key: "output.format", Description: "Output format: text, json or markdown", Default: "text", kind: kindString, validate: validateFormat},
+	{Key: "output.template", Description: "Template file, or the name of a template in the templates directory", kind: kindString},
+	{Key: "output.timezone", Description: "Time zone for displayed dates, e.g. Europe/Oslo (default: local)", kind: kindString, validate: validateTimezone},
+	{Key: "output.quote_patterns", Description: "Extra regular expressions marking the start of quoted content", kind: kindList, validate: validateRegexp},
+}
+
+// Settings returns all known settings.
+func Settings() []Setting {
+	return slices.Clone(settings)
+}
+
+// FindSetting returns the setting with the given key.
+func FindSetting(key string) (Setting, error) {
+	for _, s := range settings {
+		if s.Key == key {
+			return s, nil
+		}
+	}
+
+	keys := make([]string, len(settings))
+	for i, s := range settings {
+		keys[i] = s.Key
+	}
+	return Setting{}, fmt.Errorf("unknown setting %q (valid: %s)", key, strings.Join(keys, ", "))
+}
+
+// SettingsPath returns the path to the active account's config.yaml, which
+// SetSetting and UnsetSetting change. For the default account it is the
+// global config.yaml.
+func SettingsPath() string {
+	return filepath.Join(AccountDir(Account()), settingsFile)
+}
+
+// GlobalSettingsPath returns the path to the config.yaml shared by all
+// accounts.
+func GlobalSettingsPath() string {
+	return filepath.Join(ConfigDir(), settingsFile)
+}
+
+// Lookup returns the effective value of a setting and where it came from.
+// The environment overrides the active account's config.yaml, which
+// overrides the global config.yaml, which overrides the built-in default.
+// Scalar settings have at most one value.
+func Lookup(key string) ([]string, string, error) {
+	setting, err := FindSetting(key)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if env, ok := os.LookupEnv(setting.EnvVar()); ok && env != "" {
+		values := []string{env}
+		if setting.List() {
+			values = splitLines(env)
+		}
+		if err := setting.check(values); err != nil {
+			return nil, "", fmt.Errorf("invalid %s: %w", setting.EnvVar(), err)
+		}
+		return values, SourceEnv, nil
+	}
+
+	if path := SettingsPath(); path != GlobalSettingsPath() {
+		file, err := loadSettings(path)
+		if err != nil {
+			return nil, "", err
+		}
+		if values, ok := file[key]; ok {
+			return values, SourceAccountFile, nil
+		}
+	}
+
+	file, err := loadSettings(GlobalSettingsPath())
+	if err != nil {
+		return nil, "", err
+	}
+	if values, ok := file[key]; ok {
+		return values, SourceFile, nil
+	}
+
+	if setting.Default == "" {
+		return nil, SourceDefault, nil
+	}
+	return []string{setting.Default}, SourceDefault, nil
+}
+
+// LookupString returns the effective value of a scalar setting, or "" if it
+// has none. Errors are ignored; they are reported when the CLI starts.
+func LookupString(key string) string {
+	values, _, err := Lookup(key)
+	if err != nil || len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// SetSetting validates values and saves them to the active account's
+// config.yaml.
+func SetSetting(key string, values []string) error {
+	setting, err := FindSetting(key)
+	if err != nil {
+		return err
+	}
+	if !setting.List() && len(values) != 1 {
+		return fmt.Errorf("%s takes exactly one value", key)
+	}
+	if err := setting.check(values); err != nil {
+		return fmt.Errorf("invalid %s: %w", key, err)
+	}
+
+	path := SettingsPath()
+	file, err := loadSettings(path)
+	if err != nil {
+		return err
+	}
+	file[key] = values
+	return saveSettings(path, file)
+}
+
+// UnsetSetting removes a setting from the active account's config.yaml.
+func UnsetSetting(key string) error {
+	if _, err := FindSetting(key); err != nil {
+		return err
+	}
+
+	path := SettingsPath()
+	file, err := loadSettings(path)
+	if err != nil {
+		return err
+	}
+	delete(file, key)
+	return saveSettings(path, file)
+}
+
+// check validates each value of the setting.
+func (s Setting) check(values []string) error {
+	for _, v := range values {
+		switch s.kind {
+		case kindInt:
+			if _, err := strconv.Atoi(v); err != nil {
+				return fmt.Errorf("%q is not a number", v)
+			}
+		case kindBool:
+			if _, err := strconv.ParseBool(v); err != nil {
+				return fmt.Errorf("%q is not true or false", v)
+			}
+		}
+		if s.validate != nil {
+			if err := s.validate(v); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// loadSettings reads a config.yaml into a map of dotted keys to values.
+// A missing file has no settings.
+func loadSettings(path string) (map[string][]string, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string][]string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var doc map[string]any
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	values := make(map[string][]string)
+	if err := flattenSettings("", doc, values); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", path, err)
+	}
+	return values, nil
+}
+
+// flattenSettings walks the YAML document, collecting values by dotted key.
+func flattenSettings(prefix string, doc map[string]any, values map[string][]string) error {
+	for name, node := range doc {
+		key := prefix + name
+
+		if nested, ok := node.(map[string]any); ok {
+			if err := flattenSettings(key+".", nested, values); err != nil {
+				return err
+			}
+			continue
+		}
+
+		setting, err := FindSetting(key)
+		if err != nil {
+			return err
+		}
+
+		var v []string
+		switch node := node.(type) {
+		case nil:
+			continue
+		case []any:
+			if !setting.List() {
+				return fmt.Errorf("%s takes a single value", key)
+			}
+			for _, item := range node {
+				v = append(v, fmt.Sprint(item))
+			}
+		default:
+			v = []string{fmt.Sprint(node)}
+		}
+
+		if err := setting.check(v); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		values[key] = v
+	}
+	return nil
+}
+
+// saveSettings writes the settings to a config.yaml as nested YAML, using
+// native YAML types for numbers and booleans.
+func saveSettings(path string, values map[string][]string) error {
+	doc := make(map[string]any)
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		setting, err := FindSetting(key)
+		if err != nil {
+			return err
+		}
+
+		node := doc
+		parts := strings.Split(key, ".")
+		for _, part := range parts[:len(parts)-1] {
+			child, ok := node[part].(map[string]any)
+			if !ok {
+				child = make(map[string]any)
+				node[part] = child
+			}
+			node = child
+		}
+		node[parts[len(parts)-1]] = setting.yamlValue(values[key])
+	}
+
+	b, err := yaml.Marshal(doc)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	return os.WriteFile(path, b, 0600)
+}
+
+// yamlValue converts values to the YAML type of the setting.
+func (s Setting) yamlValue(values []string) any {
+	switch s.kind {
+	case kindList:
+		return values
+	case kindInt:
+		n, _ := strconv.Atoi(values[0])
+		return n
+	case kindBool:
+		b, _ := strconv.ParseBool(values[0])
+		return b
+	default:
+		return values[0]
+	}
+}
+
+// splitLines splits a newline-separated list, dropping empty lines.
+func splitLines(s string) []string {
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func validateTokenStore(value string) error {
+	switch value {
+	case TokenStoreFile, TokenStoreEncrypted, TokenStoreKeyring:
+		return nil
+	}
+	return fmt.Errorf("unknown token store %q (valid: %s, %s, %s)", value,
+		TokenStoreFile, TokenStoreEncrypted, TokenStoreKeyring)
+}
+
+func validateIMAPURL(value string) error {
+	u, err := url.Parse(value)
+	if err != nil {
+		return err
+	}
+	if (u.Scheme != "imaps" && u.Scheme != "imap") || u.Host == "" {
+		return fmt.Errorf("%q must be imaps://user@host[/mailbox] or imap://user@host[/mailbox]", value)
+	}
+	return nil
+}
+
+func validateEndpoint(value string) error {
+	u, err := url.Parse(value)
+	if err != nil {
+		return err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%q must be an http:// or https:// URL", value)
+	}
+	return nil
+}
+
+func validateLimit(value string) error {
+	if strings.EqualFold(value, "all") {
+		return nil
+	}
+	return validatePositive(value)
+}
+
+func validatePositive(value string) error {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return fmt.Errorf("%q must be a positive number", value)
+	}
+	return nil
+}
+
+func validateFormat(value string) error {
+	if !slices.Contains(OutputFormats, value) {
+		return fmt.Errorf("unknown format %q (valid: %s)", value, strings.Join(OutputFormats, ", "))
+	}
+	return nil
+}
+
+func validateTimezone(value string) error {
+	_, err := time.LoadLocation(value)
+	return err
+}
+
+func validateRegexp(value string) error {
+	_, err := regexp.Compile(value)
+	return err
+}
@@ -0,0 +1,224 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLookup_Precedence(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("GMAIL_CLI_SEARCH_LIMIT", "")
+
+	lookup := func() ([]string, string) {
+		t.Helper()
+		values, source, err := Lookup("search.limit")
+		if err != nil {
+			t.Fatalf("Lookup() error = %v", err)
+		}
+		return values, source
+	}
+
+	if values, source := lookup(); !slices.Equal(values, []string{"25"}) || source != SourceDefault {
+		t.Errorf("Lookup() = %v, %s; want [25], %s", values, source, SourceDefault)
+	}
+
+	if err := SetSetting("search.limit", []string{"100"}); err != nil {
+		t.Fatalf("SetSetting() error = %v", err)
+	}
+	if values, source := lookup(); !slices.Equal(values, []string{"100"}) || source != SourceFile {
+		t.Errorf("Lookup() = %v, %s; want [100], %s", values, source, SourceFile)
+	}
+
+	t.Setenv("GMAIL_CLI_SEARCH_LIMIT", "all")
+	if values, source := lookup(); !slices.Equal(values, []string{"all"}) || source != SourceEnv {
+		t.Errorf("Lookup() = %v, %s; want [all], %s", values, source, SourceEnv)
+	}
+
+	t.Setenv("GMAIL_CLI_SEARCH_LIMIT", "0")
+	if _, _, err := Lookup("search.limit"); err == nil {
+		t.Error("Lookup() should reject an invalid environment value")
+	}
+
+	t.Setenv("GMAIL_CLI_SEARCH_LIMIT", "")
+	if err := UnsetSetting("search.limit"); err != nil {
+		t.Fatalf("UnsetSetting() error = %v", err)
+	}
+	if _, source := lookup(); source != SourceDefault {
+		t.Errorf("source after unset = %s, want %s", source, SourceDefault)
+	}
+}
+
+func TestLookup_AccountSettings(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv(AccountEnv, "")
+	t.Setenv("GMAIL_CLI_SEARCH_LIMIT", "")
+	t.Cleanup(func() { account = "" })
+
+	// Global settings are written with the default account
+	for key, value := range map[string]string{"search.limit": "100", "output.format": "json"} {
+		if err := SetSetting(key, []string{value}); err != nil {
+			t.Fatalf("SetSetting(%s) error = %v", key, err)
+		}
+	}
+
+	if err := SetAccount("work"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := SettingsPath(), filepath.Join(dir, appName, "accounts", "work", "config.yaml"); got != want {
+		t.Errorf("SettingsPath() = %q, want %q", got, want)
+	}
+	if err := SetSetting("search.limit", []string{"5"}); err != nil {
+		t.Fatalf("SetSetting() error = %v", err)
+	}
+
+	tests := []struct {
+		key        string
+		want       string
+		wantSource string
+	}{
+		{key: "search.limit", want: "5", wantSource: SourceAccountFile},
+		{key: "output.format", want: "json", wantSource: SourceFile},
+		{key: "search.concurrency", want: "8", wantSource: SourceDefault},
+	}
+	for _, tt := range tests {
+		values, source, err := Lookup(tt.key)
+		if err != nil {
+			t.Fatalf("Lookup(%s) error = %v", tt.key, err)
+		}
+		if !slices.Equal(values, []string{tt.want}) || source != tt.wantSource {
+			t.Errorf("Lookup(%s) = %v, %s; want [%s], %s", tt.key, values, source, tt.want, tt.wantSource)
+		}
+	}
+
+	// Other accounts only see the global file
+	if err := SetAccount("personal"); err != nil {
+		t.Fatal(err)
+	}
+	if got := LookupString("search.limit"); got != "100" {
+		t.Errorf("LookupString(search.limit) for another account = %q, want 100", got)
+	}
+}
+
+func TestSetSetting_WritesNestedYAML(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	settings := map[string][]string{
+		"search.concurrency":     {"4"},
+		"download.messages_only": {"true"},
+		"output.quote_patterns":  {"^Le .+ a écrit :$", "^Am .+ schrieb"},
+	}
+	for key, values := range settings {
+		if err := SetSetting(key, values); err != nil {
+			t.Fatalf("SetSetting(%s) error = %v", key, err)
+		}
+	}
+
+	b, err := os.ReadFile(SettingsPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"search:\n    concurrency: 4\n", "messages_only: true\n", "- ^Am .+ schrieb\n"} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("config.yaml missing %q:\n%s", want, b)
+		}
+	}
+
+	for key, want := range settings {
+		got, source, err := Lookup(key)
+		if err != nil {
+			t.Fatalf("Lookup(%s) error = %v", key, err)
+		}
+		if !slices.Equal(got, want) || source != SourceFile {
+			t.Errorf("Lookup(%s) = %v, %s; want %v, %s", key, got, source, want, SourceFile)
+		}
+	}
+}
+
+func TestSetSetting_Invalid(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	tests := []struct {
+		key    string
+		values []string
+	}{
+		{"unknown.key", []string{"x"}},
+		{"search.limit", []string{"-1"}},
+		{"search.concurrency", []string{"many"}},
+		{"download.reverse", []string{"maybe"}},
+		{"output.format", []string{"xml"}},
+		{"output.timezone", []string{"Nowhere/Special"}},
+		{"output.quote_patterns", []string{"("}},
+		{"token_store", []string{"cloud"}},
+		{"imap.url", []string{"https://imap.example.com"}},
+		{"api.endpoint", []string{"127.0.0.1:8025"}},
+		{"search.limit", []string{"1", "2"}},
+	}
+
+	for _, tt := range tests {
+		if err := SetSetting(tt.key, tt.values); err == nil {
+			t.Errorf("SetSetting(%s, %v) should fail", tt.key, tt.values)
+		}
+	}
+
+	if _, err := os.Stat(SettingsPath()); !os.IsNotExist(err) {
+		t.Error("invalid settings should not create config.yaml")
+	}
+}
+
+func TestLookup_InvalidFile(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	if err := EnsureConfigDir(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, content := range []string{"search:\n  limt: 10\n", "search:\n  limit: [1, 2]\n", "download:\n  reverse: sometimes\n"} {
+		if err := os.WriteFile(SettingsPath(), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := Lookup("search.limit"); err == nil {
+			t.Errorf("Lookup() with config.yaml %q should fail", content)
+		}
+	}
+}
+
+func TestTokenStoreBackend_FromSettings(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv(TokenStoreEnv, "")
+
+	if got := TokenStoreBackend(); got != TokenStoreFile {
+		t.Errorf("TokenStoreBackend() = %q, want %q", got, TokenStoreFile)
+	}
+	if err := SetSetting("token_store", []string{TokenStoreKeyring}); err != nil {
+		t.Fatal(err)
+	}
+	if got := TokenStoreBackend(); got != TokenStoreKeyring {
+		t.Errorf("TokenStoreBackend() = %q, want %q", got, TokenStoreKeyring)
+	}
+	t.Setenv(TokenStoreEnv, TokenStoreEncrypted)
+	if got := TokenStoreBackend(); got != TokenStoreEncrypted {
+		t.Errorf("TokenStoreBackend() = %q, want %q", got, TokenStoreEncrypted)
+	}
+}
+
+func TestTemplatePath(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	templates := filepath.Join(dir, appName, "templates")
+
+	tests := map[string]string{
+		"brief":             filepath.Join(templates, "brief.tmpl"),
+		"brief.tmpl":        filepath.Join(templates, "brief.tmpl"),
+		"./brief.tmpl":      "./brief.tmpl",
+		"/srv/tmpl/a.tmpl":  "/srv/tmpl/a.tmpl",
+		"pipelines/ci.tmpl": "pipelines/ci.tmpl",
+	}
+	for name, want := range tests {
+		if got := TemplatePath(name); got != want {
+			t.Errorf("TemplatePath(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
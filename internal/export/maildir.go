@@ -0,0 +1,181 @@
+package export
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/bentsolheim/gmail-cli/internal/gmail"
+)
+
+// ManifestFile is the file in a Maildir listing the IDs of exported messages,
+// one per line.
+const ManifestFile = ".gmail-cli-manifest"
+
+// labelFlags maps Gmail system labels to Maildir flags. Messages without the
+// UNREAD label get the S (seen) flag.
+var labelFlags = map[string]byte{
+	"STARRED": 'F',
+	"DRAFT":   'D',
+	"TRASH":   'T',
+}
+
+// Maildir writes messages into a Maildir, keeping a manifest of exported
+// message IDs so later exports to the same directory only add new messages.
+// It is not safe for concurrent use.
+type Maildir struct {
+	dir      string
+	hostname string
+	exported map[string]bool
+	manifest *os.File
+	counter  int
+}
+
+// OpenMaildir creates the Maildir at dir if needed and loads its manifest.
+// Call Close when done.
+func OpenMaildir(dir string) (*Maildir, error) {
+	for _, sub := range []string{"tmp", "new", "cur"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0700); err != nil {
+			return nil, fmt.Errorf("failed to create maildir: %w", err)
+		}
+	}
+
+	exported, err := readManifest(filepath.Join(dir, ManifestFile))
+	if err != nil {
+		return nil, err
+	}
+
+	manifest, err := os.OpenFile(filepath.Join(dir, ManifestFile), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open manifest: %w", err)
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "localhost"
+	}
+
+	return &Maildir{
+		dir:      dir,
+		hostname: escapeHostname(hostname),
+		exported: exported,
+		manifest: manifest,
+	}, nil
+}
+
+// Exported reports whether a message was exported before.
+func (m *Maildir) Exported(messageID string) bool {
+	return m.exported[messageID]
+}
+
+// Count returns the number of messages in the manifest.
+func (m *Maildir) Count() int {
+	return len(m.exported)
+}
+
+// WriteMessage delivers a message into cur/ with flags from its labels and
+// records it in the manifest. It returns the path of the new file.
+func (m *Maildir) WriteMessage(msg *gmail.RawMessage) (string, error) {
+	// Unique name per the Maildir spec: time, a unique part and the host.
+	// The Gmail message ID makes it unique across runs.
+	m.counter++
+	name := fmt.Sprintf("%d.G%sQ%d.%s", msg.InternalDate.Unix(), msg.ID, m.counter, m.hostname)
+
+	// The message is synced before it's delivered, and the manifest after
+	// each append, so a crash never leaves an ID in the manifest without
+	// its message in cur/
+	tmpPath := filepath.Join(m.dir, "tmp", name)
+	if err := writeSynced(tmpPath, msg.Data); err != nil {
+		os.Remove(tmpPath)
+		return "", fmt.Errorf("failed to write message %s: %w", msg.ID, err)
+	}
+
+	curPath := filepath.Join(m.dir, "cur", name+":2,"+MaildirFlags(msg.LabelIDs))
+	if err := os.Rename(tmpPath, curPath); err != nil {
+		os.Remove(tmpPath)
+		return "", fmt.Errorf("failed to deliver message %s: %w", msg.ID, err)
+	}
+
+	if _, err := m.manifest.WriteString(msg.ID + "\n"); err != nil {
+		return "", fmt.Errorf("failed to update manifest: %w", err)
+	}
+	if err := m.manifest.Sync(); err != nil {
+		return "", fmt.Errorf("failed to sync manifest: %w", err)
+	}
+	m.exported[msg.ID] = true
+
+	return curPath, nil
+}
+
+// Close closes the manifest.
+func (m *Maildir) Close() error {
+	return m.manifest.Close()
+}
+
+// writeSynced writes data to a new file at path and flushes it to disk.
+func writeSynced(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// MaildirFlags returns the Maildir info flags for a message's Gmail labels,
+// in ASCII order as the spec requires.
+func MaildirFlags(labelIDs []string) string {
+	var flags []byte
+	if !slices.Contains(labelIDs, "UNREAD") {
+		flags = append(flags, 'S')
+	}
+	for _, label := range labelIDs {
+		if flag, ok := labelFlags[label]; ok {
+			flags = append(flags, flag)
+		}
+	}
+	slices.Sort(flags)
+	return string(slices.Compact(flags))
+}
+
+// readManifest reads the IDs in a manifest. A missing manifest is empty.
+func readManifest(path string) (map[string]bool, error) {
+	exported := make(map[string]bool)
+
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return exported, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if id := strings.TrimSpace(scanner.Text()); id != "" {
+			exported[id] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	return exported, nil
+}
+
+// escapeHostname replaces the characters the Maildir spec reserves in the
+// host part of a filename.
+func escapeHostname(hostname string) string {
+	return strings.NewReplacer("/", `\057`, ":", `\072`).Replace(hostname)
+}
@@ -0,0 +1,103 @@
+package export
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bentsolheim/gmail-cli/internal/gmail"
+)
+
+func TestMaildirFlags(t *testing.T) {
+	tests := []struct {
+		labels []string
+		want   string
+	}{
+		{nil, "S"},
+		{[]string{"INBOX", "UNREAD"}, ""},
+		{[]string{"INBOX", "STARRED"}, "FS"},
+		{[]string{"UNREAD", "STARRED", "IMPORTANT"}, "F"},
+		{[]string{"DRAFT"}, "DS"},
+		{[]string{"TRASH", "STARRED", "STARRED"}, "FST"},
+	}
+
+	for _, tt := range tests {
+		if got := MaildirFlags(tt.labels); got != tt.want {
+			t.Errorf("MaildirFlags(%v) = %q, want %q", tt.labels, got, tt.want)
+		}
+	}
+}
+
+func TestMaildir_Resume(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "work")
+	date := time.Date(2025, 12, 9, 10, 30, 0, 0, time.UTC)
+
+	maildir, err := OpenMaildir(dir)
+	if err != nil {
+		t.Fatalf("OpenMaildir() error = %v", err)
+	}
+	for _, sub := range []string{"tmp", "new", "cur"} {
+		if info, err := os.Stat(filepath.Join(dir, sub)); err != nil || !info.IsDir() {
+			t.Errorf("%s/ not created: %v", sub, err)
+		}
+	}
+
+	path, err := maildir.WriteMessage(&gmail.RawMessage{
+		ID:           "18c1",
+		LabelIDs:     []string{"INBOX", "UNREAD", "STARRED"},
+		InternalDate: date,
+		Data:         []byte("Subject: one\r\n\r\nBody\r\n"),
+	})
+	if err != nil {
+		t.Fatalf("WriteMessage() error = %v", err)
+	}
+	if filepath.Dir(path) != filepath.Join(dir, "cur") {
+		t.Errorf("message written to %s, want cur/", path)
+	}
+	name := filepath.Base(path)
+	if !strings.HasPrefix(name, "1765276200.G18c1Q1.") || !strings.HasSuffix(name, ":2,F") {
+		t.Errorf("filename = %q", name)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "Subject: one\r\n\r\nBody\r\n" {
+		t.Errorf("message content = %q, want the raw bytes unchanged", data)
+	}
+	if entries, _ := os.ReadDir(filepath.Join(dir, "tmp")); len(entries) != 0 {
+		t.Errorf("tmp/ should be empty, has %d entries", len(entries))
+	}
+	if err := maildir.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	// Reopening picks up the manifest
+	maildir, err = OpenMaildir(dir)
+	if err != nil {
+		t.Fatalf("OpenMaildir() error = %v", err)
+	}
+	defer maildir.Close()
+
+	if !maildir.Exported("18c1") || maildir.Exported("18c2") {
+		t.Errorf("Exported() should only report 18c1")
+	}
+	if _, err := maildir.WriteMessage(&gmail.RawMessage{ID: "18c2", InternalDate: date, Data: []byte("Subject: two\r\n\r\n")}); err != nil {
+		t.Fatalf("WriteMessage() error = %v", err)
+	}
+	if got := maildir.Count(); got != 2 {
+		t.Errorf("Count() = %d, want 2", got)
+	}
+
+	manifest, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(manifest) != "18c1\n18c2\n" {
+		t.Errorf("manifest = %q", manifest)
+	}
+}
+
+func TestEscapeHostname(t *testing.T) {
+	if got, want := escapeHostname("host:1/a"), `host\0721\057a`; got != want {
+		t.Errorf("escapeHostname() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,85 @@
+// Package export writes messages to local mailbox formats.
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net/mail"
+
+	"github.com/bentsolheim/gmail-cli/internal/gmail"
+)
+
+// mboxDateLayout is the asctime format of the date on an mbox "From " line.
+const mboxDateLayout = "Mon Jan _2 15:04:05 2006"
+
+// MboxWriter writes messages to an mbox file in the mboxrd format: each
+// message starts with a "From " separator line, and lines in the message
+// matching ">*From " get one more ">" so they can be unescaped exactly.
+type MboxWriter struct {
+	w *bufio.Writer
+}
+
+// NewMboxWriter returns a writer that appends messages to w.
+// Call Flush when done.
+func NewMboxWriter(w io.Writer) *MboxWriter {
+	return &MboxWriter{w: bufio.NewWriter(w)}
+}
+
+// WriteMessage appends a message. CRLF line endings are converted to LF,
+// and the message is followed by a blank line.
+func (m *MboxWriter) WriteMessage(msg *gmail.RawMessage) error {
+	m.w.WriteString("From ")
+	m.w.WriteString(envelopeSender(msg.Data))
+	m.w.WriteString(" ")
+	m.w.WriteString(msg.InternalDate.UTC().Format(mboxDateLayout))
+	m.w.WriteString("\n")
+
+	data := msg.Data
+	for len(data) > 0 {
+		line := data
+		rest := []byte(nil)
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			line, rest = data[:i], data[i+1:]
+		}
+		line = bytes.TrimSuffix(line, []byte("\r"))
+
+		if isFromLine(line) {
+			m.w.WriteByte('>')
+		}
+		m.w.Write(line)
+		m.w.WriteByte('\n')
+
+		data = rest
+	}
+
+	_, err := m.w.WriteString("\n")
+	return err
+}
+
+// Flush writes any buffered data to the underlying writer.
+func (m *MboxWriter) Flush() error {
+	return m.w.Flush()
+}
+
+// isFromLine reports whether line matches ">*From ", which mboxrd escapes.
+func isFromLine(line []byte) bool {
+	return bytes.HasPrefix(bytes.TrimLeft(line, ">"), []byte("From "))
+}
+
+// envelopeSender returns the address for the "From " line: the Return-Path,
+// or the From address, or MAILER-DAEMON if neither can be parsed.
+func envelopeSender(data []byte) string {
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		return "MAILER-DAEMON"
+	}
+
+	if addr, err := mail.ParseAddress(msg.Header.Get("Return-Path")); err == nil && addr.Address != "" {
+		return addr.Address
+	}
+	if addr, err := mail.ParseAddress(msg.Header.Get("From")); err == nil {
+		return addr.Address
+	}
+	return "MAILER-DAEMON"
+}
@@ -0,0 +1,71 @@
+package export
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bentsolheim/gmail-cli/internal/gmail"
+)
+
+func TestMboxWriter(t *testing.T) {
+	date := time.Date(2025, 12, 9, 10, 30, 5, 0, time.FixedZone("CET", 3600))
+
+	messages := []*gmail.RawMessage{
+		{
+			InternalDate: date,
+			Data: []byte("Return-Path: <bounce@lists.example.com>\r\n" +
+				"From: Felipe Garcia <felipe@example.com>\r\n" +
+				"Subject: Escaping\r\n" +
+				"\r\n" +
+				"From the top:\r\n" +
+				">From here\r\n" +
+				">>From there\r\n" +
+				" From indented\r\n" +
+				"Fromage"),
+		},
+		{
+			InternalDate: date.Add(24 * time.Hour),
+			Data:         []byte("From: you@example.com\n\nShort reply\n"),
+		},
+		{
+			InternalDate: date,
+			Data:         []byte("garbage without headers"),
+		},
+	}
+
+	var sb strings.Builder
+	w := NewMboxWriter(&sb)
+	for _, msg := range messages {
+		if err := w.WriteMessage(msg); err != nil {
+			t.Fatalf("WriteMessage() error = %v", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+
+	want := "From bounce@lists.example.com Tue Dec  9 09:30:05 2025\n" +
+		"Return-Path: <bounce@lists.example.com>\n" +
+		"From: Felipe Garcia <felipe@example.com>\n" +
+		"Subject: Escaping\n" +
+		"\n" +
+		">From the top:\n" +
+		">>From here\n" +
+		">>>From there\n" +
+		" From indented\n" +
+		"Fromage\n" +
+		"\n" +
+		"From you@example.com Wed Dec 10 09:30:05 2025\n" +
+		"From: you@example.com\n" +
+		"\n" +
+		"Short reply\n" +
+		"\n" +
+		"From MAILER-DAEMON Tue Dec  9 09:30:05 2025\n" +
+		"garbage without headers\n" +
+		"\n"
+
+	if got := sb.String(); got != want {
+		t.Errorf("mbox output =\n%s\nwant\n%s", got, want)
+	}
+}
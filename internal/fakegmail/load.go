@@ -0,0 +1,268 @@
+package fakegmail
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bentsolheim/gmail-cli/internal/gmail"
+	"github.com/bentsolheim/gmail-cli/internal/message"
+	gmailapi "google.golang.org/api/gmail/v1"
+)
+
+// LabelsHeader lists a fixture's labels, comma separated, as in Google
+// Takeout exports, e.g. "Inbox,Unread,Work/Projects". Messages without it
+// are in the inbox.
+const LabelsHeader = "X-Gmail-Labels"
+
+// systemLabels maps label names as written in LabelsHeader to the IDs of
+// Gmail's system labels.
+var systemLabels = map[string]string{
+	"inbox":     "INBOX",
+	"sent":      "SENT",
+	"draft":     "DRAFT",
+	"drafts":    "DRAFT",
+	"spam":      "SPAM",
+	"trash":     "TRASH",
+	"starred":   "STARRED",
+	"important": "IMPORTANT",
+	"unread":    "UNREAD",
+}
+
+// ignoredLabels are written by Takeout but aren't labels in the API.
+var ignoredLabels = []string{"opened", "archived"}
+
+// fakeMessage is a fixture with its Gmail API representation.
+type fakeMessage struct {
+	id       string
+	threadID string
+	data     []byte
+	date     time.Time
+	labelIDs []string
+	snippet  string
+	payload  *gmailapi.MessagePart
+	parsed   *message.Message
+}
+
+// fakeThread is a thread of fixtures, oldest first, and how the Gmail
+// client reads it, with label names, for evaluating queries.
+type fakeThread struct {
+	id       string
+	messages []*fakeMessage
+	parsed   *gmail.Thread
+}
+
+// latest returns when the newest message of the thread was received.
+func (t *fakeThread) latest() time.Time {
+	return t.messages[len(t.messages)-1].date
+}
+
+var headerDecoder = &mime.WordDecoder{}
+
+// load reads the .eml files in dir and groups them into threads.
+func (s *Server) load(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to read fixtures: %w", err)
+	}
+
+	var messages []*fakeMessage
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.EqualFold(filepath.Ext(entry.Name()), ".eml") {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		msg, err := s.loadMessage(path)
+		if err != nil {
+			return fmt.Errorf("failed to load %s: %w", path, err)
+		}
+		// Gmail keeps one copy of a message
+		if _, ok := s.messages[msg.id]; ok {
+			continue
+		}
+		s.messages[msg.id] = msg
+		messages = append(messages, msg)
+	}
+	if len(messages) == 0 {
+		return fmt.Errorf("no .eml files in %s", dir)
+	}
+
+	// A message joins the thread of a message it references, so replies
+	// are threaded whatever order the files are in.
+	slices.SortStableFunc(messages, func(a, b *fakeMessage) int { return a.date.Compare(b.date) })
+	byMessageID := make(map[string]*fakeThread)
+	for _, msg := range messages {
+		var thread *fakeThread
+		for _, ref := range slices.Concat(msg.parsed.References, msg.parsed.InReplyTo) {
+			if thread = byMessageID[ref]; thread != nil {
+				break
+			}
+		}
+		if thread == nil {
+			// Like Gmail, a thread's ID is the ID of its first message
+			thread = &fakeThread{id: msg.id}
+			s.threads[thread.id] = thread
+			s.threadOrder = append(s.threadOrder, thread)
+		}
+		msg.threadID = thread.id
+		thread.messages = append(thread.messages, msg)
+		if msg.parsed.MessageID != "" {
+			byMessageID[msg.parsed.MessageID] = thread
+		}
+	}
+
+	for _, thread := range s.threadOrder {
+		full := make([]*gmailapi.Message, len(thread.messages))
+		for i, msg := range thread.messages {
+			full[i] = s.apiMessage(msg, "full", nil)
+		}
+		thread.parsed = gmail.ParseThread(thread.id, full)
+		for i := range thread.parsed.Messages {
+			thread.parsed.Messages[i].Labels = s.labelNames(thread.parsed.Messages[i].Labels)
+		}
+		thread.parsed.Labels = s.labelNames(thread.parsed.Labels)
+	}
+
+	// Newest first, as Gmail lists them
+	slices.SortStableFunc(s.threadOrder, func(a, b *fakeThread) int { return b.latest().Compare(a.latest()) })
+	for _, thread := range s.threadOrder {
+		s.messageOrder = append(s.messageOrder, thread.messages...)
+	}
+	slices.SortStableFunc(s.messageOrder, func(a, b *fakeMessage) int { return b.date.Compare(a.date) })
+	return nil
+}
+
+// loadMessage reads a fixture. Messages without a valid Date header are
+// dated by the file's modification time.
+func (s *Server) loadMessage(path string) (*fakeMessage, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := message.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := message.Payload(data)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &fakeMessage{
+		id:      messageID(parsed, data),
+		data:    data,
+		date:    parsed.Date,
+		snippet: snippet(parsed.Body),
+		payload: payload,
+		parsed:  parsed,
+	}
+	if msg.date.IsZero() {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		msg.date = info.ModTime()
+	}
+
+	labels, err := s.parseLabels(parsed.Header.Get(LabelsHeader))
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s header: %w", LabelsHeader, err)
+	}
+	msg.labelIDs = labels
+	return msg, nil
+}
+
+// messageID returns a Gmail-style ID: 16 hex digits of a hash of the
+// Message-ID, or of the content if there is none.
+func messageID(msg *message.Message, data []byte) string {
+	key := data
+	if msg.MessageID != "" {
+		key = []byte(msg.MessageID)
+	}
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8])
+}
+
+// parseLabels returns the label IDs for a LabelsHeader value, creating user
+// labels as needed. An empty value means the inbox.
+func (s *Server) parseLabels(value string) ([]string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return []string{"INBOX"}, nil
+	}
+	if decoded, err := headerDecoder.DecodeHeader(value); err == nil {
+		value = decoded
+	}
+
+	// Takeout quotes names with commas
+	reader := csv.NewReader(strings.NewReader(value))
+	reader.TrimLeadingSpace = true
+	names, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		lower := strings.ToLower(name)
+		switch {
+		case name == "" || slices.Contains(ignoredLabels, lower):
+			continue
+		case systemLabels[lower] != "":
+			ids = append(ids, systemLabels[lower])
+		case strings.HasPrefix(lower, "category "):
+			ids = append(ids, "CATEGORY_"+strings.ToUpper(strings.TrimPrefix(lower, "category ")))
+		default:
+			ids = append(ids, s.userLabel(name))
+		}
+	}
+	slices.Sort(ids)
+	return slices.Compact(ids), nil
+}
+
+// userLabel returns the ID of a user label, creating it if it's new.
+func (s *Server) userLabel(name string) string {
+	for _, label := range s.labels {
+		if label.Type == "user" && strings.EqualFold(label.Name, name) {
+			return label.Id
+		}
+	}
+	id := fmt.Sprintf("Label_%d", len(s.labels)+1)
+	s.labels = append(s.labels, &gmailapi.Label{Id: id, Name: name, Type: "user"})
+	return id
+}
+
+// labelNames maps label IDs to names as the Gmail client does, keeping
+// system label IDs.
+func (s *Server) labelNames(ids []string) []string {
+	names := make([]string, len(ids))
+	for i, id := range ids {
+		names[i] = id
+		for _, label := range s.labels {
+			if label.Id == id {
+				names[i] = label.Name
+			}
+		}
+	}
+	slices.Sort(names)
+	return slices.Compact(names)
+}
+
+// snippet returns the start of a body with whitespace collapsed, like the
+// snippets Gmail returns.
+func snippet(body string) string {
+	const maxLen = 200
+	text := strings.Join(strings.Fields(body), " ")
+	if runes := []rune(text); len(runes) > maxLen {
+		return string(runes[:maxLen])
+	}
+	return text
+}
@@ -0,0 +1,367 @@
+// Package fakegmail serves a read-only subset of the Gmail API from a
+// directory of .eml files, so gmail-cli and the agents driving it can be
+// tested against realistic mail without network access or credentials.
+package fakegmail
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/bentsolheim/gmail-cli/internal/query"
+	gmailapi "google.golang.org/api/gmail/v1"
+)
+
+const (
+	// DefaultEmailAddress is the address of the fake mailbox.
+	DefaultEmailAddress = "me@example.com"
+
+	// historyID is the history ID of every message. Fixtures don't change,
+	// so there is never any history after it.
+	historyID = 1
+
+	defaultPageSize = 100
+	maxPageSize     = 500
+)
+
+// systemLabelIDs are the system labels that every mailbox has.
+var systemLabelIDs = []string{
+	"INBOX", "SENT", "DRAFT", "SPAM", "TRASH", "STARRED", "IMPORTANT", "UNREAD",
+	"CATEGORY_PERSONAL", "CATEGORY_SOCIAL", "CATEGORY_PROMOTIONS", "CATEGORY_UPDATES", "CATEGORY_FORUMS",
+}
+
+// Server is an http.Handler serving the Gmail API for fixtures. Use it as
+// the endpoint of a Gmail service, e.g. with option.WithEndpoint. Requests
+// aren't authenticated, and any user ID reads the same mailbox.
+//
+// It serves Threads.List and Get, Messages.List and Get, Attachments.Get,
+// Labels.List, GetProfile and History.List. Queries use Gmail's search
+// syntax as supported by package query. As in Gmail, lists leave out spam
+// and trash unless includeSpamTrash is set or the query asks for them.
+type Server struct {
+	// EmailAddress is the mailbox address returned by GetProfile.
+	EmailAddress string
+
+	mux          *http.ServeMux
+	messages     map[string]*fakeMessage
+	messageOrder []*fakeMessage
+	threads      map[string]*fakeThread
+	threadOrder  []*fakeThread
+	labels       []*gmailapi.Label
+}
+
+// New returns a server for the .eml files in dir. Labels are read from each
+// file's LabelsHeader; files that can't be parsed are an error.
+func New(dir string) (*Server, error) {
+	s := &Server{
+		EmailAddress: DefaultEmailAddress,
+		mux:          http.NewServeMux(),
+		messages:     make(map[string]*fakeMessage),
+		threads:      make(map[string]*fakeThread),
+	}
+	if err := s.load(dir); err != nil {
+		return nil, err
+	}
+
+	const prefix = "GET /gmail/v1/users/{user}/"
+	s.mux.HandleFunc(prefix+"profile", s.getProfile)
+	s.mux.HandleFunc(prefix+"labels", s.listLabels)
+	s.mux.HandleFunc(prefix+"history", s.listHistory)
+	s.mux.HandleFunc(prefix+"threads", s.listThreads)
+	s.mux.HandleFunc(prefix+"threads/{id}", s.getThread)
+	s.mux.HandleFunc(prefix+"messages", s.listMessages)
+	s.mux.HandleFunc(prefix+"messages/{id}", s.getMessage)
+	s.mux.HandleFunc(prefix+"messages/{message}/attachments/{id}", s.getAttachment)
+	return s, nil
+}
+
+// MessageCount returns the number of messages served.
+func (s *Server) MessageCount() int {
+	return len(s.messages)
+}
+
+// ThreadCount returns the number of threads served.
+func (s *Server) ThreadCount() int {
+	return len(s.threads)
+}
+
+// ServeHTTP serves a Gmail API request. Unknown paths get a 404 error in
+// the API's format.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if _, pattern := s.mux.Handler(r); pattern == "" {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) getProfile(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, &gmailapi.Profile{
+		EmailAddress:  s.EmailAddress,
+		MessagesTotal: int64(len(s.messages)),
+		ThreadsTotal:  int64(len(s.threads)),
+		HistoryId:     historyID,
+	})
+}
+
+func (s *Server) listLabels(w http.ResponseWriter, r *http.Request) {
+	var labels []*gmailapi.Label
+	for _, id := range systemLabelIDs {
+		labels = append(labels, &gmailapi.Label{Id: id, Name: id, Type: "system"})
+	}
+	writeJSON(w, &gmailapi.ListLabelsResponse{Labels: append(labels, s.labels...)})
+}
+
+func (s *Server) listHistory(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("startHistoryId") == "" {
+		writeError(w, http.StatusBadRequest, "Missing startHistoryId")
+		return
+	}
+	writeJSON(w, &gmailapi.ListHistoryResponse{HistoryId: historyID})
+}
+
+func (s *Server) listThreads(w http.ResponseWriter, r *http.Request) {
+	q, ok := parseQuery(w, r)
+	if !ok {
+		return
+	}
+	includeSpamTrash := r.URL.Query().Get("includeSpamTrash") == "true" || searchesSpamTrash(q.Root)
+
+	var threads []*gmailapi.Thread
+	for _, thread := range s.threadOrder {
+		if !q.Match(thread.parsed) {
+			continue
+		}
+		if !includeSpamTrash && !slices.ContainsFunc(thread.messages, visible) {
+			continue
+		}
+		threads = append(threads, &gmailapi.Thread{
+			Id:        thread.id,
+			Snippet:   thread.messages[len(thread.messages)-1].snippet,
+			HistoryId: historyID,
+		})
+	}
+
+	page, next, ok := paginate(w, r, threads)
+	if !ok {
+		return
+	}
+	writeJSON(w, &gmailapi.ListThreadsResponse{
+		Threads:            page,
+		NextPageToken:      next,
+		ResultSizeEstimate: int64(len(threads)),
+	})
+}
+
+func (s *Server) getThread(w http.ResponseWriter, r *http.Request) {
+	thread, ok := s.threads[r.PathValue("id")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Requested entity was not found.")
+		return
+	}
+	format, ok := messageFormat(w, r)
+	if !ok {
+		return
+	}
+	if format == "raw" {
+		writeError(w, http.StatusBadRequest, "Invalid format: raw is not supported for threads")
+		return
+	}
+
+	result := &gmailapi.Thread{Id: thread.id, HistoryId: historyID}
+	for _, msg := range thread.messages {
+		result.Messages = append(result.Messages, s.apiMessage(msg, format, r.URL.Query()["metadataHeaders"]))
+	}
+	writeJSON(w, result)
+}
+
+func (s *Server) listMessages(w http.ResponseWriter, r *http.Request) {
+	q, ok := parseQuery(w, r)
+	if !ok {
+		return
+	}
+	includeSpamTrash := r.URL.Query().Get("includeSpamTrash") == "true" || searchesSpamTrash(q.Root)
+
+	var messages []*gmailapi.Message
+	for _, msg := range s.messageOrder {
+		if !includeSpamTrash && !visible(msg) {
+			continue
+		}
+		thread := s.threads[msg.threadID]
+		parsed := &thread.parsed.Messages[slices.Index(thread.messages, msg)]
+		if !q.MatchMessage(thread.parsed, parsed) {
+			continue
+		}
+		messages = append(messages, &gmailapi.Message{Id: msg.id, ThreadId: msg.threadID})
+	}
+
+	page, next, ok := paginate(w, r, messages)
+	if !ok {
+		return
+	}
+	writeJSON(w, &gmailapi.ListMessagesResponse{
+		Messages:           page,
+		NextPageToken:      next,
+		ResultSizeEstimate: int64(len(messages)),
+	})
+}
+
+func (s *Server) getMessage(w http.ResponseWriter, r *http.Request) {
+	msg, ok := s.messages[r.PathValue("id")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Requested entity was not found.")
+		return
+	}
+	format, ok := messageFormat(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, s.apiMessage(msg, format, r.URL.Query()["metadataHeaders"]))
+}
+
+func (s *Server) getAttachment(w http.ResponseWriter, r *http.Request) {
+	msg, ok := s.messages[r.PathValue("message")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Requested entity was not found.")
+		return
+	}
+	att, ok := msg.parsed.Attachment(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "Requested entity was not found.")
+		return
+	}
+	writeJSON(w, &gmailapi.MessagePartBody{
+		AttachmentId: att.ID,
+		Size:         int64(len(att.Data)),
+		Data:         base64.URLEncoding.EncodeToString(att.Data),
+	})
+}
+
+// apiMessage returns a message in the given format. In metadata format,
+// only the top-level headers named in metadataHeaders are included, or all
+// of them if it's empty.
+func (s *Server) apiMessage(msg *fakeMessage, format string, metadataHeaders []string) *gmailapi.Message {
+	result := &gmailapi.Message{
+		Id:           msg.id,
+		ThreadId:     msg.threadID,
+		LabelIds:     msg.labelIDs,
+		Snippet:      msg.snippet,
+		HistoryId:    historyID,
+		InternalDate: msg.date.UnixMilli(),
+		SizeEstimate: int64(len(msg.data)),
+	}
+
+	switch format {
+	case "full":
+		result.Payload = msg.payload
+	case "metadata":
+		result.Payload = &gmailapi.MessagePart{MimeType: msg.payload.MimeType}
+		for _, header := range msg.payload.Headers {
+			if len(metadataHeaders) == 0 || slices.ContainsFunc(metadataHeaders, func(name string) bool {
+				return strings.EqualFold(name, header.Name)
+			}) {
+				result.Payload.Headers = append(result.Payload.Headers, header)
+			}
+		}
+	case "raw":
+		result.Raw = base64.URLEncoding.EncodeToString(msg.data)
+	}
+	return result
+}
+
+// visible reports whether a message is listed without includeSpamTrash.
+func visible(msg *fakeMessage) bool {
+	return !slices.Contains(msg.labelIDs, "SPAM") && !slices.Contains(msg.labelIDs, "TRASH")
+}
+
+// searchesSpamTrash reports whether a query asks for spam or trash with
+// in:spam, in:trash or in:anywhere, which Gmail lists them for.
+func searchesSpamTrash(node query.Node) bool {
+	switch n := node.(type) {
+	case *query.And:
+		return slices.ContainsFunc(n.Children, searchesSpamTrash)
+	case *query.Or:
+		return slices.ContainsFunc(n.Children, searchesSpamTrash)
+	case *query.Term:
+		return n.Field == "in" && slices.Contains([]string{"spam", "trash", "anywhere"}, strings.ToLower(n.Value))
+	}
+	return false
+}
+
+// parseQuery parses the q parameter, writing an error if it's invalid.
+func parseQuery(w http.ResponseWriter, r *http.Request) (*query.Query, bool) {
+	q, err := query.Parse(r.URL.Query().Get("q"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid query: "+err.Error())
+		return nil, false
+	}
+	return q, true
+}
+
+// messageFormat returns the format parameter, "full" by default, writing an
+// error if it's invalid.
+func messageFormat(w http.ResponseWriter, r *http.Request) (string, bool) {
+	format := r.URL.Query().Get("format")
+	switch format {
+	case "":
+		return "full", true
+	case "full", "metadata", "minimal", "raw":
+		return format, true
+	}
+	writeError(w, http.StatusBadRequest, "Invalid value for format: "+format)
+	return "", false
+}
+
+// paginate returns the page of items selected by the maxResults and
+// pageToken parameters, and the token of the next page. Page tokens are
+// offsets into the results.
+func paginate[T any](w http.ResponseWriter, r *http.Request, items []T) ([]T, string, bool) {
+	size := defaultPageSize
+	if value := r.URL.Query().Get("maxResults"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			writeError(w, http.StatusBadRequest, "Invalid value for maxResults: "+value)
+			return nil, "", false
+		}
+		size = min(n, maxPageSize)
+	}
+
+	start := 0
+	if token := r.URL.Query().Get("pageToken"); token != "" {
+		n, err := strconv.Atoi(token)
+		if err != nil || n < 0 || n > len(items) {
+			writeError(w, http.StatusBadRequest, "Invalid pageToken")
+			return nil, "", false
+		}
+		start = n
+	}
+
+	end := min(start+size, len(items))
+	next := ""
+	if end < len(items) {
+		next = strconv.Itoa(end)
+	}
+	return items[start:end], next, true
+}
+
+// writeJSON writes v as a JSON response.
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes an error in the Gmail API's format, which the API
+// client returns as a *googleapi.Error.
+func writeError(w http.ResponseWriter, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]any{
+		"error": map[string]any{
+			"code":    code,
+			"message": message,
+		},
+	})
+}
@@ -0,0 +1,158 @@
+package fakegmail_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/bentsolheim/gmail-cli/internal/fakegmail"
+	"github.com/bentsolheim/gmail-cli/internal/gmail"
+	gmailapi "google.golang.org/api/gmail/v1"
+	"google.golang.org/api/option"
+)
+
+// newTestClient returns a Gmail client for a fake server with the fixtures
+// in testdata: a three message conversion thread with an attachment, an
+// unread lunch message and a spam message.
+func newTestClient(t *testing.T) (*gmail.Client, *fakegmail.Server) {
+	t.Helper()
+	srv, err := fakegmail.New("testdata")
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	server := httptest.NewServer(srv)
+	t.Cleanup(server.Close)
+
+	service, err := gmailapi.NewService(context.Background(),
+		option.WithEndpoint(server.URL+"/"),
+		option.WithHTTPClient(server.Client()),
+	)
+	if err != nil {
+		t.Fatalf("failed to create service: %v", err)
+	}
+	return gmail.NewClientWithService(service, ""), srv
+}
+
+func searchSubjects(t *testing.T, client *gmail.Client, query string) ([]string, []string) {
+	t.Helper()
+	result, err := client.SearchThreads(context.Background(), query, gmail.SearchOptions{})
+	if err != nil {
+		t.Fatalf("SearchThreads(%q) error = %v", query, err)
+	}
+	var subjects, ids []string
+	for _, thread := range result.Threads {
+		subjects = append(subjects, thread.Subject)
+		ids = append(ids, thread.ID)
+	}
+	return subjects, ids
+}
+
+func TestServer_Search(t *testing.T) {
+	client, srv := newTestClient(t)
+	if srv.MessageCount() != 5 || srv.ThreadCount() != 3 {
+		t.Errorf("New() loaded %d messages in %d threads, want 5 in 3", srv.MessageCount(), srv.ThreadCount())
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{query: "", want: []string{"Lunch?", "Conversion factors"}},
+		{query: "lunch", want: []string{"Lunch?"}},
+		{query: "from:bob has:attachment", want: []string{"Conversion factors"}},
+		{query: "is:unread", want: []string{"Lunch?"}},
+		{query: "is:important", want: []string{"Conversion factors"}},
+		{query: "label:category_social", want: []string{"Lunch?"}},
+		{query: "in:sent", want: []string{"Conversion factors"}},
+		{query: "in:spam", want: []string{"You won!"}},
+		{query: "after:2025/12/05", want: []string{"Lunch?"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			if got, _ := searchSubjects(t, client, tt.query); !slices.Equal(got, tt.want) {
+				t.Errorf("SearchThreads(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+
+	// Pages follow on from the page token
+	ctx := context.Background()
+	first, err := client.SearchThreads(ctx, "", gmail.SearchOptions{Limit: 1})
+	if err != nil {
+		t.Fatalf("SearchThreads() error = %v", err)
+	}
+	if len(first.Threads) != 1 || first.NextPageToken == "" {
+		t.Fatalf("SearchThreads() with limit 1 = %+v, want one thread and a page token", first)
+	}
+	second, err := client.SearchThreads(ctx, "", gmail.SearchOptions{PageToken: first.NextPageToken})
+	if err != nil {
+		t.Fatalf("SearchThreads() error = %v", err)
+	}
+	if len(second.Threads) != 1 || second.Threads[0].Subject != "Conversion factors" || second.NextPageToken != "" {
+		t.Errorf("SearchThreads() second page = %+v", second)
+	}
+}
+
+func TestServer_Threads(t *testing.T) {
+	ctx := context.Background()
+	client, _ := newTestClient(t)
+	_, ids := searchSubjects(t, client, "")
+
+	conversion, err := client.GetThread(ctx, ids[1])
+	if err != nil {
+		t.Fatalf("GetThread() error = %v", err)
+	}
+	if len(conversion.Messages) != 3 || conversion.Messages[2].From != "Bob <bob@example.com>" {
+		t.Fatalf("GetThread() messages = %+v, want 3 oldest first", conversion.Messages)
+	}
+	if want := []string{"IMPORTANT", "INBOX", "Projects, 2025", "SENT"}; !slices.Equal(conversion.Labels, want) {
+		t.Errorf("Labels = %v, want %v", conversion.Labels, want)
+	}
+
+	att := conversion.Messages[2].Attachments[0]
+	data, err := client.DownloadAttachment(ctx, att.MessageID, att.ID)
+	if err != nil {
+		t.Fatalf("DownloadAttachment() error = %v", err)
+	}
+	if string(data) != "unit,factor\nkm,1000\n" || att.Filename != "factors.csv" {
+		t.Errorf("DownloadAttachment(%s) = %q", att.Filename, data)
+	}
+
+	lunch, err := client.GetThread(ctx, ids[0])
+	if err != nil {
+		t.Fatalf("GetThread() error = %v", err)
+	}
+	msg := lunch.Messages[0]
+	if msg.From != "Carol Sørensen <carol@example.com>" || strings.TrimSpace(msg.Body) != "Tacos at noon? 🌮" {
+		t.Errorf("GetThread() message = %+v", msg)
+	}
+
+	raw, err := client.GetRawMessage(ctx, msg.ID)
+	if err != nil {
+		t.Fatalf("GetRawMessage() error = %v", err)
+	}
+	want, err := os.ReadFile(filepath.Join("testdata", "lunch.eml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(raw.Data) != string(want) || raw.ThreadID != ids[0] {
+		t.Errorf("GetRawMessage() = %+v, want lunch.eml in thread %s", raw, ids[0])
+	}
+
+	if _, err := client.GetThread(ctx, "missing"); err == nil {
+		t.Error("GetThread() with unknown ID succeeded, want error")
+	}
+}
+
+func TestNew_Errors(t *testing.T) {
+	if _, err := fakegmail.New(t.TempDir()); err == nil {
+		t.Error("New() with no fixtures succeeded, want error")
+	}
+	if _, err := fakegmail.New(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("New() with missing directory succeeded, want error")
+	}
+}
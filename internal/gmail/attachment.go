@@ -15,7 +15,7 @@ func (c *Client) DownloadAttachment(ctx context.Context, messageID, attachmentID
 		Context(ctx).
 		Do()
 	if err != nil {
-		return nil, fmt.Errorf("failed to download attachment: %w", err)
+		return nil, fmt.Errorf("failed to download attachment: %w", c.apiError(err))
 	}
 
 	data, err := base64.URLEncoding.DecodeString(attachment.Data)
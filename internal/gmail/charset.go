@@ -0,0 +1,42 @@
+package gmail
+
+import (
+	"mime"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// DecodeCharset converts text in the given charset, such as the charset
+// parameter of a part's Content-Type, to UTF-8. Names are looked up as web
+// browsers do, so "latin1" and "iso-8859-1" decode as Windows-1252. Text in
+// an unknown or missing charset is taken to be UTF-8. Invalid sequences are
+// replaced with U+FFFD.
+func DecodeCharset(data []byte, charset string) string {
+	charset = strings.ToLower(strings.TrimSpace(charset))
+	if charset == "" || charset == "utf-8" || charset == "us-ascii" {
+		return strings.ToValidUTF8(string(data), string(utf8.RuneError))
+	}
+
+	enc, err := htmlindex.Get(charset)
+	if err != nil {
+		return strings.ToValidUTF8(string(data), string(utf8.RuneError))
+	}
+	decoded, err := enc.NewDecoder().Bytes(data)
+	if err != nil {
+		// Decoders replace invalid input, so this is rare; keep what we can
+		return strings.ToValidUTF8(string(data), string(utf8.RuneError))
+	}
+	return strings.ToValidUTF8(string(decoded), string(utf8.RuneError))
+}
+
+// contentCharset returns the charset parameter of a Content-Type header
+// value, or "" if there is none.
+func contentCharset(contentType string) string {
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	return params["charset"]
+}
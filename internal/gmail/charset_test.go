@@ -0,0 +1,50 @@
+package gmail
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+func TestDecodeCharset(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		charset string
+		want    string
+	}{
+		{name: "utf-8", data: "bl\xc3\xa5b\xc3\xa6r", charset: "UTF-8", want: "blåbær"},
+		{name: "no charset", data: "bl\xc3\xa5b\xc3\xa6r", want: "blåbær"},
+		{name: "latin1 alias", data: "bl\xe5b\xe6r", charset: "latin1", want: "blåbær"},
+		{name: "iso-8859-1 as windows-1252", data: "\x80 bl\xe5b\xe6r", charset: "ISO-8859-1", want: "€ blåbær"},
+		{name: "windows-1252", data: "\x93sitat\x94 \x96 \xd8l", charset: "windows-1252", want: "“sitat” – Øl"},
+		{name: "iso-2022-jp", data: "\x1b$B$3$s$K$A$O\x1b(B", charset: "iso-2022-jp", want: "こんにちは"},
+		{name: "koi8-r", data: "\xf0\xd2\xc9\xd7\xc5\xd4", charset: "koi8-r", want: "Привет"},
+		{name: "invalid utf-8", data: "gr\xc3\xb8t \xff", charset: "utf-8", want: "grøt �"},
+		{name: "unknown charset", data: "gr\xc3\xb8t", charset: "x-klingon", want: "grøt"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DecodeCharset([]byte(tt.data), tt.charset); got != tt.want {
+				t.Errorf("DecodeCharset(%q, %q) = %q, want %q", tt.data, tt.charset, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractBody_Charset(t *testing.T) {
+	part := &gmail.MessagePart{
+		MimeType: "multipart/alternative",
+		Parts: []*gmail.MessagePart{
+			{
+				MimeType: "text/plain",
+				Headers:  []*gmail.MessagePartHeader{{Name: "content-type", Value: `text/plain; charset="ISO-8859-1"`}},
+				Body:     &gmail.MessagePartBody{Data: base64.URLEncoding.EncodeToString([]byte("Bl\xe5b\xe6rsyltet\xf8y"))},
+			},
+		},
+	}
+	if got, want := extractBody(part), "Blåbærsyltetøy"; got != want {
+		t.Errorf("extractBody() = %q, want %q", got, want)
+	}
+}
@@ -2,30 +2,115 @@ package gmail
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
 
 	"github.com/bentsolheim/gmail-cli/internal/auth"
 	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/googleapi"
 )
 
+// DefaultUserID is the Gmail user ID for the authenticated user's own mailbox.
+const DefaultUserID = "me"
+
+// ErrDelegationDenied is returned when reading another user's mailbox without delegated access.
+var ErrDelegationDenied = errors.New("delegated access denied")
+
 // Client wraps the Gmail API service.
 type Client struct {
 	service *gmail.Service
 	userID  string
 }
 
+// ClientOptions configures a Client created by NewClient.
+type ClientOptions struct {
+	// Verbose reports retried requests on stderr.
+	Verbose bool
+	// UserID is the mailbox to read: an email address for a delegated or
+	// shared mailbox. Empty means the authenticated user's own mailbox.
+	UserID string
+	// Endpoint is the base URL of the Gmail API. Empty means Google's.
+	Endpoint string
+	// NoAuth sends requests to Endpoint without credentials, e.g. to a fake
+	// server.
+	NoAuth bool
+}
+
 // NewClient creates a new authenticated Gmail client.
-func NewClient(ctx context.Context) (*Client, error) {
-	service, err := auth.GetGmailService(ctx)
+func NewClient(ctx context.Context, opts ClientOptions) (*Client, error) {
+	serviceOpts := auth.DefaultServiceOptions()
+	serviceOpts.Verbose = opts.Verbose
+	serviceOpts.Endpoint = opts.Endpoint
+	serviceOpts.NoAuth = opts.NoAuth
+
+	service, err := auth.GetGmailService(ctx, serviceOpts)
 	if err != nil {
 		return nil, err
 	}
 
+	userID := opts.UserID
+	if userID == "" {
+		userID = DefaultUserID
+	}
+
 	return &Client{
 		service: service,
-		userID:  "me",
+		userID:  userID,
 	}, nil
 }
 
+// NewClientWithService creates a client for an existing Gmail service, e.g.
+// one pointed at a test server. An empty userID means the authenticated
+// user's own mailbox.
+func NewClientWithService(service *gmail.Service, userID string) *Client {
+	if userID == "" {
+		userID = DefaultUserID
+	}
+	return &Client{
+		service: service,
+		userID:  userID,
+	}
+}
+
+// UserID returns the Gmail user ID requests are made for: "me" or the
+// delegated mailbox.
+func (c *Client) UserID() string {
+	return c.userID
+}
+
+// Mailbox returns the mailbox being read, or an empty string for the
+// authenticated user's own mailbox.
+func (c *Client) Mailbox() string {
+	if c.userID == DefaultUserID {
+		return ""
+	}
+	return c.userID
+}
+
+// apiError maps errors from reading another user's mailbox to ErrDelegationDenied.
+func (c *Client) apiError(err error) error {
+	if err == nil || c.userID == DefaultUserID {
+		return err
+	}
+
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) {
+		return err
+	}
+
+	switch apiErr.Code {
+	case http.StatusForbidden, http.StatusBadRequest, http.StatusUnauthorized:
+		if strings.Contains(strings.ToLower(apiErr.Message), "delegation") ||
+			strings.Contains(strings.ToLower(apiErr.Body), "delegation") {
+			return fmt.Errorf("%w: cannot read mailbox %s; ask its owner to add you as a delegate, or check --user: %v",
+				ErrDelegationDenied, c.userID, err)
+		}
+	}
+	return err
+}
+
 // Service returns the underlying Gmail service for direct API access if needed.
 func (c *Client) Service() *gmail.Service {
 	return c.service
@@ -0,0 +1,58 @@
+package gmail
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestClient_DelegatedMailbox(t *testing.T) {
+	var gotPath string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		if strings.Contains(r.URL.Path, "/users/boss@example.com/") {
+			writeJSON(w, summaryThread("t1"))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"error":{"code":403,"message":"Delegation denied for someone@example.com","errors":[{"reason":"forbidden","message":"Delegation denied for someone@example.com"}]}}`))
+	})
+
+	client := newTestClient(t, handler)
+
+	t.Run("requests use the mailbox", func(t *testing.T) {
+		client.userID = "boss@example.com"
+		thread, err := client.GetThread(context.Background(), "t1")
+		if err != nil {
+			t.Fatalf("GetThread() error = %v", err)
+		}
+		if gotPath != "/gmail/v1/users/boss@example.com/threads/t1" {
+			t.Errorf("request path = %q", gotPath)
+		}
+		if thread.Mailbox != "boss@example.com" {
+			t.Errorf("Mailbox = %q, want boss@example.com", thread.Mailbox)
+		}
+	})
+
+	t.Run("delegation denied", func(t *testing.T) {
+		client.userID = "someone@example.com"
+		_, err := client.GetThread(context.Background(), "t1")
+		if !errors.Is(err, ErrDelegationDenied) {
+			t.Errorf("GetThread() error = %v, want ErrDelegationDenied", err)
+		}
+		if err == nil || !strings.Contains(err.Error(), "someone@example.com") {
+			t.Errorf("error should name the mailbox: %v", err)
+		}
+	})
+
+	t.Run("own mailbox errors are unchanged", func(t *testing.T) {
+		client.userID = DefaultUserID
+		_, err := client.GetThread(context.Background(), "t1")
+		if err == nil || errors.Is(err, ErrDelegationDenied) {
+			t.Errorf("GetThread() error = %v, want plain API error", err)
+		}
+	})
+}
@@ -0,0 +1,47 @@
+package gmail
+
+import (
+	"context"
+	"slices"
+	"strings"
+)
+
+// userLabelPrefix is the ID prefix of user-created labels. System labels
+// such as INBOX and CATEGORY_PERSONAL use their name as ID.
+const userLabelPrefix = "Label_"
+
+// resolveLabels replaces the label IDs of a thread and its messages with
+// label names, sorted. The labels list is only fetched when there are user
+// labels to resolve.
+func (c *Client) resolveLabels(ctx context.Context, thread *Thread) error {
+	names := make(map[string]string)
+	if slices.ContainsFunc(thread.Labels, func(id string) bool { return strings.HasPrefix(id, userLabelPrefix) }) {
+		resp, err := c.service.Users.Labels.List(c.userID).Context(ctx).Do()
+		if err != nil {
+			return c.apiError(err)
+		}
+		for _, label := range resp.Labels {
+			names[label.Id] = label.Name
+		}
+	}
+
+	thread.Labels = labelNames(thread.Labels, names)
+	for i := range thread.Messages {
+		thread.Messages[i].Labels = labelNames(thread.Messages[i].Labels, names)
+	}
+	return nil
+}
+
+// labelNames maps label IDs to names, sorted, keeping IDs without a name.
+func labelNames(ids []string, names map[string]string) []string {
+	result := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if name, ok := names[id]; ok {
+			result = append(result, name)
+		} else {
+			result = append(result, id)
+		}
+	}
+	slices.Sort(result)
+	return slices.Compact(result)
+}
@@ -0,0 +1,67 @@
+package gmail
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"sync/atomic"
+	"testing"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+func TestGetThread_Labels(t *testing.T) {
+	var labelLists atomic.Int32
+	labelIDs := map[string][]string{
+		"user":   {"INBOX", "Label_1", "IMPORTANT"},
+		"system": {"INBOX", "CATEGORY_UPDATES"},
+	}
+
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/gmail/v1/users/me/labels":
+			labelLists.Add(1)
+			writeJSON(w, &gmail.ListLabelsResponse{Labels: []*gmail.Label{
+				{Id: "INBOX", Name: "INBOX"},
+				{Id: "Label_1", Name: "Projects/Conversion"},
+			}})
+		case "/gmail/v1/users/me/threads/user", "/gmail/v1/users/me/threads/system":
+			thread := summaryThread("t")
+			thread.Messages[0].LabelIds = labelIDs[r.URL.Path[len("/gmail/v1/users/me/threads/"):]]
+			thread.Messages = append(thread.Messages, &gmail.Message{
+				Id:       "second",
+				LabelIds: []string{"INBOX"},
+				Payload:  &gmail.MessagePart{},
+			})
+			writeJSON(w, thread)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+
+	thread, err := client.GetThread(context.Background(), "user")
+	if err != nil {
+		t.Fatalf("GetThread() error = %v", err)
+	}
+	if want := []string{"IMPORTANT", "INBOX", "Projects/Conversion"}; !slices.Equal(thread.Labels, want) {
+		t.Errorf("Labels = %v, want %v", thread.Labels, want)
+	}
+	if want := []string{"IMPORTANT", "INBOX", "Projects/Conversion"}; !slices.Equal(thread.Messages[0].Labels, want) {
+		t.Errorf("Messages[0].Labels = %v, want %v", thread.Messages[0].Labels, want)
+	}
+	if want := []string{"INBOX"}; !slices.Equal(thread.Messages[1].Labels, want) {
+		t.Errorf("Messages[1].Labels = %v, want %v", thread.Messages[1].Labels, want)
+	}
+
+	// System labels don't need the labels list
+	thread, err = client.GetThread(context.Background(), "system")
+	if err != nil {
+		t.Fatalf("GetThread() error = %v", err)
+	}
+	if want := []string{"CATEGORY_UPDATES", "INBOX"}; !slices.Equal(thread.Labels, want) {
+		t.Errorf("Labels = %v, want %v", thread.Labels, want)
+	}
+	if got := labelLists.Load(); got != 1 {
+		t.Errorf("labels listed %d times, want 1", got)
+	}
+}
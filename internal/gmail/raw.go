@@ -0,0 +1,147 @@
+package gmail
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// GetRawMessage retrieves a message as the original RFC 822 bytes, with all
+// headers and MIME structure intact.
+func (c *Client) GetRawMessage(ctx context.Context, messageID string) (*RawMessage, error) {
+	msg, err := c.service.Users.Messages.Get(c.userID, messageID).
+		Format("raw").
+		Context(ctx).
+		Do()
+	if err != nil {
+		return nil, c.apiError(err)
+	}
+
+	// Gmail pads the URL-safe base64, but tolerate it being left out
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(msg.Raw, "="))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode message %s: %w", messageID, err)
+	}
+
+	return &RawMessage{
+		ID:           msg.Id,
+		ThreadID:     msg.ThreadId,
+		LabelIDs:     msg.LabelIds,
+		InternalDate: time.UnixMilli(msg.InternalDate),
+		Data:         data,
+	}, nil
+}
+
+// ForEachRawMessage calls fn with every message matching query, following
+// page tokens until the result set is exhausted. Messages are fetched with up
+// to opts.Concurrency parallel requests but passed to fn in result order, and
+// at most that many are held in memory at once. An error from fn stops the
+// walk and is returned.
+func (c *Client) ForEachRawMessage(ctx context.Context, query string, opts RawMessageOptions, fn func(*RawMessage) error) error {
+	concurrency := opts.Concurrency
+	if concurrency < 1 {
+		concurrency = DefaultConcurrency
+	}
+
+	pageToken := ""
+	for {
+		call := c.service.Users.Messages.List(c.userID).
+			Q(query).
+			MaxResults(maxPageSize).
+			Context(ctx)
+		if pageToken != "" {
+			call = call.PageToken(pageToken)
+		}
+
+		resp, err := call.Do()
+		if err != nil {
+			return c.apiError(err)
+		}
+
+		var ids []string
+		for _, msg := range resp.Messages {
+			if opts.Skip == nil || !opts.Skip(msg.Id) {
+				ids = append(ids, msg.Id)
+			}
+		}
+
+		for batch := range slices.Chunk(ids, concurrency) {
+			messages, err := c.getRawMessages(ctx, batch)
+			if err != nil {
+				return err
+			}
+			for _, msg := range messages {
+				if err := fn(msg); err != nil {
+					return err
+				}
+			}
+		}
+
+		pageToken = resp.NextPageToken
+		if pageToken == "" {
+			return nil
+		}
+	}
+}
+
+// getRawMessages fetches messages in parallel, keeping their order. The first
+// error cancels all outstanding requests.
+func (c *Client) getRawMessages(ctx context.Context, ids []string) ([]*RawMessage, error) {
+	messages := make([]*RawMessage, len(ids))
+
+	g, ctx := errgroup.WithContext(ctx)
+	for i, id := range ids {
+		g.Go(func() error {
+			msg, err := c.GetRawMessage(ctx, id)
+			if err != nil {
+				return fmt.Errorf("failed to get message %s: %w", id, err)
+			}
+			messages[i] = msg
+			return nil
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// ThreadMessageIDs returns the IDs of the messages in a thread, oldest first.
+func (c *Client) ThreadMessageIDs(ctx context.Context, threadID string) ([]string, error) {
+	thread, err := c.service.Users.Threads.Get(c.userID, threadID).
+		Format("minimal").
+		Context(ctx).
+		Do()
+	if err != nil {
+		return nil, c.apiError(err)
+	}
+
+	ids := make([]string, 0, len(thread.Messages))
+	for _, msg := range thread.Messages {
+		ids = append(ids, msg.Id)
+	}
+	return ids, nil
+}
+
+// SaveRawMessage saves a raw message as <message-id>.eml in the specified
+// directory, replacing an earlier download of the same message.
+func SaveRawMessage(data []byte, outputDir, messageID string) (string, error) {
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	fullPath := filepath.Join(outputDir, sanitizeFilename(messageID)+".eml")
+	if err := os.WriteFile(fullPath, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write message: %w", err)
+	}
+
+	return fullPath, nil
+}
@@ -0,0 +1,188 @@
+package gmail
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+func TestGetRawMessage(t *testing.T) {
+	// CRLF line endings and 8-bit bytes must survive unchanged
+	original := []byte("From: Alice <alice@example.com>\r\nSubject: Bl\xe5b\xe6r\r\n\r\nBody\r\n\x00\xff")
+
+	tests := []struct {
+		name    string
+		encode  func([]byte) string
+		wantErr bool
+	}{
+		{name: "padded", encode: base64.URLEncoding.EncodeToString},
+		{name: "unpadded", encode: base64.RawURLEncoding.EncodeToString},
+		{name: "invalid", encode: func([]byte) string { return "not base64!" }, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotFormat string
+			client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/gmail/v1/users/me/messages/m1" {
+					http.NotFound(w, r)
+					return
+				}
+				gotFormat = r.URL.Query().Get("format")
+				writeJSON(w, &gmail.Message{Id: "m1", Raw: tt.encode(original)})
+			}))
+
+			msg, err := client.GetRawMessage(context.Background(), "m1")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetRawMessage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if gotFormat != "raw" {
+				t.Errorf("format = %q, want raw", gotFormat)
+			}
+			if !tt.wantErr && !bytes.Equal(msg.Data, original) {
+				t.Errorf("GetRawMessage() = %q, want %q", msg.Data, original)
+			}
+		})
+	}
+}
+
+func TestThreadMessageIDs(t *testing.T) {
+	client := newTestClient(t, fakeThreadsHandler(nil, func(w http.ResponseWriter, r *http.Request, id string) {
+		writeJSON(w, &gmail.Thread{Id: id, Messages: []*gmail.Message{{Id: "m1"}, {Id: "m2"}}})
+	}))
+
+	ids, err := client.ThreadMessageIDs(context.Background(), "t1")
+	if err != nil {
+		t.Fatalf("ThreadMessageIDs() error = %v", err)
+	}
+	if want := []string{"m1", "m2"}; !slices.Equal(ids, want) {
+		t.Errorf("ThreadMessageIDs() = %v, want %v", ids, want)
+	}
+}
+
+func TestSaveRawMessage(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "evidence")
+
+	path, err := SaveRawMessage([]byte("first"), dir, "18c1")
+	if err != nil {
+		t.Fatalf("SaveRawMessage() error = %v", err)
+	}
+	if want := filepath.Join(dir, "18c1.eml"); path != want {
+		t.Errorf("SaveRawMessage() = %q, want %q", path, want)
+	}
+
+	// Downloading the same message again replaces the file
+	if _, err := SaveRawMessage([]byte("second"), dir, "18c1"); err != nil {
+		t.Fatalf("SaveRawMessage() error = %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "second" {
+		t.Errorf("file content = %q, want %q", data, "second")
+	}
+}
+
+func TestForEachRawMessage(t *testing.T) {
+	// Two pages: m1..m3, then m4..m5
+	pages := map[string]*gmail.ListMessagesResponse{
+		"": {
+			Messages:      []*gmail.Message{{Id: "m1"}, {Id: "m2"}, {Id: "m3"}},
+			NextPageToken: "page2",
+		},
+		"page2": {
+			Messages: []*gmail.Message{{Id: "m4"}, {Id: "m5"}},
+		},
+	}
+
+	var fetched sync.Map
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		const prefix = "/gmail/v1/users/me/messages"
+		switch {
+		case r.URL.Path == prefix:
+			if q := r.URL.Query().Get("q"); q != "label:work" {
+				t.Errorf("q = %q, want label:work", q)
+			}
+			writeJSON(w, pages[r.URL.Query().Get("pageToken")])
+		case strings.HasPrefix(r.URL.Path, prefix+"/"):
+			id := strings.TrimPrefix(r.URL.Path, prefix+"/")
+			fetched.Store(id, true)
+			if id == "m5" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			writeJSON(w, &gmail.Message{
+				Id:           id,
+				ThreadId:     "t-" + id,
+				LabelIds:     []string{"INBOX"},
+				InternalDate: 1765272600000,
+				Raw:          base64.URLEncoding.EncodeToString([]byte("Subject: " + id + "\r\n\r\n")),
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+
+	t.Run("messages arrive in order", func(t *testing.T) {
+		var got []string
+		opts := RawMessageOptions{
+			Concurrency: 2,
+			Skip:        func(id string) bool { return id == "m2" || id == "m5" },
+		}
+		err := client.ForEachRawMessage(context.Background(), "label:work", opts, func(msg *RawMessage) error {
+			got = append(got, msg.ID)
+			if string(msg.Data) != "Subject: "+msg.ID+"\r\n\r\n" || msg.ThreadID != "t-"+msg.ID {
+				t.Errorf("message %s = %+v", msg.ID, msg)
+			}
+			if !msg.InternalDate.Equal(time.UnixMilli(1765272600000)) {
+				t.Errorf("InternalDate = %v", msg.InternalDate)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("ForEachRawMessage() error = %v", err)
+		}
+		if want := []string{"m1", "m3", "m4"}; !slices.Equal(got, want) {
+			t.Errorf("messages = %v, want %v", got, want)
+		}
+		if _, ok := fetched.Load("m2"); ok {
+			t.Error("skipped message m2 was fetched")
+		}
+	})
+
+	t.Run("fetch error stops the walk", func(t *testing.T) {
+		count := 0
+		err := client.ForEachRawMessage(context.Background(), "label:work", RawMessageOptions{}, func(msg *RawMessage) error {
+			count++
+			return nil
+		})
+		if err == nil || !strings.Contains(err.Error(), "m5") {
+			t.Errorf("ForEachRawMessage() error = %v, want error for m5", err)
+		}
+		if count != 3 {
+			t.Errorf("fn called %d times, want 3 (first page only)", count)
+		}
+	})
+
+	t.Run("callback error stops the walk", func(t *testing.T) {
+		stop := errors.New("stop")
+		err := client.ForEachRawMessage(context.Background(), "label:work", RawMessageOptions{}, func(msg *RawMessage) error {
+			return stop
+		})
+		if !errors.Is(err, stop) {
+			t.Errorf("ForEachRawMessage() error = %v, want %v", err, stop)
+		}
+	})
+}
@@ -5,27 +5,93 @@ import (
 	"strings"
 	"time"
 
+	"golang.org/x/sync/errgroup"
 	"google.golang.org/api/gmail/v1"
 )
 
+const (
+	// maxPageSize is the largest page size accepted by Threads.List.
+	maxPageSize = 500
+
+	// DefaultConcurrency is the number of thread summaries fetched in parallel
+	// when SearchOptions.Concurrency is not set.
+	DefaultConcurrency = 8
+)
+
 // SearchThreads searches for threads matching the query and returns summaries.
-func (c *Client) SearchThreads(ctx context.Context, query string, maxResults int64) ([]ThreadSummary, error) {
-	resp, err := c.service.Users.Threads.List(c.userID).
-		Q(query).
-		MaxResults(maxResults).
-		Context(ctx).
-		Do()
+// It follows page tokens until opts.Limit threads have been collected or the
+// result set is exhausted.
+func (c *Client) SearchThreads(ctx context.Context, query string, opts SearchOptions) (*SearchResult, error) {
+	var threads []*gmail.Thread
+	pageToken := opts.PageToken
+
+	for {
+		pageSize := int64(maxPageSize)
+		if opts.Limit > 0 {
+			remaining := opts.Limit - int64(len(threads))
+			if remaining < pageSize {
+				pageSize = remaining
+			}
+		}
+
+		call := c.service.Users.Threads.List(c.userID).
+			Q(query).
+			MaxResults(pageSize).
+			Context(ctx)
+		if pageToken != "" {
+			call = call.PageToken(pageToken)
+		}
+
+		resp, err := call.Do()
+		if err != nil {
+			return nil, c.apiError(err)
+		}
+
+		threads = append(threads, resp.Threads...)
+		pageToken = resp.NextPageToken
+
+		if pageToken == "" || (opts.Limit > 0 && int64(len(threads)) >= opts.Limit) {
+			break
+		}
+	}
+
+	summaries, err := c.getThreadSummaries(ctx, threads, opts.Concurrency)
 	if err != nil {
 		return nil, err
 	}
 
-	summaries := make([]ThreadSummary, 0, len(resp.Threads))
-	for _, t := range resp.Threads {
-		summary, err := c.getThreadSummary(ctx, t.Id)
-		if err != nil {
-			return nil, err
-		}
-		summaries = append(summaries, summary)
+	return &SearchResult{
+		Threads:       summaries,
+		NextPageToken: pageToken,
+	}, nil
+}
+
+// getThreadSummaries fetches summaries for the given threads using up to
+// concurrency parallel requests. Results keep the order of threads. The first
+// error cancels all outstanding requests.
+func (c *Client) getThreadSummaries(ctx context.Context, threads []*gmail.Thread, concurrency int) ([]ThreadSummary, error) {
+	if concurrency < 1 {
+		concurrency = DefaultConcurrency
+	}
+
+	summaries := make([]ThreadSummary, len(threads))
+
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(concurrency)
+
+	for i, t := range threads {
+		g.Go(func() error {
+			summary, err := c.getThreadSummary(ctx, t.Id)
+			if err != nil {
+				return err
+			}
+			summaries[i] = summary
+			return nil
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		return nil, err
 	}
 
 	return summaries, nil
@@ -38,18 +104,24 @@ func (c *Client) getThreadSummary(ctx context.Context, threadID string) (ThreadS
 		Context(ctx).
 		Do()
 	if err != nil {
-		return ThreadSummary{}, err
+		return ThreadSummary{}, c.apiError(err)
 	}
 
+	return SummarizeThread(threadID, thread.Messages), nil
+}
+
+// SummarizeThread builds a thread summary from the thread's messages, in
+// metadata or full format.
+func SummarizeThread(threadID string, messages []*gmail.Message) ThreadSummary {
 	summary := ThreadSummary{
 		ID:           threadID,
-		MessageCount: len(thread.Messages),
+		MessageCount: len(messages),
 	}
 
 	participantSet := make(map[string]struct{})
 	var latestDate time.Time
 
-	for _, msg := range thread.Messages {
+	for _, msg := range messages {
 		// Count attachments
 		summary.AttachmentCount += countAttachments(msg.Payload)
 
@@ -80,7 +152,7 @@ func (c *Client) getThreadSummary(ctx context.Context, threadID string) (ThreadS
 
 	summary.LastMessageDate = latestDate
 
-	return summary, nil
+	return summary
 }
 
 func countAttachments(part *gmail.MessagePart) int {
@@ -133,3 +205,26 @@ func parseDate(dateStr string) (time.Time, error) {
 
 	return time.Time{}, nil
 }
+
+// Summary returns a summary of a parsed thread, as SummarizeThread does for
+// the thread's API messages.
+func (t *Thread) Summary() ThreadSummary {
+	summary := ThreadSummary{
+		ID:              t.ID,
+		Subject:         t.Subject,
+		LastMessageDate: t.DateRange.End,
+		MessageCount:    len(t.Messages),
+	}
+
+	participantSet := make(map[string]struct{})
+	for _, msg := range t.Messages {
+		summary.AttachmentCount += len(msg.Attachments)
+		name := extractName(msg.From)
+		if _, ok := participantSet[name]; !ok {
+			participantSet[name] = struct{}{}
+			summary.Participants = append(summary.Participants, name)
+		}
+	}
+
+	return summary
+}
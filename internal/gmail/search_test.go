@@ -0,0 +1,256 @@
+package gmail
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/option"
+)
+
+// newTestClient returns a Client that talks to the given fake Gmail API handler.
+func newTestClient(t *testing.T, handler http.Handler) *Client {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	service, err := gmail.NewService(context.Background(),
+		option.WithEndpoint(server.URL+"/"),
+		option.WithHTTPClient(server.Client()),
+	)
+	if err != nil {
+		t.Fatalf("failed to create service: %v", err)
+	}
+
+	return &Client{service: service, userID: "me"}
+}
+
+// writeJSON writes v as a JSON response.
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// fakeThreadsHandler serves Threads.List with the given thread IDs and
+// Threads.Get via getThread.
+func fakeThreadsHandler(ids []string, getThread func(w http.ResponseWriter, r *http.Request, id string)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		const prefix = "/gmail/v1/users/me/threads"
+		switch {
+		case r.URL.Path == prefix:
+			var threads []*gmail.Thread
+			for _, id := range ids {
+				threads = append(threads, &gmail.Thread{Id: id})
+			}
+			writeJSON(w, &gmail.ListThreadsResponse{Threads: threads})
+		case strings.HasPrefix(r.URL.Path, prefix+"/"):
+			getThread(w, r, strings.TrimPrefix(r.URL.Path, prefix+"/"))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+// summaryThread returns a metadata-format thread whose subject is the thread ID.
+func summaryThread(id string) *gmail.Thread {
+	return &gmail.Thread{
+		Id: id,
+		Messages: []*gmail.Message{
+			{
+				Id: id + "-msg",
+				Payload: &gmail.MessagePart{
+					Headers: []*gmail.MessagePartHeader{
+						{Name: "Subject", Value: id},
+						{Name: "From", Value: "Alice <alice@example.com>"},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestSearchThreads_Pagination(t *testing.T) {
+	pages := map[string]*gmail.ListThreadsResponse{
+		"":   {Threads: []*gmail.Thread{{Id: "t1"}, {Id: "t2"}}, NextPageToken: "p2"},
+		"p2": {Threads: []*gmail.Thread{{Id: "t3"}, {Id: "t4"}}, NextPageToken: "p3"},
+		"p3": {Threads: []*gmail.Thread{{Id: "t5"}}},
+	}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		const prefix = "/gmail/v1/users/me/threads"
+		if r.URL.Path == prefix {
+			// Honor maxResults so the fake behaves like the real API
+			page := pages[r.URL.Query().Get("pageToken")]
+			var max int
+			fmt.Sscan(r.URL.Query().Get("maxResults"), &max)
+			if max > 0 && max < len(page.Threads) {
+				writeJSON(w, &gmail.ListThreadsResponse{Threads: page.Threads[:max], NextPageToken: "partial"})
+				return
+			}
+			writeJSON(w, page)
+			return
+		}
+		writeJSON(w, summaryThread(strings.TrimPrefix(r.URL.Path, prefix+"/")))
+	})
+
+	client := newTestClient(t, handler)
+
+	tests := []struct {
+		name      string
+		opts      SearchOptions
+		wantIDs   []string
+		wantToken string
+	}{
+		{
+			name:    "no limit follows all pages",
+			opts:    SearchOptions{},
+			wantIDs: []string{"t1", "t2", "t3", "t4", "t5"},
+		},
+		{
+			name:      "limit on page boundary",
+			opts:      SearchOptions{Limit: 2},
+			wantIDs:   []string{"t1", "t2"},
+			wantToken: "p2",
+		},
+		{
+			name:      "limit spanning pages",
+			opts:      SearchOptions{Limit: 4},
+			wantIDs:   []string{"t1", "t2", "t3", "t4"},
+			wantToken: "p3",
+		},
+		{
+			name:    "start from page token",
+			opts:    SearchOptions{PageToken: "p2"},
+			wantIDs: []string{"t3", "t4", "t5"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := client.SearchThreads(context.Background(), "label:inbox", tt.opts)
+			if err != nil {
+				t.Fatalf("SearchThreads() error = %v", err)
+			}
+
+			var ids []string
+			for _, s := range result.Threads {
+				ids = append(ids, s.ID)
+			}
+			if strings.Join(ids, ",") != strings.Join(tt.wantIDs, ",") {
+				t.Errorf("thread IDs = %v, want %v", ids, tt.wantIDs)
+			}
+			if result.NextPageToken != tt.wantToken {
+				t.Errorf("NextPageToken = %q, want %q", result.NextPageToken, tt.wantToken)
+			}
+		})
+	}
+}
+
+func TestSearchThreads_ConcurrentFetchKeepsOrder(t *testing.T) {
+	ids := []string{"t1", "t2", "t3", "t4", "t5", "t6", "t7", "t8"}
+	const concurrency = 4
+
+	var inFlight, maxInFlight atomic.Int32
+	handler := fakeThreadsHandler(ids, func(w http.ResponseWriter, r *http.Request, id string) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			m := maxInFlight.Load()
+			if n <= m || maxInFlight.CompareAndSwap(m, n) {
+				break
+			}
+		}
+
+		// Earlier threads respond slower so completion order is reversed
+		var idx int
+		fmt.Sscanf(id, "t%d", &idx)
+		time.Sleep(time.Duration(len(ids)-idx+1) * 10 * time.Millisecond)
+
+		writeJSON(w, summaryThread(id))
+	})
+
+	client := newTestClient(t, handler)
+
+	result, err := client.SearchThreads(context.Background(), "in:inbox", SearchOptions{Concurrency: concurrency})
+	if err != nil {
+		t.Fatalf("SearchThreads() error = %v", err)
+	}
+
+	if len(result.Threads) != len(ids) {
+		t.Fatalf("got %d threads, want %d", len(result.Threads), len(ids))
+	}
+	for i, s := range result.Threads {
+		if s.ID != ids[i] || s.Subject != ids[i] {
+			t.Errorf("result[%d] = %s (%q), want %s", i, s.ID, s.Subject, ids[i])
+		}
+	}
+
+	if got := maxInFlight.Load(); got < 2 {
+		t.Errorf("max concurrent requests = %d, want calls to overlap", got)
+	}
+	if got := maxInFlight.Load(); got > concurrency {
+		t.Errorf("max concurrent requests = %d, want at most %d", got, concurrency)
+	}
+}
+
+func TestSearchThreads_ErrorCancelsOutstanding(t *testing.T) {
+	ids := []string{"t1", "t2", "t3", "t4"}
+
+	handler := fakeThreadsHandler(ids, func(w http.ResponseWriter, r *http.Request, id string) {
+		if id == "t1" {
+			http.Error(w, `{"error":{"code":404,"message":"not found"}}`, http.StatusNotFound)
+			return
+		}
+
+		// Block until the client gives up on the request
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+			writeJSON(w, summaryThread(id))
+		}
+	})
+
+	client := newTestClient(t, handler)
+
+	start := time.Now()
+	_, err := client.SearchThreads(context.Background(), "in:inbox", SearchOptions{Concurrency: len(ids)})
+	if err == nil {
+		t.Fatal("SearchThreads() expected error")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("SearchThreads() took %v, want outstanding requests canceled", elapsed)
+	}
+}
+
+func TestThreadSummary(t *testing.T) {
+	end := time.Date(2025, 12, 11, 14, 5, 0, 0, time.UTC)
+	thread := &Thread{
+		ID:        "t1",
+		Subject:   "Conversion factors",
+		DateRange: DateRange{End: end},
+		Messages: []Message{
+			{From: "Felipe Garcia <felipe@example.com>", Attachments: []Attachment{{ID: "a1"}, {ID: "a2"}}},
+			{From: "bob@example.com"},
+			{From: "Felipe Garcia <felipe@example.com>"},
+		},
+	}
+
+	got := thread.Summary()
+	if got.ID != "t1" || got.Subject != "Conversion factors" || !got.LastMessageDate.Equal(end) {
+		t.Errorf("Summary() = %+v", got)
+	}
+	if got.MessageCount != 3 || got.AttachmentCount != 2 {
+		t.Errorf("Summary() counts = %d messages, %d attachments, want 3, 2", got.MessageCount, got.AttachmentCount)
+	}
+	if want := []string{"Felipe Garcia", "bob"}; strings.Join(got.Participants, ",") != strings.Join(want, ",") {
+		t.Errorf("Summary().Participants = %v, want %v", got.Participants, want)
+	}
+}
@@ -3,6 +3,7 @@ package gmail
 import (
 	"context"
 	"encoding/base64"
+	"slices"
 	"strings"
 	"time"
 
@@ -16,26 +17,45 @@ func (c *Client) GetThread(ctx context.Context, threadID string) (*Thread, error
 		Context(ctx).
 		Do()
 	if err != nil {
+		return nil, c.apiError(err)
+	}
+
+	thread := ParseThread(threadID, gmailThread.Messages)
+	thread.Mailbox = c.Mailbox()
+
+	if err := c.resolveLabels(ctx, thread); err != nil {
 		return nil, err
 	}
 
+	return thread, nil
+}
+
+// ParseThread builds a thread from its messages in full format. Labels are
+// left as label IDs. Messages without a valid Date header are dated by when
+// Gmail received them.
+func ParseThread(threadID string, messages []*gmail.Message) *Thread {
 	thread := &Thread{
 		ID:       threadID,
-		Messages: make([]Message, 0, len(gmailThread.Messages)),
+		Messages: make([]Message, 0, len(messages)),
 	}
 
 	participantSet := make(map[string]struct{})
+	var labelIDs []string
 	var earliestDate, latestDate time.Time
 
-	for _, gmailMsg := range gmailThread.Messages {
+	for _, gmailMsg := range messages {
 		msg := Message{
-			ID: gmailMsg.Id,
+			ID:     gmailMsg.Id,
+			Labels: gmailMsg.LabelIds,
+			Size:   gmailMsg.SizeEstimate,
 		}
+		labelIDs = append(labelIDs, gmailMsg.LabelIds...)
 
 		// Extract headers
 		for _, header := range gmailMsg.Payload.Headers {
 			switch header.Name {
 			case "Subject":
+				msg.Subject = header.Value
 				if thread.Subject == "" {
 					thread.Subject = header.Value
 				}
@@ -44,21 +64,33 @@ func (c *Client) GetThread(ctx context.Context, threadID string) (*Thread, error
 				email := extractEmail(header.Value)
 				participantSet[email] = struct{}{}
 			case "To", "Cc":
+				if header.Name == "To" {
+					msg.To = header.Value
+				} else {
+					msg.Cc = header.Value
+				}
 				for _, addr := range parseAddressList(header.Value) {
 					participantSet[addr] = struct{}{}
 				}
+			case "Bcc":
+				msg.Bcc = header.Value
 			case "Date":
 				if t, err := parseDate(header.Value); err == nil {
 					msg.Date = t
-					if earliestDate.IsZero() || t.Before(earliestDate) {
-						earliestDate = t
-					}
-					if t.After(latestDate) {
-						latestDate = t
-					}
 				}
 			}
 		}
+		if msg.Date.IsZero() && gmailMsg.InternalDate != 0 {
+			msg.Date = time.UnixMilli(gmailMsg.InternalDate)
+		}
+		if !msg.Date.IsZero() {
+			if earliestDate.IsZero() || msg.Date.Before(earliestDate) {
+				earliestDate = msg.Date
+			}
+			if msg.Date.After(latestDate) {
+				latestDate = msg.Date
+			}
+		}
 
 		// Extract body
 		msg.Body = extractBody(gmailMsg.Payload)
@@ -79,7 +111,10 @@ func (c *Client) GetThread(ctx context.Context, threadID string) (*Thread, error
 		End:   latestDate,
 	}
 
-	return thread, nil
+	slices.Sort(labelIDs)
+	thread.Labels = slices.Compact(labelIDs)
+
+	return thread
 }
 
 // extractBody recursively extracts the text body from a message part.
@@ -90,10 +125,9 @@ func extractBody(part *gmail.MessagePart) string {
 	}
 
 	// If this part is text/plain, decode and return it
-	if part.MimeType == "text/plain" && part.Body != nil && part.Body.Data != "" {
-		decoded, err := base64.URLEncoding.DecodeString(part.Body.Data)
-		if err == nil {
-			return string(decoded)
+	if part.MimeType == "text/plain" {
+		if text, ok := partText(part); ok {
+			return text
 		}
 	}
 
@@ -107,10 +141,9 @@ func extractBody(part *gmail.MessagePart) string {
 	}
 
 	// If no text/plain, try text/html as fallback
-	if part.MimeType == "text/html" && part.Body != nil && part.Body.Data != "" {
-		decoded, err := base64.URLEncoding.DecodeString(part.Body.Data)
-		if err == nil {
-			return stripHTML(string(decoded))
+	if part.MimeType == "text/html" {
+		if text, ok := partText(part); ok {
+			return StripHTML(text)
 		}
 	}
 
@@ -124,8 +157,28 @@ func extractBody(part *gmail.MessagePart) string {
 	return ""
 }
 
-// stripHTML removes HTML tags and decodes common entities for basic readability.
-func stripHTML(html string) string {
+// partText decodes the body of a text part to UTF-8 from the charset in its
+// Content-Type header. It reports false if the part has no body.
+func partText(part *gmail.MessagePart) (string, bool) {
+	if part.Body == nil || part.Body.Data == "" {
+		return "", false
+	}
+	decoded, err := base64.URLEncoding.DecodeString(part.Body.Data)
+	if err != nil {
+		return "", false
+	}
+
+	var charset string
+	for _, header := range part.Headers {
+		if strings.EqualFold(header.Name, "Content-Type") {
+			charset = contentCharset(header.Value)
+		}
+	}
+	return DecodeCharset(decoded, charset), true
+}
+
+// StripHTML removes HTML tags and decodes common entities for basic readability.
+func StripHTML(html string) string {
 	// Very basic HTML stripping - just remove tags
 	var result strings.Builder
 	inTag := false
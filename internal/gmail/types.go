@@ -10,6 +10,30 @@ type ThreadSummary struct {
 	LastMessageDate time.Time
 	MessageCount    int
 	AttachmentCount int
+	// Score is the relevance of a ranked local search result, higher is
+	// better. Zero for other searches.
+	Score float64
+	// Snippet is an excerpt of a ranked local search result with matching
+	// words highlighted as **word**. Empty for other searches.
+	Snippet string
+}
+
+// SearchOptions controls which page of results SearchThreads returns.
+type SearchOptions struct {
+	// Limit is the maximum number of threads to return. Zero means no limit.
+	Limit int64
+	// PageToken resumes a search from a page token returned by a previous search.
+	PageToken string
+	// Concurrency is the number of thread summaries fetched in parallel.
+	// Zero means DefaultConcurrency.
+	Concurrency int
+}
+
+// SearchResult contains a page of thread summaries.
+type SearchResult struct {
+	Threads []ThreadSummary
+	// NextPageToken is set when more results are available.
+	NextPageToken string
 }
 
 // Thread contains the full content of an email thread.
@@ -19,6 +43,11 @@ type Thread struct {
 	Participants []string
 	DateRange    DateRange
 	Messages     []Message
+	// Mailbox is the delegated or shared mailbox the thread was read from.
+	// Empty for the authenticated user's own mailbox.
+	Mailbox string
+	// Labels are the names of the labels on any message in the thread.
+	Labels []string
 }
 
 // DateRange represents the time span of a thread.
@@ -29,10 +58,19 @@ type DateRange struct {
 
 // Message represents a single email message within a thread.
 type Message struct {
-	ID          string
-	From        string
-	Date        time.Time
-	Body        string
+	ID   string
+	From string
+	// To, Cc and Bcc are the address list headers as sent.
+	To      string
+	Cc      string
+	Bcc     string
+	Subject string
+	Date    time.Time
+	Body    string
+	// Labels are the names of the message's labels.
+	Labels []string
+	// Size is the size of the message in bytes.
+	Size        int64
 	Attachments []Attachment
 }
 
@@ -44,3 +82,23 @@ type Attachment struct {
 	MimeType  string
 	Size      int64
 }
+
+// RawMessage is a message as the original RFC 822 bytes.
+type RawMessage struct {
+	ID       string
+	ThreadID string
+	LabelIDs []string
+	// InternalDate is when Gmail received the message.
+	InternalDate time.Time
+	Data         []byte
+}
+
+// RawMessageOptions controls ForEachRawMessage.
+type RawMessageOptions struct {
+	// Concurrency is the number of messages fetched in parallel.
+	// Zero means DefaultConcurrency.
+	Concurrency int
+	// Skip reports whether a message should not be fetched, e.g. because
+	// it was exported before. Nil fetches every message.
+	Skip func(id string) bool
+}
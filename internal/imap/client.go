@@ -0,0 +1,568 @@
+// Package imap is a minimal IMAP4rev1 client with the commands needed to
+// read a mailbox: login, mailbox listing, UID SEARCH and UID FETCH, and the
+// Gmail extensions X-GM-RAW, X-GM-THRID, X-GM-MSGID and X-GM-LABELS.
+package imap
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Error is a NO or BAD reply to a command.
+type Error struct {
+	Command string
+	Status  string
+	Text    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("imap %s: %s %s", e.Command, e.Status, e.Text)
+}
+
+// Quoted is a command argument sent as a quoted string, or as a literal if
+// it can't be quoted.
+type Quoted string
+
+// DialOptions controls Dial.
+type DialOptions struct {
+	// TLSConfig is the TLS configuration. Nil uses the system roots and
+	// the host name from the address.
+	TLSConfig *tls.Config
+	// StartTLS connects in plain text and upgrades with STARTTLS, as on
+	// port 143, instead of connecting with TLS, as on port 993.
+	StartTLS bool
+}
+
+// Client is a connection to an IMAP server. It is not safe for concurrent
+// use.
+type Client struct {
+	conn net.Conn
+	r    *bufio.Reader
+	w    *bufio.Writer
+	tag  int
+	caps map[string]bool
+}
+
+// Dial connects to an IMAP server at addr (host:port) over TLS.
+func Dial(ctx context.Context, addr string, opts DialOptions) (*Client, error) {
+	tlsConfig := opts.TLSConfig
+	if tlsConfig == nil {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address %q: %w", addr, err)
+		}
+		tlsConfig = &tls.Config{ServerName: host}
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", addr, err)
+	}
+
+	c := &Client{}
+	if !opts.StartTLS {
+		conn, err = handshake(ctx, conn, tlsConfig)
+		if err != nil {
+			return nil, err
+		}
+	}
+	c.setConn(conn)
+
+	if err := c.greeting(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if opts.StartTLS {
+		if !c.caps["STARTTLS"] {
+			conn.Close()
+			return nil, errors.New("server does not support STARTTLS")
+		}
+		if err := c.execute(ctx, nil, "STARTTLS"); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn, err = handshake(ctx, conn, tlsConfig)
+		if err != nil {
+			return nil, err
+		}
+		c.setConn(conn)
+		// Capabilities from before TLS can't be trusted
+		if err := c.capability(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func handshake(ctx context.Context, conn net.Conn, config *tls.Config) (net.Conn, error) {
+	tlsConn := tls.Client(conn, config)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("TLS handshake failed: %w", err)
+	}
+	return tlsConn, nil
+}
+
+func (c *Client) setConn(conn net.Conn) {
+	c.conn = conn
+	c.r = bufio.NewReader(conn)
+	c.w = bufio.NewWriter(conn)
+}
+
+// greeting reads the server greeting and the capabilities.
+func (c *Client) greeting(ctx context.Context) error {
+	stop := c.watch(ctx)
+	defer stop()
+
+	resp, err := c.readResponse()
+	if err != nil {
+		return fmt.Errorf("failed to read greeting: %w", err)
+	}
+	if resp.tag != "*" || (resp.status != "OK" && resp.status != "PREAUTH") {
+		return fmt.Errorf("server refused connection: %s %s", resp.status, resp.text)
+	}
+	return c.capability(ctx)
+}
+
+// Has reports whether the server advertised a capability, such as
+// "X-GM-EXT-1".
+func (c *Client) Has(capability string) bool {
+	return c.caps[strings.ToUpper(capability)]
+}
+
+func (c *Client) capability(ctx context.Context) error {
+	c.caps = make(map[string]bool)
+	return c.execute(ctx, func(fields []any) {
+		if len(fields) > 0 && strings.EqualFold(atom(fields[0]), "CAPABILITY") {
+			for _, f := range fields[1:] {
+				c.caps[strings.ToUpper(atom(f))] = true
+			}
+		}
+	}, "CAPABILITY")
+}
+
+// Login authenticates with a username and password.
+func (c *Client) Login(ctx context.Context, username, password string) error {
+	if err := c.execute(ctx, nil, "LOGIN", Quoted(username), Quoted(password)); err != nil {
+		return err
+	}
+	return c.capability(ctx)
+}
+
+// AuthenticateXOAuth2 authenticates with an OAuth 2 access token, as Gmail
+// and other providers support instead of passwords.
+func (c *Client) AuthenticateXOAuth2(ctx context.Context, username, token string) error {
+	ir := base64.StdEncoding.EncodeToString([]byte("user=" + username + "\x01auth=Bearer " + token + "\x01\x01"))
+	sent := false
+	err := c.executeWith(ctx, nil, func(text string) (string, error) {
+		// The first challenge asks for the initial response. After a
+		// failure the server sends error details and expects an empty reply.
+		if sent {
+			return "", nil
+		}
+		sent = true
+		return ir, nil
+	}, "AUTHENTICATE", "XOAUTH2")
+	if err != nil {
+		return err
+	}
+	return c.capability(ctx)
+}
+
+// Mailbox is a mailbox returned by List.
+type Mailbox struct {
+	Name string
+	// Attributes are flags such as \HasChildren, and special uses such as
+	// \All, \Sent and \Trash.
+	Attributes []string
+}
+
+// HasAttribute reports whether the mailbox has an attribute, ignoring case.
+func (m Mailbox) HasAttribute(attr string) bool {
+	for _, a := range m.Attributes {
+		if strings.EqualFold(a, attr) {
+			return true
+		}
+	}
+	return false
+}
+
+// List returns all mailboxes.
+func (c *Client) List(ctx context.Context) ([]Mailbox, error) {
+	var mailboxes []Mailbox
+	err := c.execute(ctx, func(fields []any) {
+		if len(fields) < 4 || !strings.EqualFold(atom(fields[0]), "LIST") {
+			return
+		}
+		mb := Mailbox{Name: atom(fields[3])}
+		if attrs, ok := fields[1].([]any); ok {
+			for _, a := range attrs {
+				mb.Attributes = append(mb.Attributes, atom(a))
+			}
+		}
+		mailboxes = append(mailboxes, mb)
+	}, "LIST", Quoted(""), Quoted("*"))
+	return mailboxes, err
+}
+
+// Examine opens a mailbox read-only, so fetching messages doesn't mark them
+// as read.
+func (c *Client) Examine(ctx context.Context, mailbox string) error {
+	return c.execute(ctx, nil, "EXAMINE", Quoted(mailbox))
+}
+
+// Search returns the UIDs of the messages matching the search keys, such as
+// "FROM", Quoted("felipe") or "X-GM-RAW", Quoted("has:attachment").
+func (c *Client) Search(ctx context.Context, criteria ...any) ([]uint32, error) {
+	args := []any{"UID", "SEARCH"}
+	for _, arg := range criteria {
+		if q, ok := arg.(Quoted); ok && !isASCII(string(q)) {
+			args = append(args, "CHARSET", "UTF-8")
+			break
+		}
+	}
+	args = append(args, criteria...)
+
+	var uids []uint32
+	err := c.execute(ctx, func(fields []any) {
+		if len(fields) == 0 || !strings.EqualFold(atom(fields[0]), "SEARCH") {
+			return
+		}
+		for _, f := range fields[1:] {
+			if uid, err := strconv.ParseUint(atom(f), 10, 32); err == nil {
+				uids = append(uids, uint32(uid))
+			}
+		}
+	}, args...)
+	return uids, err
+}
+
+// Message is a message returned by Fetch. Items that weren't fetched are
+// zero.
+type Message struct {
+	UID          uint32
+	Flags        []string
+	InternalDate time.Time
+	Size         int64
+	// ThreadID, MessageID and Labels are Gmail's X-GM-THRID, X-GM-MSGID
+	// and X-GM-LABELS.
+	ThreadID  uint64
+	MessageID uint64
+	Labels    []string
+	// Body is the fetched BODY[] section: the whole message, or the
+	// requested header fields.
+	Body []byte
+}
+
+// HasFlag reports whether the message has a flag, such as \Seen, ignoring
+// case.
+func (m *Message) HasFlag(flag string) bool {
+	for _, f := range m.Flags {
+		if strings.EqualFold(f, flag) {
+			return true
+		}
+	}
+	return false
+}
+
+// fetchBatch is the number of UIDs fetched per command, keeping command
+// lines short.
+const fetchBatch = 500
+
+// Fetch returns the given items, such as "FLAGS" and "BODY.PEEK[]", of the
+// messages with the given UIDs, in the order the server sends them. Nil
+// uids fetches every message. UID is always fetched.
+func (c *Client) Fetch(ctx context.Context, uids []uint32, items ...string) ([]*Message, error) {
+	list := "(" + strings.Join(append([]string{"UID"}, items...), " ") + ")"
+
+	if uids == nil {
+		return c.fetch(ctx, "1:*", list)
+	}
+	var messages []*Message
+	for start := 0; start < len(uids); start += fetchBatch {
+		end := min(start+fetchBatch, len(uids))
+		batch, err := c.fetch(ctx, uidSet(uids[start:end]), list)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, batch...)
+	}
+	return messages, nil
+}
+
+func (c *Client) fetch(ctx context.Context, set, items string) ([]*Message, error) {
+	var messages []*Message
+	var parseErr error
+	err := c.execute(ctx, func(fields []any) {
+		if len(fields) < 3 || !strings.EqualFold(atom(fields[1]), "FETCH") {
+			return
+		}
+		attrs, ok := fields[2].([]any)
+		if !ok {
+			return
+		}
+		msg, err := parseFetch(attrs)
+		if err != nil {
+			parseErr = err
+			return
+		}
+		messages = append(messages, msg)
+	}, "UID", "FETCH", set, items)
+	if err != nil {
+		return nil, err
+	}
+	if parseErr != nil {
+		return nil, parseErr
+	}
+	return messages, nil
+}
+
+// internalDateLayout is the format of INTERNALDATE.
+const internalDateLayout = "_2-Jan-2006 15:04:05 -0700"
+
+func parseFetch(attrs []any) (*Message, error) {
+	msg := &Message{}
+	for i := 0; i+1 < len(attrs); i += 2 {
+		name := strings.ToUpper(atom(attrs[i]))
+		value := attrs[i+1]
+
+		var err error
+		switch {
+		case name == "UID":
+			var uid uint64
+			uid, err = strconv.ParseUint(atom(value), 10, 32)
+			msg.UID = uint32(uid)
+		case name == "FLAGS":
+			msg.Flags = atoms(value)
+		case name == "INTERNALDATE":
+			msg.InternalDate, err = time.Parse(internalDateLayout, atom(value))
+		case name == "RFC822.SIZE":
+			msg.Size, err = strconv.ParseInt(atom(value), 10, 64)
+		case name == "X-GM-THRID":
+			msg.ThreadID, err = strconv.ParseUint(atom(value), 10, 64)
+		case name == "X-GM-MSGID":
+			msg.MessageID, err = strconv.ParseUint(atom(value), 10, 64)
+		case name == "X-GM-LABELS":
+			msg.Labels = atoms(value)
+		case strings.HasPrefix(name, "BODY["):
+			msg.Body = []byte(atom(value))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s in FETCH response: %w", name, err)
+		}
+	}
+	return msg, nil
+}
+
+// Logout ends the session and closes the connection.
+func (c *Client) Logout(ctx context.Context) error {
+	err := c.execute(ctx, nil, "LOGOUT")
+	if closeErr := c.conn.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Close closes the connection without logging out.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// execute sends a command and reads responses until its completion,
+// passing the fields of untagged data responses to handle.
+func (c *Client) execute(ctx context.Context, handle func(fields []any), args ...any) error {
+	return c.executeWith(ctx, handle, nil, args...)
+}
+
+// executeWith is execute for commands that answer continuation requests,
+// such as AUTHENTICATE. continueWith returns the line to send in reply to
+// the text of a continuation request.
+func (c *Client) executeWith(ctx context.Context, handle func(fields []any), continueWith func(text string) (string, error), args ...any) error {
+	stop := c.watch(ctx)
+	defer stop()
+
+	c.tag++
+	tag := "A" + strconv.Itoa(c.tag)
+	command := atom(args[0])
+	if command == "UID" && len(args) > 1 {
+		command += " " + atom(args[1])
+	}
+
+	if _, err := c.w.WriteString(tag); err != nil {
+		return c.connError(ctx, err)
+	}
+	for _, arg := range args {
+		c.w.WriteByte(' ')
+		if q, ok := arg.(Quoted); ok && !canQuote(string(q)) {
+			if err := c.writeLiteral(string(q)); err != nil {
+				return c.connError(ctx, err)
+			}
+			continue
+		}
+		c.w.WriteString(formatArg(arg))
+	}
+	c.w.WriteString("\r\n")
+	if err := c.w.Flush(); err != nil {
+		return c.connError(ctx, err)
+	}
+
+	for {
+		resp, err := c.readResponse()
+		if err != nil {
+			return c.connError(ctx, err)
+		}
+		switch resp.tag {
+		case "*":
+			if resp.status == "BYE" && command != "LOGOUT" {
+				return fmt.Errorf("server closed the connection: %s", resp.text)
+			}
+			if resp.status == "" && handle != nil {
+				handle(resp.fields)
+			}
+		case "+":
+			if continueWith == nil {
+				return fmt.Errorf("unexpected continuation request to %s", command)
+			}
+			line, err := continueWith(resp.text)
+			if err != nil {
+				return err
+			}
+			c.w.WriteString(line + "\r\n")
+			if err := c.w.Flush(); err != nil {
+				return c.connError(ctx, err)
+			}
+		case tag:
+			if resp.status != "OK" {
+				return &Error{Command: command, Status: resp.status, Text: resp.text}
+			}
+			return nil
+		}
+	}
+}
+
+// writeLiteral sends a string as a literal, waiting for the server to ask
+// for its content.
+func (c *Client) writeLiteral(s string) error {
+	fmt.Fprintf(c.w, "{%d}\r\n", len(s))
+	if err := c.w.Flush(); err != nil {
+		return err
+	}
+	for {
+		resp, err := c.readResponse()
+		if err != nil {
+			return err
+		}
+		if resp.tag == "+" {
+			break
+		}
+		if resp.tag != "*" {
+			return fmt.Errorf("server rejected literal: %s %s", resp.status, resp.text)
+		}
+	}
+	_, err := c.w.WriteString(s)
+	return err
+}
+
+// watch interrupts reads and writes when ctx is done. The returned function
+// stops watching.
+func (c *Client) watch(ctx context.Context) func() {
+	conn := c.conn
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Unix(1, 0))
+	})
+	return func() {
+		if !stop() {
+			// The deadline was set; clear it for the next command
+			conn.SetDeadline(time.Time{})
+		}
+	}
+}
+
+// connError returns ctx's error if it interrupted the connection.
+func (c *Client) connError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if errors.Is(err, io.EOF) {
+		return errors.New("server closed the connection")
+	}
+	return err
+}
+
+func formatArg(arg any) string {
+	switch v := arg.(type) {
+	case Quoted:
+		return quote(string(v))
+	case string:
+		return v
+	}
+	return fmt.Sprint(arg)
+}
+
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// canQuote reports whether s can be sent as a quoted string: 7-bit text
+// without line breaks.
+func canQuote(s string) bool {
+	return isASCII(s) && !strings.ContainsAny(s, "\r\n\x00")
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+// uidSet formats UIDs as a sequence set, with runs as ranges.
+func uidSet(uids []uint32) string {
+	var sb strings.Builder
+	for i := 0; i < len(uids); {
+		j := i
+		for j+1 < len(uids) && uids[j+1] == uids[j]+1 {
+			j++
+		}
+		if sb.Len() > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(strconv.FormatUint(uint64(uids[i]), 10))
+		if j > i {
+			sb.WriteByte(':')
+			sb.WriteString(strconv.FormatUint(uint64(uids[j]), 10))
+		}
+		i = j + 1
+	}
+	return sb.String()
+}
+
+// atom returns a field as a string, "" for NIL and lists.
+func atom(field any) string {
+	s, _ := field.(string)
+	return s
+}
+
+// atoms returns the strings in a list field.
+func atoms(field any) []string {
+	list, _ := field.([]any)
+	result := make([]string, 0, len(list))
+	for _, f := range list {
+		result = append(result, atom(f))
+	}
+	return result
+}
@@ -0,0 +1,178 @@
+package imap_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bentsolheim/gmail-cli/internal/imap"
+	"github.com/bentsolheim/gmail-cli/internal/imap/imaptest"
+)
+
+func testServer(t *testing.T, startTLS bool) *imaptest.Server {
+	t.Helper()
+	received := time.Date(2025, 12, 11, 9, 0, 0, 0, time.UTC)
+	srv := &imaptest.Server{
+		Username: "me@example.com",
+		Password: "secret",
+		Token:    "oauth-token",
+		StartTLS: startTLS,
+		Mailboxes: []*imaptest.Mailbox{
+			{Name: "INBOX", Messages: []*imaptest.Message{
+				{
+					Data:         []byte("Message-ID: <a@example.com>\r\nFrom: Felipe <felipe@example.com>\r\nSubject: Conversion\r\n\r\nHere are the factors.\r\n"),
+					Flags:        []string{`\Seen`},
+					InternalDate: received,
+				},
+				{
+					Data:         []byte("Message-ID: <b@example.com>\r\nFrom: Zoë <zoe@example.com>\r\nSubject: Smörgåsbord\r\n\r\nLunch?\r\n"),
+					InternalDate: received.Add(time.Hour),
+				},
+			}},
+			{Name: "Archive", Attributes: []string{`\Archive`}},
+		},
+	}
+	srv.Start()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+	srv := testServer(t, false)
+
+	c, err := imap.Dial(ctx, srv.Addr, imap.DialOptions{TLSConfig: srv.ClientTLSConfig()})
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	if err := c.Login(ctx, "me@example.com", "secret"); err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+
+	mailboxes, err := c.List(ctx)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(mailboxes) != 2 || mailboxes[0].Name != "INBOX" || !mailboxes[1].HasAttribute(`\archive`) {
+		t.Errorf("List() = %+v, want INBOX and Archive with \\Archive", mailboxes)
+	}
+
+	if err := c.Examine(ctx, "INBOX"); err != nil {
+		t.Fatalf("Examine() error = %v", err)
+	}
+
+	uids, err := c.Search(ctx, "FROM", imap.Quoted("felipe"))
+	if err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+	if want := []uint32{1}; !slices.Equal(uids, want) {
+		t.Errorf("Search(FROM felipe) = %v, want %v", uids, want)
+	}
+
+	// Non-ASCII strings are sent as literals with a charset
+	uids, err = c.Search(ctx, "SUBJECT", imap.Quoted("smörgåsbord"))
+	if err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+	if want := []uint32{2}; !slices.Equal(uids, want) {
+		t.Errorf("Search(SUBJECT smörgåsbord) = %v, want %v", uids, want)
+	}
+	if cmds := srv.Commands(); !slices.Contains(cmds, `UID SEARCH CHARSET UTF-8 SUBJECT "smörgåsbord"`) {
+		t.Errorf("commands = %q, want a UTF-8 search", cmds)
+	}
+
+	messages, err := c.Fetch(ctx, []uint32{1, 2}, "FLAGS", "INTERNALDATE", "RFC822.SIZE", "BODY.PEEK[]")
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if len(messages) != 2 {
+		t.Fatalf("Fetch() returned %d messages, want 2", len(messages))
+	}
+	first := messages[0]
+	if first.UID != 1 || !first.HasFlag(`\seen`) || first.Size != int64(len(first.Body)) {
+		t.Errorf("Fetch() first = %+v", first)
+	}
+	if want := time.Date(2025, 12, 11, 9, 0, 0, 0, time.UTC); !first.InternalDate.Equal(want) {
+		t.Errorf("InternalDate = %v, want %v", first.InternalDate, want)
+	}
+	if !strings.HasSuffix(string(first.Body), "Here are the factors.\r\n") {
+		t.Errorf("Body = %q", first.Body)
+	}
+	if messages[1].HasFlag(`\Seen`) {
+		t.Error("BODY.PEEK[] marked the message as read")
+	}
+
+	// Nil fetches everything
+	messages, err = c.Fetch(ctx, nil, "BODY.PEEK[HEADER.FIELDS (MESSAGE-ID)]")
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if len(messages) != 2 || string(messages[1].Body) != "Message-Id: <b@example.com>\r\n\r\n" {
+		t.Errorf("Fetch(header fields) = %+v", messages)
+	}
+
+	if err := c.Logout(ctx); err != nil {
+		t.Errorf("Logout() error = %v", err)
+	}
+}
+
+func TestClient_StartTLS(t *testing.T) {
+	ctx := context.Background()
+	srv := testServer(t, true)
+
+	c, err := imap.Dial(ctx, srv.Addr, imap.DialOptions{TLSConfig: srv.ClientTLSConfig(), StartTLS: true})
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer c.Close()
+	if c.Has("STARTTLS") {
+		t.Error("capabilities from before STARTTLS were kept")
+	}
+	if err := c.AuthenticateXOAuth2(ctx, "me@example.com", "oauth-token"); err != nil {
+		t.Fatalf("AuthenticateXOAuth2() error = %v", err)
+	}
+	if err := c.Examine(ctx, "INBOX"); err != nil {
+		t.Errorf("Examine() error = %v", err)
+	}
+
+	// Without STARTTLS, a plain text server is refused
+	if _, err := imap.Dial(ctx, srv.Addr, imap.DialOptions{TLSConfig: srv.ClientTLSConfig()}); err == nil {
+		t.Error("Dial() with TLS to a STARTTLS server succeeded, want error")
+	}
+}
+
+func TestClient_AuthErrors(t *testing.T) {
+	ctx := context.Background()
+	srv := testServer(t, false)
+
+	c, err := imap.Dial(ctx, srv.Addr, imap.DialOptions{TLSConfig: srv.ClientTLSConfig()})
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer c.Close()
+
+	var imapErr *imap.Error
+	err = c.Login(ctx, "me@example.com", "wrong")
+	if !errors.As(err, &imapErr) || imapErr.Status != "NO" {
+		t.Errorf("Login() with wrong password error = %v, want NO", err)
+	}
+	err = c.AuthenticateXOAuth2(ctx, "me@example.com", "expired")
+	if !errors.As(err, &imapErr) || imapErr.Command != "AUTHENTICATE" {
+		t.Errorf("AuthenticateXOAuth2() with wrong token error = %v, want AUTHENTICATE NO", err)
+	}
+
+	// The connection is still usable after failures
+	if err := c.Login(ctx, "me@example.com", "secret"); err != nil {
+		t.Errorf("Login() error = %v", err)
+	}
+
+	// Commands are interrupted by the context
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := c.Search(canceled, "ALL"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Search() with canceled context error = %v, want context.Canceled", err)
+	}
+}
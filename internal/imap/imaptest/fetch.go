@@ -0,0 +1,124 @@
+package imaptest
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+func (sess *session) fetch(tag string, args []any) {
+	if len(args) != 2 {
+		sess.reply(tag, "BAD", "expected sequence set and items")
+		return
+	}
+	set := str(args[0])
+	var items []string
+	if list, ok := args[1].([]any); ok {
+		for _, item := range list {
+			items = append(items, strings.ToUpper(str(item)))
+		}
+	} else {
+		items = []string{strings.ToUpper(str(args[1]))}
+	}
+
+	max := uint32(len(sess.mailbox.Messages))
+	for i, msg := range sess.mailbox.Messages {
+		uid := uint32(i + 1)
+		if !inSet(set, uid, max) {
+			continue
+		}
+
+		// UID FETCH responses always include the UID
+		parts := []string{"UID " + strconv.Itoa(i+1)}
+		for _, item := range items {
+			part, err := sess.fetchItem(msg, item)
+			if err != nil {
+				sess.reply(tag, "BAD", err.Error())
+				return
+			}
+			if part != "" {
+				parts = append(parts, part)
+			}
+		}
+		sess.untagged(fmt.Sprintf("%d FETCH (%s)", i+1, strings.Join(parts, " ")))
+	}
+	sess.reply(tag, "OK", "FETCH completed")
+}
+
+// fetchItem returns an item of a FETCH response, "" for UID, which is
+// always included.
+func (sess *session) fetchItem(msg *Message, item string) (string, error) {
+	switch item {
+	case "UID":
+		return "", nil
+	case "FLAGS":
+		return "FLAGS (" + strings.Join(msg.Flags, " ") + ")", nil
+	case "INTERNALDATE":
+		return `INTERNALDATE "` + msg.InternalDate.Format("_2-Jan-2006 15:04:05 -0700") + `"`, nil
+	case "RFC822.SIZE":
+		return "RFC822.SIZE " + strconv.Itoa(len(msg.Data)), nil
+	}
+
+	if sess.s.Gmail {
+		switch item {
+		case "X-GM-THRID":
+			return "X-GM-THRID " + strconv.FormatUint(msg.ThreadID, 10), nil
+		case "X-GM-MSGID":
+			return "X-GM-MSGID " + strconv.FormatUint(msg.MessageID, 10), nil
+		case "X-GM-LABELS":
+			labels := make([]string, len(msg.Labels))
+			for i, label := range msg.Labels {
+				if strings.HasPrefix(label, `\`) {
+					labels[i] = label
+				} else {
+					labels[i] = quote(label)
+				}
+			}
+			return "X-GM-LABELS (" + strings.Join(labels, " ") + ")", nil
+		}
+	}
+
+	peek := strings.HasPrefix(item, "BODY.PEEK[")
+	if !peek && !strings.HasPrefix(item, "BODY[") {
+		return "", fmt.Errorf("unsupported fetch item %s", item)
+	}
+	section := item[strings.IndexByte(item, '[')+1 : len(item)-1]
+
+	var data []byte
+	switch {
+	case section == "":
+		data = msg.Data
+	case strings.HasPrefix(section, "HEADER.FIELDS "):
+		names := strings.Fields(strings.Trim(strings.TrimPrefix(section, "HEADER.FIELDS "), "()"))
+		data = headerFields(msg.Data, names)
+	default:
+		return "", fmt.Errorf("unsupported section %s", section)
+	}
+
+	// Fetching a body without PEEK marks the message as read
+	if !peek && !sess.readOnly && !hasFlag(msg, `\Seen`) {
+		msg.Flags = append(msg.Flags, `\Seen`)
+	}
+	return fmt.Sprintf("BODY[%s] {%d}\r\n%s", section, len(data), data), nil
+}
+
+// headerFields returns the named header fields of a message, ending with a
+// blank line.
+func headerFields(data []byte, names []string) []byte {
+	header, err := textproto.NewReader(bufio.NewReader(bytes.NewReader(headerBlock(data)))).ReadMIMEHeader()
+	if err != nil && len(header) == 0 {
+		return []byte("\r\n")
+	}
+	var buf bytes.Buffer
+	for _, name := range names {
+		key := textproto.CanonicalMIMEHeaderKey(name)
+		for _, value := range header[key] {
+			fmt.Fprintf(&buf, "%s: %s\r\n", key, value)
+		}
+	}
+	buf.WriteString("\r\n")
+	return buf.Bytes()
+}
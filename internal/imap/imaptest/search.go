@@ -0,0 +1,294 @@
+package imaptest
+
+import (
+	"bytes"
+	"fmt"
+	"net/mail"
+	"net/textproto"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bentsolheim/gmail-cli/internal/gmail"
+	"github.com/bentsolheim/gmail-cli/internal/message"
+	"github.com/bentsolheim/gmail-cli/internal/query"
+)
+
+// matcher reports whether the message with a UID matches a search key.
+type matcher func(uid uint32, msg *Message) bool
+
+func (sess *session) search(tag string, args []any) {
+	var keys []matcher
+	for i := 0; i < len(args); {
+		if strings.EqualFold(str(args[i]), "CHARSET") {
+			i += 2
+			continue
+		}
+		m, err := sess.parseKey(args, &i)
+		if err != nil {
+			sess.reply(tag, "BAD", err.Error())
+			return
+		}
+		keys = append(keys, m)
+	}
+
+	var uids []string
+	for i, msg := range sess.mailbox.Messages {
+		uid := uint32(i + 1)
+		if !slices.ContainsFunc(keys, func(m matcher) bool { return !m(uid, msg) }) {
+			uids = append(uids, strconv.Itoa(i+1))
+		}
+	}
+	sess.untagged(strings.TrimSpace("SEARCH " + strings.Join(uids, " ")))
+	sess.reply(tag, "OK", "SEARCH completed")
+}
+
+// parseKey parses the search key at args[*i], advancing *i past it and
+// its arguments.
+func (sess *session) parseKey(args []any, i *int) (matcher, error) {
+	if list, ok := args[*i].([]any); ok {
+		*i++
+		var keys []matcher
+		for j := 0; j < len(list); {
+			m, err := sess.parseKey(list, &j)
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, m)
+		}
+		return func(uid uint32, msg *Message) bool {
+			return !slices.ContainsFunc(keys, func(m matcher) bool { return !m(uid, msg) })
+		}, nil
+	}
+
+	key := strings.ToUpper(str(args[*i]))
+	*i++
+	arg := func() (string, error) {
+		if *i >= len(args) {
+			return "", fmt.Errorf("missing argument to %s", key)
+		}
+		*i++
+		return str(args[*i-1]), nil
+	}
+
+	switch key {
+	case "ALL":
+		return func(uint32, *Message) bool { return true }, nil
+	case "SEEN", "UNSEEN", "FLAGGED", "UNFLAGGED":
+		flag := `\Seen`
+		if strings.HasSuffix(key, "FLAGGED") {
+			flag = `\Flagged`
+		}
+		want := !strings.HasPrefix(key, "UN")
+		return func(_ uint32, msg *Message) bool { return hasFlag(msg, flag) == want }, nil
+	case "NOT":
+		m, err := sess.parseKey(args, i)
+		if err != nil {
+			return nil, err
+		}
+		return func(uid uint32, msg *Message) bool { return !m(uid, msg) }, nil
+	case "OR":
+		a, err := sess.parseKey(args, i)
+		if err != nil {
+			return nil, err
+		}
+		b, err := sess.parseKey(args, i)
+		if err != nil {
+			return nil, err
+		}
+		return func(uid uint32, msg *Message) bool { return a(uid, msg) || b(uid, msg) }, nil
+	case "UID":
+		set, err := arg()
+		if err != nil {
+			return nil, err
+		}
+		max := uint32(len(sess.mailbox.Messages))
+		return func(uid uint32, _ *Message) bool { return inSet(set, uid, max) }, nil
+	case "FROM", "TO", "CC", "BCC", "SUBJECT":
+		value, err := arg()
+		if err != nil {
+			return nil, err
+		}
+		return func(_ uint32, msg *Message) bool {
+			return containsFold(header(msg, key), value)
+		}, nil
+	case "BODY", "TEXT":
+		value, err := arg()
+		if err != nil {
+			return nil, err
+		}
+		return func(_ uint32, msg *Message) bool {
+			parsed, err := message.Parse(msg.Data)
+			if err != nil {
+				return false
+			}
+			text := parsed.Body
+			if key == "TEXT" {
+				text = string(headerBlock(msg.Data)) + text
+			}
+			return containsFold(text, value)
+		}, nil
+	case "SINCE", "BEFORE", "ON":
+		value, err := arg()
+		if err != nil {
+			return nil, err
+		}
+		date, err := time.Parse("_2-Jan-2006", value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid date %q", value)
+		}
+		return func(_ uint32, msg *Message) bool {
+			y, m, d := msg.InternalDate.Date()
+			day := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+			switch key {
+			case "SINCE":
+				return !day.Before(date)
+			case "BEFORE":
+				return day.Before(date)
+			}
+			return day.Equal(date)
+		}, nil
+	case "LARGER", "SMALLER":
+		value, err := arg()
+		if err != nil {
+			return nil, err
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid size %q", value)
+		}
+		return func(_ uint32, msg *Message) bool {
+			if key == "LARGER" {
+				return len(msg.Data) > n
+			}
+			return len(msg.Data) < n
+		}, nil
+	}
+
+	if !sess.s.Gmail {
+		return nil, fmt.Errorf("unsupported search key %s", key)
+	}
+
+	switch key {
+	case "X-GM-RAW":
+		value, err := arg()
+		if err != nil {
+			return nil, err
+		}
+		q, err := query.Parse(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid X-GM-RAW query: %v", err)
+		}
+		return func(_ uint32, msg *Message) bool {
+			thread, gm := gmailMessage(msg)
+			return thread != nil && q.MatchMessage(thread, gm)
+		}, nil
+	case "X-GM-THRID", "X-GM-MSGID":
+		value, err := arg()
+		if err != nil {
+			return nil, err
+		}
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q", key, value)
+		}
+		return func(_ uint32, msg *Message) bool {
+			if key == "X-GM-THRID" {
+				return msg.ThreadID == id
+			}
+			return msg.MessageID == id
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported search key %s", key)
+}
+
+// gmailMessage converts a message for query evaluation, with its labels
+// named as in the Gmail API.
+func gmailMessage(msg *Message) (*gmail.Thread, *gmail.Message) {
+	parsed, err := message.Parse(msg.Data)
+	if err != nil {
+		return nil, nil
+	}
+	gm := &gmail.Message{
+		From:    parsed.From,
+		To:      parsed.To,
+		Cc:      parsed.Cc,
+		Subject: parsed.Subject,
+		Date:    parsed.Date,
+		Body:    parsed.Body,
+		Size:    int64(len(msg.Data)),
+	}
+	if gm.Date.IsZero() {
+		gm.Date = msg.InternalDate
+	}
+	for _, att := range parsed.Attachments {
+		gm.Attachments = append(gm.Attachments, gmail.Attachment{ID: att.ID, Filename: att.Filename})
+	}
+	for _, label := range msg.Labels {
+		if strings.HasPrefix(label, `\`) {
+			label = strings.ToUpper(label[1:])
+		}
+		gm.Labels = append(gm.Labels, label)
+	}
+	if !hasFlag(msg, `\Seen`) {
+		gm.Labels = append(gm.Labels, "UNREAD")
+	}
+	if hasFlag(msg, `\Flagged`) {
+		gm.Labels = append(gm.Labels, "STARRED")
+	}
+	return &gmail.Thread{Subject: parsed.Subject}, gm
+}
+
+func hasFlag(msg *Message, flag string) bool {
+	return slices.ContainsFunc(msg.Flags, func(f string) bool { return strings.EqualFold(f, flag) })
+}
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+// header returns the values of a header field of a message.
+func header(msg *Message, name string) string {
+	m, err := mail.ReadMessage(bytes.NewReader(msg.Data))
+	if err != nil {
+		return ""
+	}
+	return strings.Join(m.Header[textproto.CanonicalMIMEHeaderKey(name)], " ")
+}
+
+// headerBlock returns the header section of a message, including the blank
+// line that ends it.
+func headerBlock(data []byte) []byte {
+	for _, sep := range []string{"\r\n\r\n", "\n\n"} {
+		if i := bytes.Index(data, []byte(sep)); i >= 0 {
+			return data[:i+len(sep)]
+		}
+	}
+	return data
+}
+
+// inSet reports whether uid is in a sequence set such as "1:3,5" or "2:*".
+func inSet(set string, uid, max uint32) bool {
+	parse := func(s string) uint32 {
+		if s == "*" {
+			return max
+		}
+		n, _ := strconv.ParseUint(s, 10, 32)
+		return uint32(n)
+	}
+	for _, part := range strings.Split(set, ",") {
+		lo, hi, isRange := strings.Cut(part, ":")
+		a, b := parse(lo), parse(lo)
+		if isRange {
+			b = parse(hi)
+		}
+		if a > b {
+			a, b = b, a
+		}
+		if uid >= a && uid <= b {
+			return true
+		}
+	}
+	return false
+}
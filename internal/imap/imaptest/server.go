@@ -0,0 +1,516 @@
+// Package imaptest provides an in-process IMAP server for tests, in the
+// spirit of net/http/httptest. It serves messages from memory over TLS and
+// supports the commands package imap uses, including Gmail's X-GM-RAW,
+// X-GM-THRID, X-GM-MSGID and X-GM-LABELS extensions.
+package imaptest
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message is a message in a test mailbox. Its UID is its position in the
+// mailbox, starting at 1.
+type Message struct {
+	Data         []byte
+	Flags        []string
+	InternalDate time.Time
+	// ThreadID, MessageID and Labels are returned as X-GM-THRID,
+	// X-GM-MSGID and X-GM-LABELS by a Gmail server.
+	ThreadID  uint64
+	MessageID uint64
+	Labels    []string
+}
+
+// Mailbox is a test mailbox.
+type Mailbox struct {
+	Name       string
+	Attributes []string
+	Messages   []*Message
+}
+
+// Server is an IMAP server listening on a local port. Set its fields, then
+// call Start.
+type Server struct {
+	// Addr is the host:port the server listens on, set by Start.
+	Addr string
+	// Username and Password are accepted by LOGIN, and Username and Token
+	// by AUTHENTICATE XOAUTH2.
+	Username string
+	Password string
+	Token    string
+	// Gmail makes the server advertise and support the Gmail extensions.
+	Gmail bool
+	// StartTLS makes the server accept plain text connections that must
+	// upgrade with STARTTLS, instead of TLS connections.
+	StartTLS  bool
+	Mailboxes []*Mailbox
+
+	listener  net.Listener
+	tlsConfig *tls.Config
+	certPool  *x509.CertPool
+
+	mu       sync.Mutex
+	commands []string
+	conns    map[net.Conn]bool
+	wg       sync.WaitGroup
+}
+
+// Start starts the server on a random local port. It panics if the server
+// can't listen, like httptest.Server.
+func (s *Server) Start() {
+	cert, pool, err := newCertificate()
+	if err != nil {
+		panic(fmt.Sprintf("imaptest: failed to create certificate: %v", err))
+	}
+	s.tlsConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+	s.certPool = pool
+
+	s.listener, err = net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(fmt.Sprintf("imaptest: failed to listen: %v", err))
+	}
+	s.Addr = s.listener.Addr().String()
+	s.conns = make(map[net.Conn]bool)
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			conn, err := s.listener.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.conns[conn] = true
+			s.mu.Unlock()
+
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				s.serve(conn)
+				s.mu.Lock()
+				delete(s.conns, conn)
+				s.mu.Unlock()
+			}()
+		}
+	}()
+}
+
+// Close stops the server and closes open connections.
+func (s *Server) Close() {
+	s.listener.Close()
+	s.mu.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+// ClientTLSConfig returns a TLS configuration that trusts the server.
+func (s *Server) ClientTLSConfig() *tls.Config {
+	return &tls.Config{RootCAs: s.certPool, ServerName: "localhost"}
+}
+
+// Commands returns the commands received so far, without tags and with
+// literals quoted, e.g. `EXAMINE "INBOX"` or `UID FETCH 1:3 (UID FLAGS)`.
+func (s *Server) Commands() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.commands...)
+}
+
+// newCertificate returns a self-signed certificate for localhost and
+// 127.0.0.1, and a pool trusting it.
+func newCertificate() (tls.Certificate, *x509.CertPool, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "imaptest"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, pool, nil
+}
+
+// session is the state of one connection.
+type session struct {
+	s         *Server
+	conn      net.Conn
+	r         *bufio.Reader
+	w         *bufio.Writer
+	secure    bool
+	authed    bool
+	mailbox   *Mailbox
+	readOnly  bool
+	loggedOut bool
+}
+
+func (s *Server) serve(conn net.Conn) {
+	defer conn.Close()
+	if !s.StartTLS {
+		conn = tls.Server(conn, s.tlsConfig)
+	}
+	sess := &session{s: s, secure: !s.StartTLS}
+	sess.setConn(conn)
+
+	sess.untagged("OK IMAP4rev1 imaptest ready")
+	if sess.w.Flush() != nil {
+		return
+	}
+
+	for !sess.loggedOut {
+		tag, args, line, err := sess.readCommand()
+		if err != nil {
+			if err != io.EOF {
+				sess.untagged("BAD " + err.Error())
+				sess.w.Flush()
+			}
+			return
+		}
+		if tag == "" {
+			continue
+		}
+		s.mu.Lock()
+		s.commands = append(s.commands, line)
+		s.mu.Unlock()
+
+		sess.handle(tag, args)
+		if sess.w.Flush() != nil {
+			return
+		}
+	}
+}
+
+func (sess *session) setConn(conn net.Conn) {
+	sess.conn = conn
+	sess.r = bufio.NewReader(conn)
+	sess.w = bufio.NewWriter(conn)
+}
+
+func (sess *session) untagged(line string) {
+	sess.w.WriteString("* " + line + "\r\n")
+}
+
+func (sess *session) reply(tag, status, text string) {
+	sess.w.WriteString(tag + " " + status + " " + text + "\r\n")
+}
+
+func (sess *session) handle(tag string, args []any) {
+	if len(args) == 0 {
+		sess.reply(tag, "BAD", "missing command")
+		return
+	}
+	command := strings.ToUpper(str(args[0]))
+	args = args[1:]
+	if command == "UID" && len(args) > 0 {
+		command += " " + strings.ToUpper(str(args[0]))
+		args = args[1:]
+	}
+
+	switch command {
+	case "CAPABILITY":
+		sess.untagged("CAPABILITY " + sess.capabilities())
+		sess.reply(tag, "OK", "CAPABILITY completed")
+		return
+	case "NOOP":
+		sess.reply(tag, "OK", "NOOP completed")
+		return
+	case "LOGOUT":
+		sess.untagged("BYE logging out")
+		sess.reply(tag, "OK", "LOGOUT completed")
+		sess.loggedOut = true
+		return
+	case "STARTTLS":
+		if sess.secure {
+			sess.reply(tag, "BAD", "already using TLS")
+			return
+		}
+		sess.reply(tag, "OK", "begin TLS negotiation")
+		sess.w.Flush()
+		sess.setConn(tls.Server(sess.conn, sess.s.tlsConfig))
+		sess.secure = true
+		return
+	}
+
+	if !sess.secure {
+		sess.reply(tag, "BAD", "use STARTTLS first")
+		return
+	}
+
+	switch command {
+	case "LOGIN":
+		if len(args) != 2 || str(args[0]) != sess.s.Username || sess.s.Password == "" || str(args[1]) != sess.s.Password {
+			sess.reply(tag, "NO", "[AUTHENTICATIONFAILED] invalid credentials")
+			return
+		}
+		sess.authed = true
+		sess.reply(tag, "OK", "LOGIN completed")
+		return
+	case "AUTHENTICATE":
+		sess.authenticate(tag, args)
+		return
+	}
+
+	if !sess.authed {
+		sess.reply(tag, "NO", "not authenticated")
+		return
+	}
+
+	switch command {
+	case "LIST":
+		for _, mb := range sess.s.Mailboxes {
+			sess.untagged(fmt.Sprintf("LIST (%s) \"/\" %s", strings.Join(mb.Attributes, " "), quote(mb.Name)))
+		}
+		sess.reply(tag, "OK", "LIST completed")
+	case "SELECT", "EXAMINE":
+		sess.mailbox = nil
+		for _, mb := range sess.s.Mailboxes {
+			if len(args) == 1 && mb.Name == str(args[0]) {
+				sess.mailbox = mb
+			}
+		}
+		if sess.mailbox == nil {
+			sess.reply(tag, "NO", "[NONEXISTENT] no such mailbox")
+			return
+		}
+		sess.readOnly = command == "EXAMINE"
+		sess.untagged(fmt.Sprintf("%d EXISTS", len(sess.mailbox.Messages)))
+		sess.untagged("OK [UIDVALIDITY 1] UIDs valid")
+		if sess.readOnly {
+			sess.reply(tag, "OK", "[READ-ONLY] EXAMINE completed")
+		} else {
+			sess.reply(tag, "OK", "[READ-WRITE] SELECT completed")
+		}
+	case "UID SEARCH":
+		if sess.mailbox == nil {
+			sess.reply(tag, "BAD", "no mailbox selected")
+			return
+		}
+		sess.search(tag, args)
+	case "UID FETCH":
+		if sess.mailbox == nil {
+			sess.reply(tag, "BAD", "no mailbox selected")
+			return
+		}
+		sess.fetch(tag, args)
+	default:
+		sess.reply(tag, "BAD", "unsupported command "+command)
+	}
+}
+
+func (sess *session) capabilities() string {
+	caps := []string{"IMAP4rev1", "AUTH=XOAUTH2"}
+	if !sess.secure {
+		caps = append(caps, "STARTTLS", "LOGINDISABLED")
+	}
+	if sess.s.Gmail {
+		caps = append(caps, "X-GM-EXT-1")
+	}
+	return strings.Join(caps, " ")
+}
+
+// authenticate handles AUTHENTICATE XOAUTH2, with or without an initial
+// response. Like Gmail, a failure sends error details as a challenge first.
+func (sess *session) authenticate(tag string, args []any) {
+	if len(args) == 0 || !strings.EqualFold(str(args[0]), "XOAUTH2") {
+		sess.reply(tag, "NO", "unsupported mechanism")
+		return
+	}
+
+	var ir string
+	if len(args) > 1 {
+		ir = str(args[1])
+	} else {
+		sess.w.WriteString("+ \r\n")
+		sess.w.Flush()
+		line, err := sess.r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		ir = strings.TrimSpace(line)
+	}
+
+	decoded, _ := base64.StdEncoding.DecodeString(ir)
+	want := "user=" + sess.s.Username + "\x01auth=Bearer " + sess.s.Token + "\x01\x01"
+	if sess.s.Token == "" || string(decoded) != want {
+		details := base64.StdEncoding.EncodeToString([]byte(`{"status":"401","schemes":"Bearer"}`))
+		sess.w.WriteString("+ " + details + "\r\n")
+		sess.w.Flush()
+		if _, err := sess.r.ReadString('\n'); err != nil {
+			return
+		}
+		sess.reply(tag, "NO", "[AUTHENTICATIONFAILED] invalid credentials")
+		return
+	}
+	sess.authed = true
+	sess.reply(tag, "OK", "AUTHENTICATE completed")
+}
+
+// readCommand reads a command line, asking for the content of literals.
+// It returns the tag, the arguments after it and the line for the log.
+func (sess *session) readCommand() (string, []any, string, error) {
+	var line strings.Builder
+	var fields []any
+	var stack [][]any
+
+	for {
+		text, err := sess.r.ReadString('\n')
+		if err != nil {
+			return "", nil, "", err
+		}
+		text = strings.TrimRight(text, "\r\n")
+
+		literal := -1
+		if i := strings.LastIndexByte(text, '{'); i >= 0 && strings.HasSuffix(text, "}") {
+			n, err := strconv.Atoi(strings.TrimSuffix(text[i+1:len(text)-1], "+"))
+			if err == nil {
+				literal = n
+				text = text[:i]
+			}
+		}
+		line.WriteString(text)
+
+		toks, err := tokenize(text)
+		if err != nil {
+			return "", nil, "", err
+		}
+		for _, tok := range toks {
+			switch tok {
+			case "(":
+				stack = append(stack, fields)
+				fields = nil
+			case ")":
+				if len(stack) == 0 {
+					return "", nil, "", fmt.Errorf("unbalanced parentheses")
+				}
+				list := fields
+				fields = append(stack[len(stack)-1], any(list))
+				stack = stack[:len(stack)-1]
+			default:
+				fields = append(fields, str(tok))
+			}
+		}
+
+		if literal < 0 {
+			break
+		}
+		sess.w.WriteString("+ Ready for literal\r\n")
+		sess.w.Flush()
+		data := make([]byte, literal)
+		if _, err := io.ReadFull(sess.r, data); err != nil {
+			return "", nil, "", err
+		}
+		fields = append(fields, string(data))
+		line.WriteString(quote(string(data)))
+	}
+
+	if len(stack) > 0 {
+		return "", nil, "", fmt.Errorf("unbalanced parentheses")
+	}
+	if len(fields) == 0 {
+		return "", nil, "", nil
+	}
+	tag := str(fields[0])
+	_, logged, _ := strings.Cut(line.String(), " ")
+	return tag, fields[1:], logged, nil
+}
+
+// tokenize splits a command line into atoms, quoted strings (as string)
+// and parentheses (as any("(") and any(")")). Brackets in atoms may contain
+// spaces and parentheses.
+func tokenize(text string) ([]any, error) {
+	var tokens []any
+	for i := 0; i < len(text); {
+		switch c := text[i]; {
+		case c == ' ':
+			i++
+		case c == '(' || c == ')':
+			tokens = append(tokens, any(string(c)))
+			i++
+		case c == '"':
+			var sb strings.Builder
+			i++
+			for i < len(text) && text[i] != '"' {
+				if text[i] == '\\' && i+1 < len(text) {
+					i++
+				}
+				sb.WriteByte(text[i])
+				i++
+			}
+			if i == len(text) {
+				return nil, fmt.Errorf("unterminated quoted string")
+			}
+			i++
+			tokens = append(tokens, quotedString(sb.String()))
+		default:
+			start := i
+			depth := 0
+			for i < len(text) {
+				c := text[i]
+				if c == '[' {
+					depth++
+				} else if c == ']' && depth > 0 {
+					depth--
+				} else if depth == 0 && (c == ' ' || c == '(' || c == ')') {
+					break
+				}
+				i++
+			}
+			tokens = append(tokens, text[start:i])
+		}
+	}
+	return tokens, nil
+}
+
+// quotedString is a quoted string token, so "(" in quotes isn't a list.
+type quotedString string
+
+// str returns a field as a string.
+func str(field any) string {
+	switch v := field.(type) {
+	case string:
+		return v
+	case quotedString:
+		return string(v)
+	}
+	return ""
+}
+
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
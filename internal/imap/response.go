@@ -0,0 +1,243 @@
+package imap
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// maxLiteral limits the size of a literal, such as a message body, read
+// from the server.
+const maxLiteral = 256 << 20
+
+// response is a server response line.
+type response struct {
+	// tag is "*" for untagged responses, "+" for continuation requests,
+	// or the tag of the completed command.
+	tag string
+	// status is OK, NO, BAD, BYE or PREAUTH for status responses, and ""
+	// for data responses.
+	status string
+	// text is the rest of a status response or continuation request,
+	// including any response code.
+	text string
+	// fields are the fields of a data response. Atoms, quoted strings and
+	// literals are strings, NIL is nil and lists are []any.
+	fields []any
+}
+
+func (c *Client) readResponse() (*response, error) {
+	return readResponse(c.r)
+}
+
+func readResponse(r *bufio.Reader) (*response, error) {
+	tag, err := readAtom(r)
+	if err != nil {
+		return nil, err
+	}
+	resp := &response{tag: tag}
+
+	if tag == "+" {
+		resp.text, err = readText(r)
+		return resp, err
+	}
+
+	if err := skipSpace(r); err != nil {
+		return nil, err
+	}
+	b, err := r.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+	if b[0] != '\r' && b[0] != '\n' && b[0] != '(' && b[0] != '"' && b[0] != '{' {
+		word, err := readAtom(r)
+		if err != nil {
+			return nil, err
+		}
+		switch strings.ToUpper(word) {
+		case "OK", "NO", "BAD", "BYE", "PREAUTH":
+			resp.status = strings.ToUpper(word)
+			resp.text, err = readText(r)
+			return resp, err
+		}
+		resp.fields = append(resp.fields, word)
+	}
+
+	rest, err := readFields(r, false)
+	if err != nil {
+		return nil, err
+	}
+	resp.fields = append(resp.fields, rest...)
+	return resp, nil
+}
+
+// readFields reads fields up to the end of the line, or up to the closing
+// parenthesis of a list.
+func readFields(r *bufio.Reader, inList bool) ([]any, error) {
+	var fields []any
+	for {
+		if err := skipSpace(r); err != nil {
+			return nil, err
+		}
+		b, err := r.Peek(1)
+		if err != nil {
+			return nil, err
+		}
+
+		switch b[0] {
+		case '\r', '\n':
+			if inList {
+				return nil, fmt.Errorf("unterminated list in response")
+			}
+			if err := readEOL(r); err != nil {
+				return nil, err
+			}
+			return fields, nil
+		case ')':
+			if !inList {
+				return nil, fmt.Errorf("unexpected ')' in response")
+			}
+			r.ReadByte()
+			return fields, nil
+		case '(':
+			r.ReadByte()
+			list, err := readFields(r, true)
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, list)
+		case '"':
+			s, err := readQuoted(r)
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, s)
+		case '{':
+			s, err := readLiteral(r)
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, s)
+		default:
+			a, err := readAtom(r)
+			if err != nil {
+				return nil, err
+			}
+			if strings.EqualFold(a, "NIL") {
+				fields = append(fields, nil)
+			} else {
+				fields = append(fields, a)
+			}
+		}
+	}
+}
+
+// readAtom reads an atom. Brackets may contain spaces and parentheses, as in
+// BODY[HEADER.FIELDS (FROM TO)].
+func readAtom(r *bufio.Reader) (string, error) {
+	var sb strings.Builder
+	depth := 0
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		switch {
+		case b == '[':
+			depth++
+		case b == ']' && depth > 0:
+			depth--
+		case b == '\r' || b == '\n' || (depth == 0 && (b == ' ' || b == '(' || b == ')')):
+			r.UnreadByte()
+			if sb.Len() == 0 {
+				return "", fmt.Errorf("expected atom in response")
+			}
+			return sb.String(), nil
+		}
+		sb.WriteByte(b)
+	}
+}
+
+func readQuoted(r *bufio.Reader) (string, error) {
+	r.ReadByte() // opening quote
+	var sb strings.Builder
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		switch b {
+		case '"':
+			return sb.String(), nil
+		case '\\':
+			b, err = r.ReadByte()
+			if err != nil {
+				return "", err
+			}
+		case '\r', '\n':
+			return "", fmt.Errorf("unterminated quoted string in response")
+		}
+		sb.WriteByte(b)
+	}
+}
+
+// readLiteral reads a {n} literal: n bytes on the lines after it.
+func readLiteral(r *bufio.Reader) (string, error) {
+	r.ReadByte() // {
+	spec, err := r.ReadString('}')
+	if err != nil {
+		return "", err
+	}
+	n, err := strconv.Atoi(strings.TrimSuffix(strings.TrimSuffix(spec, "}"), "+"))
+	if err != nil || n < 0 || n > maxLiteral {
+		return "", fmt.Errorf("invalid literal {%s in response", spec)
+	}
+	if err := readEOL(r); err != nil {
+		return "", err
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// readText reads the rest of the line.
+func readText(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+func readEOL(r *bufio.Reader) error {
+	b, err := r.ReadByte()
+	if err != nil {
+		return err
+	}
+	if b == '\r' {
+		b, err = r.ReadByte()
+		if err != nil {
+			return err
+		}
+	}
+	if b != '\n' {
+		return fmt.Errorf("expected end of line in response")
+	}
+	return nil
+}
+
+func skipSpace(r *bufio.Reader) error {
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return err
+		}
+		if b != ' ' {
+			return r.UnreadByte()
+		}
+	}
+}
@@ -0,0 +1,199 @@
+// Package index is an inverted index over threads with BM25 ranking, for
+// searching a local mirror without the network.
+package index
+
+import (
+	"cmp"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/bentsolheim/gmail-cli/internal/gmail"
+)
+
+// version is bumped when the saved format or the tokenizer changes, so old
+// indexes are rebuilt.
+const version = 1
+
+// BM25 parameters: k1 controls term frequency saturation and b how much
+// scores are normalized by document length.
+const (
+	k1 = 1.2
+	b  = 0.75
+)
+
+// Field weights. A term in the subject counts three times as much as one in
+// the body.
+const (
+	subjectWeight     = 3
+	participantWeight = 2
+	attachmentWeight  = 2
+	bodyWeight        = 1
+)
+
+// Index maps terms to the threads containing them.
+type Index struct {
+	// Version is the format version the index was built with.
+	Version int
+	// Tag identifies the data the index was built from, e.g. a history ID,
+	// so callers can tell whether it is stale.
+	Tag string
+	// Docs holds each thread's ID and length in tokens.
+	Docs []Doc
+	// Postings maps each term to the threads containing it.
+	Postings map[string][]Posting
+	// TotalLength is the sum of all document lengths.
+	TotalLength int
+}
+
+// Doc is an indexed thread.
+type Doc struct {
+	ThreadID string
+	Length   int
+}
+
+// Posting records a term's weighted frequency in a thread.
+type Posting struct {
+	Doc  int
+	Freq float64
+}
+
+// Hit is a thread matching a query.
+type Hit struct {
+	ThreadID string
+	Score    float64
+}
+
+// Build indexes threads by subject, body, participants and attachment names.
+func Build(threads []*gmail.Thread, tag string) *Index {
+	idx := &Index{
+		Version:  version,
+		Tag:      tag,
+		Postings: make(map[string][]Posting),
+	}
+
+	for _, thread := range threads {
+		freqs := make(map[string]float64)
+		length := 0
+		add := func(text string, weight float64) {
+			for _, token := range Tokenize(text) {
+				freqs[token] += weight
+				length++
+			}
+		}
+
+		add(thread.Subject, subjectWeight)
+		for _, p := range thread.Participants {
+			add(p, participantWeight)
+		}
+		for _, msg := range thread.Messages {
+			add(msg.From, participantWeight)
+			add(msg.Body, bodyWeight)
+			for _, att := range msg.Attachments {
+				add(att.Filename, attachmentWeight)
+			}
+		}
+
+		doc := len(idx.Docs)
+		idx.Docs = append(idx.Docs, Doc{ThreadID: thread.ID, Length: length})
+		idx.TotalLength += length
+		for term, freq := range freqs {
+			idx.Postings[term] = append(idx.Postings[term], Posting{Doc: doc, Freq: freq})
+		}
+	}
+
+	return idx
+}
+
+// Search ranks the threads containing any of the query's terms by BM25,
+// best first, returning up to limit hits (zero means no limit).
+func (idx *Index) Search(query string, limit int) []Hit {
+	if len(idx.Docs) == 0 {
+		return nil
+	}
+
+	n := float64(len(idx.Docs))
+	avgLength := float64(idx.TotalLength) / n
+	if avgLength == 0 {
+		avgLength = 1
+	}
+
+	scores := make(map[int]float64)
+	terms := Tokenize(query)
+	slices.Sort(terms)
+	for _, term := range slices.Compact(terms) {
+		postings := idx.Postings[term]
+		if len(postings) == 0 {
+			continue
+		}
+		df := float64(len(postings))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		for _, p := range postings {
+			norm := k1 * (1 - b + b*float64(idx.Docs[p.Doc].Length)/avgLength)
+			scores[p.Doc] += idf * p.Freq * (k1 + 1) / (p.Freq + norm)
+		}
+	}
+
+	hits := make([]Hit, 0, len(scores))
+	for doc, score := range scores {
+		hits = append(hits, Hit{ThreadID: idx.Docs[doc].ThreadID, Score: score})
+	}
+	slices.SortFunc(hits, func(a, b Hit) int {
+		if c := cmp.Compare(b.Score, a.Score); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ThreadID, b.ThreadID)
+	})
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits
+}
+
+// Load reads an index saved with Save. It returns nil without an error if
+// there is no index, it was built by an older version, or it can't be
+// decoded, such as when it was truncated; an index can always be rebuilt.
+func Load(path string) (*Index, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open index: %w", err)
+	}
+	defer f.Close()
+
+	idx := &Index{}
+	if err := gob.NewDecoder(f).Decode(idx); err != nil {
+		return nil, nil
+	}
+	if idx.Version != version {
+		return nil, nil
+	}
+	return idx, nil
+}
+
+// Save writes the index to path, replacing any previous one atomically.
+func (idx *Index) Save(path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".index-*")
+	if err != nil {
+		return fmt.Errorf("failed to save index: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := gob.NewEncoder(tmp).Encode(idx); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save index: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save index: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to save index: %w", err)
+	}
+	return nil
+}
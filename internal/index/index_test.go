@@ -0,0 +1,170 @@
+package index
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/bentsolheim/gmail-cli/internal/gmail"
+)
+
+func testThreads() []*gmail.Thread {
+	return []*gmail.Thread{
+		{
+			ID:      "t1",
+			Subject: "Conversion factors",
+			Messages: []gmail.Message{
+				{From: "Felipe Garcia <felipe@example.com>", Body: "Here are the conversion factors for the pipeline."},
+			},
+		},
+		{
+			ID:      "t2",
+			Subject: "Lunch on Friday",
+			Messages: []gmail.Message{
+				{From: "Carol <carol@example.com>", Body: "Tacos? We could also talk about the pipeline."},
+			},
+		},
+		{
+			ID:           "t3",
+			Subject:      "Quarterly report",
+			Participants: []string{"dave@example.com"},
+			Messages: []gmail.Message{
+				{
+					From:        "Dave <dave@example.com>",
+					Body:        "Attached.",
+					Attachments: []gmail.Attachment{{Filename: "conversion-report.xlsx"}},
+				},
+			},
+		},
+	}
+}
+
+func hitIDs(hits []Hit) []string {
+	var ids []string
+	for _, hit := range hits {
+		ids = append(ids, hit.ThreadID)
+	}
+	return ids
+}
+
+func TestSearch(t *testing.T) {
+	idx := Build(testThreads(), "1")
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		// subject matches outrank attachment names
+		{query: "conversion", want: []string{"t1", "t3"}},
+		{query: "CONVERSION factors", want: []string{"t1", "t3"}},
+		// any word matches; threads with more of them rank higher
+		{query: "pipeline tacos", want: []string{"t2", "t1"}},
+		{query: "felipe", want: []string{"t1"}},
+		{query: "xlsx", want: []string{"t3"}},
+		{query: "nothing-matches", want: nil},
+		{query: "", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			hits := idx.Search(tt.query, 0)
+			if got := hitIDs(hits); !slices.Equal(got, tt.want) {
+				t.Errorf("Search(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+			for i := 1; i < len(hits); i++ {
+				if hits[i].Score > hits[i-1].Score {
+					t.Errorf("Search(%q) not sorted by score: %v", tt.query, hits)
+				}
+			}
+		})
+	}
+
+	if got := idx.Search("conversion", 1); len(got) != 1 {
+		t.Errorf("Search() with limit 1 returned %d hits", len(got))
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.gob")
+
+	idx, err := Load(path)
+	if idx != nil || err != nil {
+		t.Fatalf("Load() of missing index = %v, %v, want nil, nil", idx, err)
+	}
+
+	if err := Build(testThreads(), "42").Save(path); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	idx, err = Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if idx.Tag != "42" {
+		t.Errorf("Tag = %q, want 42", idx.Tag)
+	}
+	if got := hitIDs(idx.Search("pipeline", 0)); len(got) != 2 {
+		t.Errorf("Search() after Load() = %v, want 2 hits", got)
+	}
+
+	// A truncated index reads as no index
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data[:len(data)/2], 0600); err != nil {
+		t.Fatal(err)
+	}
+	idx, err = Load(path)
+	if idx != nil || err != nil {
+		t.Errorf("Load() of truncated index = %v, %v, want nil, nil", idx, err)
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	got := Tokenize("Re: Blåbær-syltetøy, Q4 (v2.1)!")
+	want := []string{"re", "blåbær", "syltetøy", "q4", "v2", "1"}
+	if !slices.Equal(got, want) {
+		t.Errorf("Tokenize() = %q, want %q", got, want)
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		query string
+		words int
+		want  string
+	}{
+		{
+			name:  "highlights matches",
+			text:  "Here are the conversion factors.",
+			query: "Conversion",
+			words: 10,
+			want:  "Here are the **conversion** factors.",
+		},
+		{
+			name:  "window with most distinct terms",
+			text:  "pipeline one two three four five six seven conversion pipeline factors end",
+			query: "conversion pipeline factors",
+			words: 4,
+			want:  "...seven **conversion** **pipeline** **factors**...",
+		},
+		{
+			name:  "no match",
+			text:  "Tacos on Friday",
+			query: "pipeline",
+			words: 10,
+			want:  "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Snippet(tt.text, tt.query, tt.words); got != tt.want {
+				t.Errorf("Snippet() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
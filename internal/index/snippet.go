@@ -0,0 +1,91 @@
+package index
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// snippetContext is the number of words shown before a snippet's first match.
+const snippetContext = 3
+
+// Tokenize splits text into lowercase terms of letters and digits.
+func Tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// Snippet returns the run of up to maxWords words of text containing the
+// most distinct query terms, with matching words highlighted as **word**.
+// It returns "" if text contains none of the terms.
+func Snippet(text, query string, maxWords int) string {
+	terms := Tokenize(query)
+	words := strings.Fields(text)
+
+	// matches[i] lists the query terms word i contains
+	matches := make([][]string, len(words))
+	found := false
+	for i, word := range words {
+		for _, token := range Tokenize(word) {
+			if slices.Contains(terms, token) && !slices.Contains(matches[i], token) {
+				matches[i] = append(matches[i], token)
+				found = true
+			}
+		}
+	}
+	if !found {
+		return ""
+	}
+
+	// Pick the window with the most distinct terms, then the most matches
+	best, bestDistinct, bestCount := 0, -1, -1
+	for start := range words {
+		if matches[start] == nil {
+			continue
+		}
+		end := min(start+maxWords, len(words))
+		distinct := map[string]bool{}
+		count := 0
+		for _, m := range matches[start:end] {
+			for _, term := range m {
+				distinct[term] = true
+			}
+			if m != nil {
+				count++
+			}
+		}
+		if len(distinct) > bestDistinct || (len(distinct) == bestDistinct && count > bestCount) {
+			best, bestDistinct, bestCount = start, len(distinct), count
+		}
+	}
+
+	// Show a few words before the first match if the window has room
+	last := best
+	for i := best; i < min(best+maxWords, len(words)); i++ {
+		if matches[i] != nil {
+			last = i
+		}
+	}
+	start := max(0, best-min(snippetContext, maxWords-(last-best+1)))
+	end := min(start+maxWords, len(words))
+
+	var sb strings.Builder
+	if start > 0 {
+		sb.WriteString("...")
+	}
+	for i := start; i < end; i++ {
+		if i > start {
+			sb.WriteByte(' ')
+		}
+		if matches[i] != nil {
+			sb.WriteString("**" + words[i] + "**")
+		} else {
+			sb.WriteString(words[i])
+		}
+	}
+	if end < len(words) {
+		sb.WriteString("...")
+	}
+	return sb.String()
+}
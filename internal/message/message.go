@@ -0,0 +1,248 @@
+// Package message parses RFC 822 messages with MIME parts into their text
+// body and attachments, for backends that read original messages rather than
+// the Gmail API.
+package message
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bentsolheim/gmail-cli/internal/gmail"
+)
+
+// Message is a parsed message.
+type Message struct {
+	Header mail.Header
+	// MessageID is the Message-ID without angle brackets.
+	MessageID string
+	// InReplyTo and References are message IDs without angle brackets.
+	InReplyTo  []string
+	References []string
+	// Subject, From, To and Cc are decoded header values.
+	Subject string
+	From    string
+	To      string
+	Cc      string
+	// Date is zero if the Date header is missing or invalid.
+	Date time.Time
+	// Body is the text/plain part, or the text/html part stripped of tags.
+	Body        string
+	Attachments []Attachment
+}
+
+// Attachment is a MIME part with a filename.
+type Attachment struct {
+	// ID is the part's position in the MIME tree, e.g. "2" or "1.3".
+	ID       string
+	Filename string
+	MimeType string
+	Data     []byte
+}
+
+var headerDecoder = &mime.WordDecoder{}
+
+// Parse parses a message. Malformed MIME parts are skipped rather than
+// failing the whole message.
+func Parse(data []byte) (*Message, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse message: %w", err)
+	}
+	body, err := io.ReadAll(msg.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read message: %w", err)
+	}
+
+	m := &Message{
+		Header:     msg.Header,
+		MessageID:  firstID(msg.Header.Get("Message-Id")),
+		InReplyTo:  messageIDs(msg.Header.Get("In-Reply-To")),
+		References: messageIDs(msg.Header.Get("References")),
+		Subject:    decodeHeader(msg.Header.Get("Subject")),
+		From:       decodeHeader(msg.Header.Get("From")),
+		To:         decodeHeader(msg.Header.Get("To")),
+		Cc:         decodeHeader(msg.Header.Get("Cc")),
+	}
+	if date, err := msg.Header.Date(); err == nil {
+		m.Date = date
+	}
+
+	p := &parser{msg: m}
+	p.walk(textproto.MIMEHeader(msg.Header), body, "")
+	m.Body = p.plain
+	if m.Body == "" && p.html != "" {
+		m.Body = gmail.StripHTML(p.html)
+	}
+	return m, nil
+}
+
+// Attachment returns the attachment with the given ID.
+func (m *Message) Attachment(id string) (*Attachment, bool) {
+	for i := range m.Attachments {
+		if m.Attachments[i].ID == id {
+			return &m.Attachments[i], true
+		}
+	}
+	return nil, false
+}
+
+// parser collects the text bodies and attachments of a MIME tree.
+type parser struct {
+	msg   *Message
+	plain string
+	html  string
+}
+
+// walk visits a MIME part. path is the part's ID, "" for the top level.
+func (p *parser) walk(header textproto.MIMEHeader, body []byte, path string) {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mediaType, params = "text/plain", map[string]string{}
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		reader := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+		for i := 1; ; i++ {
+			// NextRawPart keeps the transfer encoding for decode
+			part, err := reader.NextRawPart()
+			if err != nil {
+				return
+			}
+			partBody, err := io.ReadAll(part)
+			if err != nil {
+				return
+			}
+			p.walk(part.Header, partBody, childPath(path, i))
+		}
+	}
+
+	data := decodeTransfer(header.Get("Content-Transfer-Encoding"), body)
+
+	if filename := partFilename(header, params); filename != "" {
+		p.msg.Attachments = append(p.msg.Attachments, Attachment{
+			ID:       partID(path),
+			Filename: filename,
+			MimeType: mediaType,
+			Data:     data,
+		})
+		return
+	}
+
+	switch mediaType {
+	case "text/plain":
+		if p.plain == "" {
+			p.plain = gmail.DecodeCharset(data, params["charset"])
+		}
+	case "text/html":
+		if p.html == "" {
+			p.html = gmail.DecodeCharset(data, params["charset"])
+		}
+	case "message/rfc822":
+		p.msg.Attachments = append(p.msg.Attachments, Attachment{
+			ID:       partID(path),
+			Filename: "message.eml",
+			MimeType: mediaType,
+			Data:     data,
+		})
+	}
+}
+
+// childPath returns the ID of the nth part of a multipart part.
+func childPath(path string, n int) string {
+	if path == "" {
+		return strconv.Itoa(n)
+	}
+	return path + "." + strconv.Itoa(n)
+}
+
+// partID returns the ID of a part, "1" for a single-part message.
+func partID(path string) string {
+	if path == "" {
+		return "1"
+	}
+	return path
+}
+
+// partFilename returns the filename from Content-Disposition or the
+// Content-Type name parameter, or "" if the part has none.
+func partFilename(header textproto.MIMEHeader, params map[string]string) string {
+	if _, dparams, err := mime.ParseMediaType(header.Get("Content-Disposition")); err == nil {
+		if name := dparams["filename"]; name != "" {
+			return decodeHeader(name)
+		}
+	}
+	return decodeHeader(params["name"])
+}
+
+// decodeTransfer decodes a base64 or quoted-printable body. Other encodings
+// are returned as is.
+func decodeTransfer(encoding string, body []byte) []byte {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		cleaned := bytes.Map(func(r rune) rune {
+			if r == '\r' || r == '\n' || r == ' ' || r == '\t' {
+				return -1
+			}
+			return r
+		}, body)
+		decoded := make([]byte, base64.StdEncoding.DecodedLen(len(cleaned)))
+		// On corrupt input, keep what decoded before the error
+		n, _ := base64.StdEncoding.Decode(decoded, cleaned)
+		return decoded[:n]
+	case "quoted-printable":
+		decoded, err := io.ReadAll(quotedprintable.NewReader(bytes.NewReader(body)))
+		if err != nil {
+			return body
+		}
+		return decoded
+	}
+	return body
+}
+
+// decodeHeader decodes RFC 2047 encoded words, keeping the value as is if
+// it can't be decoded.
+func decodeHeader(value string) string {
+	decoded, err := headerDecoder.DecodeHeader(value)
+	if err != nil {
+		return value
+	}
+	return decoded
+}
+
+// messageIDs returns the message IDs in a References or In-Reply-To header.
+func messageIDs(value string) []string {
+	var ids []string
+	for {
+		start := strings.IndexByte(value, '<')
+		if start < 0 {
+			return ids
+		}
+		end := strings.IndexByte(value[start:], '>')
+		if end < 0 {
+			return ids
+		}
+		if id := strings.TrimSpace(value[start+1 : start+end]); id != "" {
+			ids = append(ids, id)
+		}
+		value = value[start+end+1:]
+	}
+}
+
+// firstID returns the first message ID in a header, or the trimmed value if
+// it has no angle brackets.
+func firstID(value string) string {
+	if ids := messageIDs(value); len(ids) > 0 {
+		return ids[0]
+	}
+	return strings.TrimSpace(value)
+}
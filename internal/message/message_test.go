@@ -0,0 +1,205 @@
+package message
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bentsolheim/gmail-cli/internal/gmail"
+	gmailapi "google.golang.org/api/gmail/v1"
+)
+
+func TestParse(t *testing.T) {
+	raw := strings.ReplaceAll(`Message-ID: <m1@example.com>
+In-Reply-To: <m0@example.com>
+References: <root@example.com>
+ <m0@example.com>
+From: =?UTF-8?Q?J=C3=B8rgen_Hansen?= <jorgen@example.no>
+To: Bob <bob@example.com>
+Cc: carol@example.com
+Subject: =?UTF-8?B?QmzDpWLDpnI=?=
+Date: Thu, 11 Dec 2025 14:05:00 +0100
+MIME-Version: 1.0
+Content-Type: multipart/mixed; boundary="outer"
+
+--outer
+Content-Type: multipart/alternative; boundary="inner"
+
+--inner
+Content-Type: text/plain; charset=utf-8
+Content-Transfer-Encoding: quoted-printable
+
+Hei, her er tallene for bl=C3=A5b=C3=A6r.
+--inner
+Content-Type: text/html; charset=utf-8
+
+<p>Hei</p>
+--inner--
+--outer
+Content-Type: application/pdf; name="report.pdf"
+Content-Disposition: attachment; filename="report.pdf"
+Content-Transfer-Encoding: base64
+
+JVBERi0x
+LjQK
+--outer
+Content-Type: image/png; name="=?UTF-8?Q?bl=C3=A5.png?="
+Content-Transfer-Encoding: base64
+
+iVBORw==
+--outer--
+`, "\n", "\r\n")
+
+	msg, err := Parse([]byte(raw))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	checks := []struct {
+		name, got, want string
+	}{
+		{"MessageID", msg.MessageID, "m1@example.com"},
+		{"From", msg.From, "Jørgen Hansen <jorgen@example.no>"},
+		{"To", msg.To, "Bob <bob@example.com>"},
+		{"Cc", msg.Cc, "carol@example.com"},
+		{"Subject", msg.Subject, "Blåbær"},
+		{"Body", msg.Body, "Hei, her er tallene for blåbær."},
+	}
+	for _, c := range checks {
+		if c.got != c.want {
+			t.Errorf("%s = %q, want %q", c.name, c.got, c.want)
+		}
+	}
+	if want := []string{"m0@example.com"}; !slices.Equal(msg.InReplyTo, want) {
+		t.Errorf("InReplyTo = %v, want %v", msg.InReplyTo, want)
+	}
+	if want := []string{"root@example.com", "m0@example.com"}; !slices.Equal(msg.References, want) {
+		t.Errorf("References = %v, want %v", msg.References, want)
+	}
+	if want := time.Date(2025, 12, 11, 13, 5, 0, 0, time.UTC); !msg.Date.Equal(want) {
+		t.Errorf("Date = %v, want %v", msg.Date, want)
+	}
+
+	if len(msg.Attachments) != 2 {
+		t.Fatalf("got %d attachments, want 2", len(msg.Attachments))
+	}
+	pdf := msg.Attachments[0]
+	if pdf.ID != "2" || pdf.Filename != "report.pdf" || pdf.MimeType != "application/pdf" || string(pdf.Data) != "%PDF-1.4\n" {
+		t.Errorf("first attachment = %+v", pdf)
+	}
+	if png := msg.Attachments[1]; png.ID != "3" || png.Filename != "blå.png" {
+		t.Errorf("second attachment = %+v, want ID 3 named blå.png", png)
+	}
+	if att, ok := msg.Attachment("3"); !ok || att.Filename != "blå.png" {
+		t.Errorf("Attachment(3) = %v, %v", att, ok)
+	}
+	if _, ok := msg.Attachment("9"); ok {
+		t.Error("Attachment(9) found, want not found")
+	}
+}
+
+func TestParse_HTMLOnly(t *testing.T) {
+	raw := "Subject: Hi\r\nContent-Type: text/html\r\n\r\n<p>Hello &amp; welcome</p>\r\n"
+	msg, err := Parse([]byte(raw))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if want := "Hello & welcome\r\n"; msg.Body != want {
+		t.Errorf("Body = %q, want %q", msg.Body, want)
+	}
+}
+
+func TestParse_NoHeaders(t *testing.T) {
+	if _, err := Parse([]byte("not a message")); err == nil {
+		t.Error("Parse() succeeded, want error")
+	}
+}
+
+func TestPayload(t *testing.T) {
+	raw := strings.ReplaceAll(`Message-ID: <m1@example.com>
+From: =?UTF-8?Q?J=C3=B8rgen_Hansen?= <jorgen@example.no>
+Subject: Report
+Content-Type: multipart/mixed; boundary="outer"
+
+--outer
+Content-Type: text/plain; charset=utf-8
+Content-Transfer-Encoding: quoted-printable
+
+Tallene for bl=C3=A5b=C3=A6r.
+--outer
+Content-Type: application/pdf; name="report.pdf"
+Content-Transfer-Encoding: base64
+
+JVBERi0x
+--outer--
+`, "\n", "\r\n")
+
+	payload, err := Payload([]byte(raw))
+	if err != nil {
+		t.Fatalf("Payload() error = %v", err)
+	}
+	if payload.MimeType != "multipart/mixed" || len(payload.Parts) != 2 {
+		t.Fatalf("Payload() = %s with %d parts, want multipart/mixed with 2", payload.MimeType, len(payload.Parts))
+	}
+
+	// The Gmail client reads it like a message from the API
+	thread := gmail.ParseThread("t1", []*gmailapi.Message{{Id: "m1", Payload: payload}})
+	msg := thread.Messages[0]
+	if msg.From != "Jørgen Hansen <jorgen@example.no>" || msg.Body != "Tallene for blåbær." {
+		t.Errorf("ParseThread() message = %+v", msg)
+	}
+	if len(msg.Attachments) != 1 || msg.Attachments[0].ID != "2" || msg.Attachments[0].Size != 6 {
+		t.Fatalf("Attachments = %+v, want report.pdf with ID 2", msg.Attachments)
+	}
+
+	parsed, err := Parse([]byte(raw))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if att, ok := parsed.Attachment(msg.Attachments[0].ID); !ok || att.Filename != "report.pdf" {
+		t.Errorf("Attachment(%q) = %+v, want report.pdf", msg.Attachments[0].ID, att)
+	}
+}
+
+func TestParse_Charsets(t *testing.T) {
+	tests := []struct {
+		file string
+		want string
+	}{
+		{file: "iso-8859-1.eml", want: "Hei! Blåbærsyltetøy på brødskiva."},
+		{file: "windows-1252.eml", want: "Pris: 100 € – «tilbud» “nå”."},
+		{file: "windows-1252-html.eml", want: "Ærlig talt, Øystein – det går fint."},
+		{file: "iso-2022-jp.eml", want: "こんにちは、世界。"},
+		{file: "koi8-r.eml", want: "Привет, мир!"},
+		{file: "invalid-utf-8.eml", want: "Grøt og � smult"},
+		{file: "unknown-charset.eml", want: "Blåbær"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			msg, err := Parse(data)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if got := strings.TrimSpace(msg.Body); got != tt.want {
+				t.Errorf("Body = %q, want %q", got, tt.want)
+			}
+
+			// Bodies read through the Gmail API are decoded the same way
+			payload, err := Payload(data)
+			if err != nil {
+				t.Fatalf("Payload() error = %v", err)
+			}
+			thread := gmail.ParseThread("t1", []*gmailapi.Message{{Id: "m1", Payload: payload}})
+			if got := strings.TrimSpace(thread.Messages[0].Body); got != tt.want {
+				t.Errorf("ParseThread() body = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
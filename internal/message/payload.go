@@ -0,0 +1,98 @@
+package message
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"slices"
+	"strings"
+
+	gmailapi "google.golang.org/api/gmail/v1"
+)
+
+// Payload converts a message into the MIME tree of the Gmail API's full
+// format. Header values are decoded. Text parts carry their decoded content;
+// attachments carry an attachment ID instead, the ID accepted by
+// Message.Attachment.
+func Payload(data []byte) (*gmailapi.MessagePart, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse message: %w", err)
+	}
+	body, err := io.ReadAll(msg.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read message: %w", err)
+	}
+	return payloadPart(textproto.MIMEHeader(msg.Header), body, ""), nil
+}
+
+// payloadPart converts a MIME part and its children. path is the part's ID,
+// "" for the top level.
+func payloadPart(header textproto.MIMEHeader, body []byte, path string) *gmailapi.MessagePart {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mediaType, params = "text/plain", map[string]string{}
+	}
+
+	part := &gmailapi.MessagePart{
+		PartId:   path,
+		MimeType: mediaType,
+		Headers:  payloadHeaders(header),
+		Body:     &gmailapi.MessagePartBody{},
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		reader := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+		for i := 1; ; i++ {
+			child, err := reader.NextRawPart()
+			if err != nil {
+				return part
+			}
+			childBody, err := io.ReadAll(child)
+			if err != nil {
+				return part
+			}
+			part.Parts = append(part.Parts, payloadPart(child.Header, childBody, childPath(path, i)))
+		}
+	}
+
+	data := decodeTransfer(header.Get("Content-Transfer-Encoding"), body)
+	part.Body.Size = int64(len(data))
+
+	// The same parts are attachments as in Parse
+	filename := partFilename(header, params)
+	if filename == "" && mediaType == "message/rfc822" {
+		filename = "message.eml"
+	}
+	if filename != "" {
+		part.Filename = filename
+		part.Body.AttachmentId = partID(path)
+		return part
+	}
+
+	part.Body.Data = base64.URLEncoding.EncodeToString(data)
+	return part
+}
+
+// payloadHeaders returns the headers of a part sorted by name, with decoded
+// values.
+func payloadHeaders(header textproto.MIMEHeader) []*gmailapi.MessagePartHeader {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	var headers []*gmailapi.MessagePartHeader
+	for _, name := range names {
+		for _, value := range header[name] {
+			headers = append(headers, &gmailapi.MessagePartHeader{Name: name, Value: decodeHeader(value)})
+		}
+	}
+	return headers
+}
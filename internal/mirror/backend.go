@@ -0,0 +1,39 @@
+package mirror
+
+import (
+	"context"
+	"errors"
+
+	"github.com/bentsolheim/gmail-cli/internal/backend"
+	"github.com/bentsolheim/gmail-cli/internal/gmail"
+)
+
+// ErrNoAttachments is returned when downloading an attachment from the
+// mirror, which only keeps message text.
+var ErrNoAttachments = errors.New("attachments are not mirrored")
+
+var _ backend.Backend = (*Store)(nil)
+
+// SearchThreads implements backend.Backend with Search.
+func (s *Store) SearchThreads(ctx context.Context, query string, opts gmail.SearchOptions) (*gmail.SearchResult, error) {
+	if opts.PageToken != "" {
+		return nil, backend.ErrPageToken
+	}
+	return s.Search(query, opts.Limit)
+}
+
+// GetThread implements backend.Backend with Thread.
+func (s *Store) GetThread(ctx context.Context, threadID string) (*gmail.Thread, error) {
+	thread, err := s.Thread(threadID)
+	if err != nil {
+		return nil, err
+	}
+	thread.Mailbox = s.Mailbox
+	return thread, nil
+}
+
+// DownloadAttachment implements backend.Backend. It always fails with
+// ErrNoAttachments.
+func (s *Store) DownloadAttachment(ctx context.Context, messageID, attachmentID string) ([]byte, error) {
+	return nil, ErrNoAttachments
+}
@@ -0,0 +1,59 @@
+package mirror
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+
+	"github.com/bentsolheim/gmail-cli/internal/gmail"
+	"github.com/bentsolheim/gmail-cli/internal/query"
+)
+
+// Search returns summaries of the threads with a message matching the query,
+// newest first, up to limit threads (zero means no limit).
+//
+// Queries use Gmail's search syntax as supported by package query. As in
+// Gmail, the whole query must match a single message.
+func (s *Store) Search(queryText string, limit int64) (*gmail.SearchResult, error) {
+	if s.state.HistoryID == 0 {
+		return nil, ErrNotSynced
+	}
+
+	q, err := query.Parse(queryText)
+	if err != nil {
+		return nil, err
+	}
+
+	threads, err := s.threads()
+	if err != nil {
+		return nil, err
+	}
+
+	// Like Gmail, order by when the newest message was received
+	var matched []string
+	for threadID, messages := range threads {
+		thread := gmail.ParseThread(threadID, messages)
+		s.resolveLabels(thread)
+		if q.Match(thread) {
+			matched = append(matched, threadID)
+		}
+	}
+	received := func(threadID string) int64 {
+		return threads[threadID][len(threads[threadID])-1].InternalDate
+	}
+	slices.SortFunc(matched, func(a, b string) int {
+		if c := cmp.Compare(received(b), received(a)); c != 0 {
+			return c
+		}
+		return strings.Compare(a, b)
+	})
+	if limit > 0 && int64(len(matched)) > limit {
+		matched = matched[:limit]
+	}
+
+	summaries := make([]gmail.ThreadSummary, 0, len(matched))
+	for _, threadID := range matched {
+		summaries = append(summaries, gmail.SummarizeThread(threadID, threads[threadID]))
+	}
+	return &gmail.SearchResult{Threads: summaries}, nil
+}
@@ -0,0 +1,82 @@
+package mirror
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSearch(t *testing.T) {
+	day := func(s string) int64 {
+		d, _ := time.ParseInLocation("2006-01-02", s, time.Local)
+		return d.Add(12 * time.Hour).UnixMilli()
+	}
+	fake := newFakeMailbox(
+		testMessage("m1", "t1", "Felipe Garcia <felipe@example.com>", "Conversion plan", "Let's meet on Monday", day("2025-01-10"), "INBOX", "Label_1", "UNREAD"),
+		testMessage("m2", "t1", "Bob <bob@example.com>", "Re: Conversion plan", "Sounds good", day("2025-01-11"), "INBOX"),
+		testMessage("m3", "t2", "Carol <carol@example.com>", "Lunch", "Tacos on Friday?", day("2025-02-01"), "STARRED"),
+	)
+	store, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if _, err := store.Sync(context.Background(), newTestClient(t, fake), SyncOptions{}); err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{query: "", want: []string{"t2", "t1"}},
+		{query: "from:felipe", want: []string{"t1"}},
+		{query: "FROM:Felipe", want: []string{"t1"}},
+		{query: "to:bob", want: []string{"t2", "t1"}},
+		{query: "subject:lunch", want: []string{"t2"}},
+		{query: "tacos", want: []string{"t2"}},
+		{query: `"meet on monday"`, want: []string{"t1"}},
+		{query: "-tacos", want: []string{"t1"}},
+		{query: "label:projects-conversion", want: []string{"t1"}},
+		{query: "label:Projects/Conversion", want: []string{"t1"}},
+		{query: "is:unread", want: []string{"t1"}},
+		{query: "is:starred", want: []string{"t2"}},
+		{query: "in:inbox", want: []string{"t1"}},
+		{query: "after:2025/01/11", want: []string{"t2", "t1"}},
+		{query: "before:2025/01/11", want: []string{"t1"}},
+		{query: "after:2025/01/20 before:2025/03/01", want: []string{"t2"}},
+		{query: "tacos OR sounds", want: []string{"t2", "t1"}},
+		{query: "subject:(conversion plan) -from:bob", want: []string{"t1"}},
+		// all terms must match the same message
+		{query: "from:felipe sounds", want: nil},
+		{query: "has:attachment", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			result, err := store.Search(tt.query, 0)
+			if err != nil {
+				t.Fatalf("Search() error = %v", err)
+			}
+			var got []string
+			for _, thread := range result.Threads {
+				got = append(got, thread.ID)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Search(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+
+	result, err := store.Search("", 1)
+	if err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+	if len(result.Threads) != 1 {
+		t.Errorf("Search() with limit 1 returned %d threads", len(result.Threads))
+	}
+
+	if _, err := store.Search("after:yesterday", 0); err == nil {
+		t.Error("Search() with invalid date succeeded, want error")
+	}
+}
@@ -0,0 +1,92 @@
+package mirror
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/bentsolheim/gmail-cli/internal/gmail"
+	"github.com/bentsolheim/gmail-cli/internal/index"
+	"github.com/bentsolheim/gmail-cli/internal/output"
+)
+
+// snippetWords is the length of search result snippets in words.
+const snippetWords = 24
+
+// RankedSearch returns summaries of the threads containing any of the
+// query's words, most relevant first by BM25, with scores and snippets. It
+// returns up to limit threads (zero means no limit).
+func (s *Store) RankedSearch(query string, limit int64) (*gmail.SearchResult, error) {
+	if s.state.HistoryID == 0 {
+		return nil, ErrNotSynced
+	}
+
+	idx, err := s.index()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &gmail.SearchResult{}
+	for _, hit := range idx.Search(query, int(limit)) {
+		messages, err := s.threadMessages(hit.ThreadID)
+		if err != nil {
+			return nil, err
+		}
+
+		summary := gmail.SummarizeThread(hit.ThreadID, messages)
+		summary.Score = hit.Score
+		summary.Snippet = snippet(gmail.ParseThread(hit.ThreadID, messages), query)
+		result.Threads = append(result.Threads, summary)
+	}
+	return result, nil
+}
+
+// snippet returns the best excerpt of a thread for query: from the new text
+// of its messages, or else its subject.
+func snippet(thread *gmail.Thread, query string) string {
+	var bodies []string
+	for _, msg := range thread.Messages {
+		bodies = append(bodies, output.StripQuotedContent(msg.Body))
+	}
+	if s := index.Snippet(strings.Join(bodies, "\n"), query, snippetWords); s != "" {
+		return s
+	}
+	return index.Snippet(thread.Subject, query, snippetWords)
+}
+
+// index returns the search index, rebuilding it if the mirror has changed
+// since it was built.
+func (s *Store) index() (*index.Index, error) {
+	idx, err := index.Load(filepath.Join(s.dir, indexFile))
+	if err != nil {
+		return nil, err
+	}
+	if idx != nil && idx.Tag == s.indexTag() {
+		return idx, nil
+	}
+	return s.buildIndex()
+}
+
+// buildIndex indexes every thread in the mirror and saves the index.
+func (s *Store) buildIndex() (*index.Index, error) {
+	threads, err := s.threads()
+	if err != nil {
+		return nil, err
+	}
+
+	parsed := make([]*gmail.Thread, 0, len(threads))
+	for threadID, messages := range threads {
+		parsed = append(parsed, gmail.ParseThread(threadID, messages))
+	}
+
+	idx := index.Build(parsed, s.indexTag())
+	if err := idx.Save(filepath.Join(s.dir, indexFile)); err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+// indexTag identifies the mirror contents an index was built from.
+func (s *Store) indexTag() string {
+	return strconv.FormatUint(s.state.Revision, 10)
+}
@@ -0,0 +1,91 @@
+package mirror
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bentsolheim/gmail-cli/internal/index"
+)
+
+func TestRankedSearch(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeMailbox(
+		testMessage("m1", "t1", "Felipe Garcia <felipe@example.com>", "Conversion factors", "Here are the conversion factors for the pipeline.", 1000),
+		testMessage("m2", "t2", "Carol <carol@example.com>", "Lunch", "Tacos on Friday? Then the pipeline review.", 2000),
+	)
+	client := newTestClient(t, fake)
+	dir := t.TempDir()
+
+	store, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if _, err := store.RankedSearch("pipeline", 0); err != ErrNotSynced {
+		t.Errorf("RankedSearch() before sync error = %v, want ErrNotSynced", err)
+	}
+	if _, err := store.Sync(ctx, client, SyncOptions{}); err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+
+	result, err := store.RankedSearch("conversion pipeline", 0)
+	if err != nil {
+		t.Fatalf("RankedSearch() error = %v", err)
+	}
+	if len(result.Threads) != 2 {
+		t.Fatalf("RankedSearch() returned %d threads, want 2", len(result.Threads))
+	}
+	first := result.Threads[0]
+	if first.ID != "t1" || first.Subject != "Conversion factors" || first.MessageCount != 1 {
+		t.Errorf("first result = %+v, want thread t1", first)
+	}
+	if first.Score <= result.Threads[1].Score {
+		t.Errorf("scores = %v, %v, want descending", first.Score, result.Threads[1].Score)
+	}
+	if !strings.Contains(first.Snippet, "**conversion**") || !strings.Contains(first.Snippet, "**pipeline.**") {
+		t.Errorf("Snippet = %q, want highlighted terms", first.Snippet)
+	}
+
+	// A sync that adds mail updates the index
+	fake.mu.Lock()
+	fake.messages["m3"] = testMessage("m3", "t3", "Dave <dave@example.com>", "Tacos", "Tacos tacos tacos", 3000)
+	fake.history = nil
+	fake.historyID = 200
+	fake.expired = true
+	fake.mu.Unlock()
+	if _, err := store.Sync(ctx, client, SyncOptions{}); err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+
+	idx, err := index.Load(filepath.Join(store.Dir(), indexFile))
+	if err != nil || idx == nil {
+		t.Fatalf("index.Load() = %v, %v", idx, err)
+	}
+	if idx.Tag != store.indexTag() {
+		t.Errorf("index tag = %q, want %q", idx.Tag, store.indexTag())
+	}
+	result, err = store.RankedSearch("tacos", 1)
+	if err != nil {
+		t.Fatalf("RankedSearch() error = %v", err)
+	}
+	if len(result.Threads) != 1 || result.Threads[0].ID != "t3" {
+		t.Errorf("RankedSearch(tacos) = %+v, want t3", result.Threads)
+	}
+
+	// A corrupt index is rebuilt rather than failing every search
+	if err := os.WriteFile(filepath.Join(store.Dir(), indexFile), []byte("not an index"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	result, err = store.RankedSearch("tacos", 1)
+	if err != nil {
+		t.Fatalf("RankedSearch() with corrupt index error = %v", err)
+	}
+	if len(result.Threads) != 1 || result.Threads[0].ID != "t3" {
+		t.Errorf("RankedSearch(tacos) with corrupt index = %+v, want t3", result.Threads)
+	}
+	if idx, err := index.Load(filepath.Join(store.Dir(), indexFile)); err != nil || idx == nil {
+		t.Errorf("index.Load() after rebuild = %v, %v", idx, err)
+	}
+}
@@ -0,0 +1,275 @@
+// Package mirror keeps a local copy of a mailbox, kept up to date with the
+// Gmail History API, that search and download can read without the network.
+package mirror
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bentsolheim/gmail-cli/internal/gmail"
+	gmailapi "google.golang.org/api/gmail/v1"
+)
+
+const (
+	stateFile   = "state.json"
+	labelsFile  = "labels.json"
+	messagesDir = "messages"
+	indexFile   = "index.gob"
+
+	// stateVersion is bumped when the on-disk layout changes incompatibly.
+	stateVersion = 1
+)
+
+// ErrNotSynced is returned when reading a mirror that has never been synced.
+var ErrNotSynced = errors.New("mailbox has not been synced, run 'gmail-cli sync'")
+
+// ErrNotFound is returned when a thread is not in the mirror.
+var ErrNotFound = errors.New("not found in local mirror")
+
+// state is the mirror's index, saved in state.json.
+type state struct {
+	Version int `json:"version"`
+	// HistoryID is the mailbox history ID the mirror is up to date with.
+	HistoryID uint64    `json:"history_id"`
+	LastSync  time.Time `json:"last_sync"`
+	// Messages maps message IDs to their thread IDs.
+	Messages map[string]string `json:"messages"`
+	// Revision is incremented whenever a message is stored or deleted.
+	Revision uint64 `json:"revision"`
+}
+
+// Store is a local mirror of a mailbox. Each message is stored in full
+// format as messages/<id>.json, with an index in state.json.
+type Store struct {
+	// Mailbox is set on threads returned by GetThread: the delegated or
+	// shared mailbox mirrored, empty for the user's own.
+	Mailbox string
+
+	dir   string
+	state state
+	// labels maps label IDs to names
+	labels map[string]string
+}
+
+// Open opens the mirror in dir, creating it if needed.
+func Open(dir string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Join(dir, messagesDir), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create mirror: %w", err)
+	}
+
+	s := &Store{
+		dir:    dir,
+		state:  state{Version: stateVersion, Messages: map[string]string{}},
+		labels: map[string]string{},
+	}
+
+	if err := readJSON(filepath.Join(dir, stateFile), &s.state); err != nil {
+		return nil, err
+	}
+	if s.state.Version != stateVersion {
+		return nil, fmt.Errorf("unsupported mirror version %d in %s; delete it and sync again", s.state.Version, dir)
+	}
+	if s.state.Messages == nil {
+		s.state.Messages = map[string]string{}
+	}
+
+	if err := readJSON(filepath.Join(dir, labelsFile), &s.labels); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Dir returns the mirror's directory.
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// LastSync returns when the mirror was last synced, or the zero time.
+func (s *Store) LastSync() time.Time {
+	return s.state.LastSync
+}
+
+// MessageCount returns the number of messages in the mirror.
+func (s *Store) MessageCount() int {
+	return len(s.state.Messages)
+}
+
+// Thread returns a thread from the mirror.
+func (s *Store) Thread(threadID string) (*gmail.Thread, error) {
+	if s.state.HistoryID == 0 {
+		return nil, ErrNotSynced
+	}
+
+	messages, err := s.threadMessages(threadID)
+	if err != nil {
+		return nil, err
+	}
+
+	thread := gmail.ParseThread(threadID, messages)
+	s.resolveLabels(thread)
+	return thread, nil
+}
+
+// threadMessages returns a thread's messages, oldest first.
+func (s *Store) threadMessages(threadID string) ([]*gmailapi.Message, error) {
+	var messages []*gmailapi.Message
+	for id, tid := range s.state.Messages {
+		if tid != threadID {
+			continue
+		}
+		msg, err := s.readMessage(id)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+	if len(messages) == 0 {
+		return nil, fmt.Errorf("thread %s: %w", threadID, ErrNotFound)
+	}
+	sortMessages(messages)
+	return messages, nil
+}
+
+// threads returns every thread's messages, oldest message first.
+func (s *Store) threads() (map[string][]*gmailapi.Message, error) {
+	threads := make(map[string][]*gmailapi.Message)
+	for id, threadID := range s.state.Messages {
+		msg, err := s.readMessage(id)
+		if err != nil {
+			return nil, err
+		}
+		threads[threadID] = append(threads[threadID], msg)
+	}
+	for _, messages := range threads {
+		sortMessages(messages)
+	}
+	return threads, nil
+}
+
+// resolveLabels replaces the label IDs of a thread and its messages with
+// label names.
+func (s *Store) resolveLabels(thread *gmail.Thread) {
+	thread.Labels = s.labelNames(thread.Labels)
+	for i := range thread.Messages {
+		thread.Messages[i].Labels = s.labelNames(thread.Messages[i].Labels)
+	}
+}
+
+// labelNames maps label IDs to names, keeping IDs without a known name.
+func (s *Store) labelNames(ids []string) []string {
+	names := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if name, ok := s.labels[id]; ok {
+			names = append(names, name)
+		} else {
+			names = append(names, id)
+		}
+	}
+	slices.Sort(names)
+	return slices.Compact(names)
+}
+
+// messagePath returns the path of a stored message.
+func (s *Store) messagePath(id string) string {
+	return filepath.Join(s.dir, messagesDir, id+".json")
+}
+
+func (s *Store) readMessage(id string) (*gmailapi.Message, error) {
+	msg := &gmailapi.Message{}
+	b, err := os.ReadFile(s.messagePath(id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read message %s from mirror: %w", id, err)
+	}
+	if err := json.Unmarshal(b, msg); err != nil {
+		return nil, fmt.Errorf("failed to parse message %s from mirror: %w", id, err)
+	}
+	return msg, nil
+}
+
+func (s *Store) writeMessage(msg *gmailapi.Message) error {
+	if err := writeJSON(s.messagePath(msg.Id), msg); err != nil {
+		return fmt.Errorf("failed to save message %s: %w", msg.Id, err)
+	}
+	s.state.Messages[msg.Id] = msg.ThreadId
+	s.state.Revision++
+	return nil
+}
+
+func (s *Store) deleteMessage(id string) error {
+	delete(s.state.Messages, id)
+	s.state.Revision++
+	err := os.Remove(s.messagePath(id))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete message %s: %w", id, err)
+	}
+	return nil
+}
+
+// saveState writes the index and labels.
+func (s *Store) saveState() error {
+	if err := writeJSON(filepath.Join(s.dir, labelsFile), s.labels); err != nil {
+		return fmt.Errorf("failed to save labels: %w", err)
+	}
+	if err := writeJSON(filepath.Join(s.dir, stateFile), s.state); err != nil {
+		return fmt.Errorf("failed to save mirror state: %w", err)
+	}
+	return nil
+}
+
+// sortMessages sorts messages by the time Gmail received them.
+func sortMessages(messages []*gmailapi.Message) {
+	slices.SortStableFunc(messages, func(a, b *gmailapi.Message) int {
+		if c := cmp.Compare(a.InternalDate, b.InternalDate); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Id, b.Id)
+	})
+}
+
+// readJSON decodes a JSON file into v, leaving v unchanged if the file
+// doesn't exist.
+func readJSON(path string, v any) error {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return nil
+}
+
+// writeJSON writes v to path atomically, so an interrupted write never
+// leaves a truncated file.
+func writeJSON(path string, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,395 @@
+package mirror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/bentsolheim/gmail-cli/internal/gmail"
+	"golang.org/x/sync/errgroup"
+	gmailapi "google.golang.org/api/gmail/v1"
+	"google.golang.org/api/googleapi"
+)
+
+// maxPageSize is the largest page size accepted by Messages.List and
+// History.List.
+const maxPageSize = 500
+
+// historyTypes are the history records an incremental sync applies.
+var historyTypes = []string{"messageAdded", "messageDeleted", "labelAdded", "labelRemoved"}
+
+// SyncOptions controls Sync.
+type SyncOptions struct {
+	// Concurrency is the number of messages fetched in parallel.
+	// Zero means gmail.DefaultConcurrency.
+	Concurrency int
+	// Full forces a full sync even if history is available.
+	Full bool
+}
+
+// SyncResult reports what a sync changed.
+type SyncResult struct {
+	// Full is set when the whole mailbox was listed rather than read from
+	// history.
+	Full bool
+	// Expired is set when a full sync was needed because the stored history
+	// ID was too old.
+	Expired bool
+	Added   int
+	Deleted int
+	// Updated counts stored messages whose labels changed.
+	Updated int
+}
+
+// Sync brings the mirror up to date. The first sync lists the whole mailbox;
+// later syncs apply the changes since the last one from the History API, and
+// fall back to a full sync when that history has expired. An interrupted full
+// sync resumes without fetching stored messages again.
+func (s *Store) Sync(ctx context.Context, client *gmail.Client, opts SyncOptions) (*SyncResult, error) {
+	if opts.Concurrency < 1 {
+		opts.Concurrency = gmail.DefaultConcurrency
+	}
+
+	if err := s.syncLabels(ctx, client); err != nil {
+		return nil, err
+	}
+
+	var result *SyncResult
+	var err error
+	if s.state.HistoryID != 0 && !opts.Full {
+		result, err = s.syncHistory(ctx, client, opts)
+		if isNotFound(err) {
+			// History IDs are kept for about a week; older ones return 404
+			result, err = s.syncFull(ctx, client, opts)
+			if result != nil {
+				result.Expired = true
+			}
+		}
+	} else {
+		result, err = s.syncFull(ctx, client, opts)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Update the search index now rather than on the next search
+	if _, err := s.index(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// syncLabels refreshes the label names.
+func (s *Store) syncLabels(ctx context.Context, client *gmail.Client) error {
+	resp, err := client.Service().Users.Labels.List(client.UserID()).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("failed to list labels: %w", err)
+	}
+
+	s.labels = make(map[string]string, len(resp.Labels))
+	for _, label := range resp.Labels {
+		s.labels[label.Id] = label.Name
+	}
+	return nil
+}
+
+// syncFull lists every message in the mailbox, fetches the ones not stored,
+// refreshes the labels of the ones that are, and deletes stored messages that
+// are gone.
+func (s *Store) syncFull(ctx context.Context, client *gmail.Client, opts SyncOptions) (*SyncResult, error) {
+	result := &SyncResult{Full: true}
+
+	// Take the history ID before listing, so changes made during the sync
+	// are picked up by the next one.
+	profile, err := client.Service().Users.GetProfile(client.UserID()).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get profile: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	pageToken := ""
+	for {
+		call := client.Service().Users.Messages.List(client.UserID()).
+			MaxResults(maxPageSize).
+			Context(ctx)
+		if pageToken != "" {
+			call = call.PageToken(pageToken)
+		}
+
+		resp, err := call.Do()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list messages: %w", err)
+		}
+
+		var missing, stored []string
+		for _, msg := range resp.Messages {
+			seen[msg.Id] = true
+			if _, ok := s.state.Messages[msg.Id]; ok {
+				stored = append(stored, msg.Id)
+			} else {
+				missing = append(missing, msg.Id)
+			}
+		}
+
+		added, err := s.fetchMessages(ctx, client, missing, opts.Concurrency)
+		result.Added += added
+		if err != nil {
+			return nil, err
+		}
+		updated, err := s.refreshLabels(ctx, client, stored, opts.Concurrency)
+		result.Updated += updated
+		if err != nil {
+			return nil, err
+		}
+
+		// Save progress so an interrupted sync resumes from here
+		if err := s.saveState(); err != nil {
+			return nil, err
+		}
+
+		pageToken = resp.NextPageToken
+		if pageToken == "" {
+			break
+		}
+	}
+
+	for id := range s.state.Messages {
+		if seen[id] {
+			continue
+		}
+		if err := s.deleteMessage(id); err != nil {
+			return nil, err
+		}
+		result.Deleted++
+	}
+
+	s.state.HistoryID = profile.HistoryId
+	s.state.LastSync = time.Now()
+	if err := s.saveState(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// syncHistory applies the changes since the stored history ID. It returns a
+// 404 error if that history has expired.
+func (s *Store) syncHistory(ctx context.Context, client *gmail.Client, opts SyncOptions) (*SyncResult, error) {
+	result := &SyncResult{}
+
+	// Changes are collected first so a message added and then deleted
+	// within the same window is never fetched.
+	var added []string
+	deleted := make(map[string]bool)
+	changed := make(map[string]*gmailapi.Message)
+
+	historyID := s.state.HistoryID
+	pageToken := ""
+	for {
+		call := client.Service().Users.History.List(client.UserID()).
+			StartHistoryId(s.state.HistoryID).
+			HistoryTypes(historyTypes...).
+			MaxResults(maxPageSize).
+			Context(ctx)
+		if pageToken != "" {
+			call = call.PageToken(pageToken)
+		}
+
+		resp, err := call.Do()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, h := range resp.History {
+			for _, a := range h.MessagesAdded {
+				added = append(added, a.Message.Id)
+				delete(deleted, a.Message.Id)
+			}
+			for _, d := range h.MessagesDeleted {
+				deleted[d.Message.Id] = true
+			}
+			for _, l := range h.LabelsAdded {
+				if err := s.applyLabels(changed, l.Message.Id, l.LabelIds, nil); err != nil {
+					return nil, err
+				}
+			}
+			for _, l := range h.LabelsRemoved {
+				if err := s.applyLabels(changed, l.Message.Id, nil, l.LabelIds); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		historyID = max(historyID, resp.HistoryId)
+		pageToken = resp.NextPageToken
+		if pageToken == "" {
+			break
+		}
+	}
+
+	for id := range deleted {
+		if _, ok := s.state.Messages[id]; !ok {
+			continue
+		}
+		if err := s.deleteMessage(id); err != nil {
+			return nil, err
+		}
+		delete(changed, id)
+		result.Deleted++
+	}
+
+	for _, msg := range changed {
+		if err := s.writeMessage(msg); err != nil {
+			return nil, err
+		}
+		result.Updated++
+	}
+
+	slices.Sort(added)
+	added = slices.DeleteFunc(slices.Compact(added), func(id string) bool {
+		_, stored := s.state.Messages[id]
+		return stored || deleted[id]
+	})
+	n, err := s.fetchMessages(ctx, client, added, opts.Concurrency)
+	result.Added += n
+	if err != nil {
+		return nil, err
+	}
+
+	s.state.HistoryID = historyID
+	s.state.LastSync = time.Now()
+	if err := s.saveState(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// applyLabels adds and removes labels on a stored message, collecting the
+// modified message in changed. Messages not stored yet are skipped; they get
+// their current labels when fetched.
+func (s *Store) applyLabels(changed map[string]*gmailapi.Message, id string, add, remove []string) error {
+	msg, ok := changed[id]
+	if !ok {
+		if _, stored := s.state.Messages[id]; !stored {
+			return nil
+		}
+		var err error
+		msg, err = s.readMessage(id)
+		if err != nil {
+			return err
+		}
+		changed[id] = msg
+	}
+
+	msg.LabelIds = slices.DeleteFunc(msg.LabelIds, func(label string) bool {
+		return slices.Contains(remove, label) || slices.Contains(add, label)
+	})
+	msg.LabelIds = append(msg.LabelIds, add...)
+	return nil
+}
+
+// fetchMessages fetches messages in full format and stores them, using up
+// to concurrency parallel requests. Messages deleted since they were listed
+// are skipped. It returns the number stored.
+func (s *Store) fetchMessages(ctx context.Context, client *gmail.Client, ids []string, concurrency int) (int, error) {
+	count := 0
+	for batch := range slices.Chunk(ids, concurrency) {
+		messages := make([]*gmailapi.Message, len(batch))
+
+		g, gctx := errgroup.WithContext(ctx)
+		for i, id := range batch {
+			g.Go(func() error {
+				msg, err := client.Service().Users.Messages.Get(client.UserID(), id).
+					Format("full").
+					Context(gctx).
+					Do()
+				if isNotFound(err) {
+					return nil
+				}
+				if err != nil {
+					return fmt.Errorf("failed to get message %s: %w", id, err)
+				}
+				messages[i] = msg
+				return nil
+			})
+		}
+		if err := g.Wait(); err != nil {
+			return count, err
+		}
+
+		for _, msg := range messages {
+			if msg == nil {
+				continue
+			}
+			if err := s.writeMessage(msg); err != nil {
+				return count, err
+			}
+			count++
+		}
+	}
+	return count, nil
+}
+
+// refreshLabels updates the labels of stored messages, fetching only their
+// minimal format. It returns the number whose labels changed.
+func (s *Store) refreshLabels(ctx context.Context, client *gmail.Client, ids []string, concurrency int) (int, error) {
+	count := 0
+	for batch := range slices.Chunk(ids, concurrency) {
+		labels := make([][]string, len(batch))
+		found := make([]bool, len(batch))
+
+		g, gctx := errgroup.WithContext(ctx)
+		for i, id := range batch {
+			g.Go(func() error {
+				msg, err := client.Service().Users.Messages.Get(client.UserID(), id).
+					Format("minimal").
+					Context(gctx).
+					Do()
+				if isNotFound(err) {
+					return nil
+				}
+				if err != nil {
+					return fmt.Errorf("failed to get message %s: %w", id, err)
+				}
+				labels[i], found[i] = msg.LabelIds, true
+				return nil
+			})
+		}
+		if err := g.Wait(); err != nil {
+			return count, err
+		}
+
+		for i, id := range batch {
+			if !found[i] {
+				continue
+			}
+			msg, err := s.readMessage(id)
+			if err != nil {
+				return count, err
+			}
+			if slices.Equal(sortedCopy(msg.LabelIds), sortedCopy(labels[i])) {
+				continue
+			}
+			msg.LabelIds = labels[i]
+			if err := s.writeMessage(msg); err != nil {
+				return count, err
+			}
+			count++
+		}
+	}
+	return count, nil
+}
+
+func sortedCopy(s []string) []string {
+	s = slices.Clone(s)
+	slices.Sort(s)
+	return s
+}
+
+// isNotFound reports whether err is a 404 from the Gmail API.
+func isNotFound(err error) bool {
+	var apiErr *googleapi.Error
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound
+}
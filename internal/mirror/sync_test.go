@@ -0,0 +1,243 @@
+package mirror
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/bentsolheim/gmail-cli/internal/gmail"
+	gmailapi "google.golang.org/api/gmail/v1"
+	"google.golang.org/api/option"
+)
+
+// fakeMailbox serves the parts of the Gmail API a sync uses.
+type fakeMailbox struct {
+	mu        sync.Mutex
+	messages  map[string]*gmailapi.Message
+	historyID uint64
+	history   []*gmailapi.History
+	// expired makes History.List return 404, as for an old history ID
+	expired bool
+	// gets counts Messages.Get requests by format
+	gets map[string]int
+}
+
+func newFakeMailbox(messages ...*gmailapi.Message) *fakeMailbox {
+	f := &fakeMailbox{
+		messages:  make(map[string]*gmailapi.Message),
+		historyID: 100,
+		gets:      make(map[string]int),
+	}
+	for _, msg := range messages {
+		f.messages[msg.Id] = msg
+	}
+	return f
+}
+
+func (f *fakeMailbox) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	const prefix = "/gmail/v1/users/me/"
+	path := strings.TrimPrefix(r.URL.Path, prefix)
+	switch {
+	case path == "profile":
+		writeResponse(w, &gmailapi.Profile{HistoryId: f.historyID})
+	case path == "labels":
+		writeResponse(w, &gmailapi.ListLabelsResponse{Labels: []*gmailapi.Label{
+			{Id: "INBOX", Name: "INBOX"},
+			{Id: "Label_1", Name: "Projects/Conversion"},
+		}})
+	case path == "messages":
+		var list []*gmailapi.Message
+		for id := range f.messages {
+			list = append(list, &gmailapi.Message{Id: id})
+		}
+		writeResponse(w, &gmailapi.ListMessagesResponse{Messages: list})
+	case strings.HasPrefix(path, "messages/"):
+		msg, ok := f.messages[strings.TrimPrefix(path, "messages/")]
+		if !ok {
+			http.Error(w, `{"error":{"code":404,"message":"Not Found"}}`, http.StatusNotFound)
+			return
+		}
+		format := r.URL.Query().Get("format")
+		f.gets[format]++
+		if format == "minimal" {
+			writeResponse(w, &gmailapi.Message{Id: msg.Id, ThreadId: msg.ThreadId, LabelIds: msg.LabelIds})
+			return
+		}
+		writeResponse(w, msg)
+	case path == "history":
+		if f.expired {
+			http.Error(w, `{"error":{"code":404,"message":"Requested entity was not found."}}`, http.StatusNotFound)
+			return
+		}
+		writeResponse(w, &gmailapi.ListHistoryResponse{History: f.history, HistoryId: f.historyID})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func newTestClient(t *testing.T, handler http.Handler) *gmail.Client {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	service, err := gmailapi.NewService(context.Background(),
+		option.WithEndpoint(server.URL+"/"),
+		option.WithHTTPClient(server.Client()),
+	)
+	if err != nil {
+		t.Fatalf("failed to create service: %v", err)
+	}
+	return gmail.NewClientWithService(service, "")
+}
+
+func writeResponse(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// testMessage returns a full-format message with a plain text body.
+func testMessage(id, threadID, from, subject, body string, internalDate int64, labels ...string) *gmailapi.Message {
+	return &gmailapi.Message{
+		Id:           id,
+		ThreadId:     threadID,
+		LabelIds:     labels,
+		InternalDate: internalDate,
+		Payload: &gmailapi.MessagePart{
+			MimeType: "text/plain",
+			Headers: []*gmailapi.MessagePartHeader{
+				{Name: "From", Value: from},
+				{Name: "To", Value: "Bob <bob@example.com>"},
+				{Name: "Subject", Value: subject},
+			},
+			Body: &gmailapi.MessagePartBody{Data: base64.URLEncoding.EncodeToString([]byte(body))},
+		},
+	}
+}
+
+func storedIDs(s *Store) []string {
+	var ids []string
+	for id := range s.state.Messages {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+func TestSync(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeMailbox(
+		testMessage("m1", "t1", "Alice <alice@example.com>", "Hello", "First", 1000, "INBOX", "UNREAD"),
+		testMessage("m2", "t1", "Bob <bob@example.com>", "Re: Hello", "Second", 2000, "INBOX"),
+		testMessage("m3", "t2", "Carol <carol@example.com>", "Other", "Third", 3000),
+	)
+	client := newTestClient(t, fake)
+	dir := t.TempDir()
+
+	store, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+
+	// First sync lists everything
+	result, err := store.Sync(ctx, client, SyncOptions{})
+	if err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	if !result.Full || result.Added != 3 {
+		t.Errorf("first Sync() = %+v, want full sync adding 3", result)
+	}
+
+	// Changes since: m4 added, m2 deleted, m1 read and labelled
+	fake.mu.Lock()
+	fake.messages["m4"] = testMessage("m4", "t2", "Dave <dave@example.com>", "Re: Other", "Fourth", 4000, "INBOX")
+	delete(fake.messages, "m2")
+	fake.messages["m1"].LabelIds = []string{"INBOX", "Label_1"}
+	fake.history = []*gmailapi.History{
+		{MessagesAdded: []*gmailapi.HistoryMessageAdded{{Message: &gmailapi.Message{Id: "m4"}}}},
+		{MessagesAdded: []*gmailapi.HistoryMessageAdded{{Message: &gmailapi.Message{Id: "m5"}}}},
+		{MessagesDeleted: []*gmailapi.HistoryMessageDeleted{{Message: &gmailapi.Message{Id: "m2"}}, {Message: &gmailapi.Message{Id: "m5"}}}},
+		{LabelsRemoved: []*gmailapi.HistoryLabelRemoved{{Message: &gmailapi.Message{Id: "m1"}, LabelIds: []string{"UNREAD"}}}},
+		{LabelsAdded: []*gmailapi.HistoryLabelAdded{{Message: &gmailapi.Message{Id: "m1"}, LabelIds: []string{"Label_1"}}}},
+	}
+	fake.historyID = 200
+	fake.gets = map[string]int{}
+	fake.mu.Unlock()
+
+	// Reopen to check the state was saved
+	store, err = Open(dir)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	result, err = store.Sync(ctx, client, SyncOptions{})
+	if err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	want := SyncResult{Added: 1, Deleted: 1, Updated: 1}
+	if *result != want {
+		t.Errorf("incremental Sync() = %+v, want %+v", result, want)
+	}
+	if fake.gets["full"] != 1 {
+		t.Errorf("incremental Sync() fetched %d messages, want 1", fake.gets["full"])
+	}
+	if got := storedIDs(store); !slices.Equal(got, []string{"m1", "m3", "m4"}) {
+		t.Errorf("stored messages = %v, want [m1 m3 m4]", got)
+	}
+	if store.state.HistoryID != 200 {
+		t.Errorf("history ID = %d, want 200", store.state.HistoryID)
+	}
+
+	thread, err := store.Thread("t1")
+	if err != nil {
+		t.Fatalf("Thread() error = %v", err)
+	}
+	if want := []string{"INBOX", "Projects/Conversion"}; !slices.Equal(thread.Labels, want) {
+		t.Errorf("Thread().Labels = %v, want %v", thread.Labels, want)
+	}
+
+	// Expired history falls back to a full sync that also refreshes labels
+	fake.mu.Lock()
+	fake.expired = true
+	fake.messages["m3"].LabelIds = []string{"INBOX"}
+	fake.messages["m6"] = testMessage("m6", "t3", "Erin <erin@example.com>", "New", "Sixth", 6000)
+	delete(fake.messages, "m4")
+	fake.historyID = 300
+	fake.mu.Unlock()
+
+	result, err = store.Sync(ctx, client, SyncOptions{})
+	if err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	want = SyncResult{Full: true, Expired: true, Added: 1, Deleted: 1, Updated: 1}
+	if *result != want {
+		t.Errorf("expired Sync() = %+v, want %+v", result, want)
+	}
+	if got := storedIDs(store); !slices.Equal(got, []string{"m1", "m3", "m6"}) {
+		t.Errorf("stored messages = %v, want [m1 m3 m6]", got)
+	}
+	if store.state.HistoryID != 300 {
+		t.Errorf("history ID = %d, want 300", store.state.HistoryID)
+	}
+}
+
+func TestStoreNotSynced(t *testing.T) {
+	store, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if _, err := store.Search("hello", 0); err != ErrNotSynced {
+		t.Errorf("Search() error = %v, want ErrNotSynced", err)
+	}
+	if _, err := store.Thread("t1"); err != ErrNotSynced {
+		t.Errorf("Thread() error = %v, want ErrNotSynced", err)
+	}
+}
@@ -1,6 +1,8 @@
 package output
 
 import (
+	"time"
+
 	"github.com/bentsolheim/gmail-cli/internal/gmail"
 )
 
@@ -14,14 +16,26 @@ type FormatOptions struct {
 
 // Formatter defines the interface for output formatting.
 type Formatter interface {
-	FormatSearchResults(results []gmail.ThreadSummary) string
+	FormatSearchResults(result *gmail.SearchResult) string
 	FormatThread(thread *gmail.Thread, savedAttachments map[string]string, opts FormatOptions) string
 }
 
 // TextFormatter implements Formatter for plain text output.
-type TextFormatter struct{}
+type TextFormatter struct {
+	// Location is the time zone dates are displayed in. Nil keeps each
+	// message's own zone.
+	Location *time.Location
+}
 
 // NewTextFormatter creates a new text formatter.
 func NewTextFormatter() *TextFormatter {
 	return &TextFormatter{}
 }
+
+// inLocation converts t to the formatter's time zone.
+func (f *TextFormatter) inLocation(t time.Time) time.Time {
+	if f.Location == nil || t.IsZero() {
+		return t
+	}
+	return t.In(f.Location)
+}
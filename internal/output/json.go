@@ -0,0 +1,219 @@
+package output
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bentsolheim/gmail-cli/internal/gmail"
+)
+
+// JSONSchemaVersion is the version of the JSON output schema. It is bumped
+// when a field is removed or changes meaning; adding fields keeps the version.
+const JSONSchemaVersion = 1
+
+// JSON document types
+const (
+	jsonTypeSearchResults = "search_results"
+	jsonTypeThread        = "thread"
+)
+
+// jsonSearchResults is the JSON document for search results.
+type jsonSearchResults struct {
+	SchemaVersion int                 `json:"schema_version"`
+	Type          string              `json:"type"`
+	Threads       []jsonThreadSummary `json:"threads"`
+	NextPageToken string              `json:"next_page_token,omitempty"`
+}
+
+type jsonThreadSummary struct {
+	ID              string   `json:"id"`
+	Subject         string   `json:"subject"`
+	Participants    []string `json:"participants"`
+	LastMessageDate string   `json:"last_message_date"`
+	MessageCount    int      `json:"message_count"`
+	AttachmentCount int      `json:"attachment_count"`
+	Score           float64  `json:"score,omitempty"`
+	Snippet         string   `json:"snippet,omitempty"`
+}
+
+// jsonThreadDocument is the JSON document for a thread.
+type jsonThreadDocument struct {
+	SchemaVersion int        `json:"schema_version"`
+	Type          string     `json:"type"`
+	Thread        jsonThread `json:"thread"`
+}
+
+type jsonThread struct {
+	ID           string        `json:"id"`
+	Mailbox      string        `json:"mailbox,omitempty"`
+	Subject      string        `json:"subject"`
+	Participants []string      `json:"participants"`
+	DateRange    jsonDateRange `json:"date_range"`
+	Labels       []string      `json:"labels,omitempty"`
+	Messages     []jsonMessage `json:"messages"`
+}
+
+type jsonDateRange struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+type jsonMessage struct {
+	// Index is the chronological position in the thread, starting at 1,
+	// so it stays stable when messages are reversed.
+	Index       int              `json:"index"`
+	ID          string           `json:"id"`
+	From        string           `json:"from"`
+	To          string           `json:"to,omitempty"`
+	Cc          string           `json:"cc,omitempty"`
+	Bcc         string           `json:"bcc,omitempty"`
+	Subject     string           `json:"subject,omitempty"`
+	Date        string           `json:"date"`
+	Labels      []string         `json:"labels,omitempty"`
+	Size        int64            `json:"size,omitempty"`
+	Body        string           `json:"body"`
+	Attachments []jsonAttachment `json:"attachments"`
+}
+
+type jsonAttachment struct {
+	ID        string `json:"id"`
+	MessageID string `json:"message_id"`
+	Filename  string `json:"filename"`
+	MimeType  string `json:"mime_type"`
+	Size      int64  `json:"size"`
+	// SavedPath is where the attachment was saved, empty if it wasn't downloaded.
+	SavedPath string `json:"saved_path,omitempty"`
+}
+
+// JSONFormatter implements Formatter for machine-readable JSON output.
+// Dates are RFC 3339 strings, empty when unknown.
+type JSONFormatter struct {
+	// Location is the time zone dates are written in. Nil keeps each
+	// message's own zone.
+	Location *time.Location
+}
+
+// NewJSONFormatter creates a new JSON formatter.
+func NewJSONFormatter() *JSONFormatter {
+	return &JSONFormatter{}
+}
+
+// FormatSearchResults formats search results as a JSON document.
+func (f *JSONFormatter) FormatSearchResults(result *gmail.SearchResult) string {
+	doc := jsonSearchResults{
+		SchemaVersion: JSONSchemaVersion,
+		Type:          jsonTypeSearchResults,
+		Threads:       []jsonThreadSummary{},
+		NextPageToken: result.NextPageToken,
+	}
+
+	for _, r := range result.Threads {
+		doc.Threads = append(doc.Threads, jsonThreadSummary{
+			ID:              r.ID,
+			Subject:         r.Subject,
+			Participants:    nonNil(r.Participants),
+			LastMessageDate: f.formatTime(r.LastMessageDate),
+			MessageCount:    r.MessageCount,
+			AttachmentCount: r.AttachmentCount,
+			Score:           r.Score,
+			Snippet:         r.Snippet,
+		})
+	}
+
+	return marshalJSON(doc)
+}
+
+// FormatThread formats a thread as a JSON document. Messages are listed in
+// chronological order unless opts.Reverse is set.
+func (f *JSONFormatter) FormatThread(thread *gmail.Thread, savedAttachments map[string]string, opts FormatOptions) string {
+	doc := jsonThreadDocument{
+		SchemaVersion: JSONSchemaVersion,
+		Type:          jsonTypeThread,
+		Thread: jsonThread{
+			ID:           thread.ID,
+			Mailbox:      thread.Mailbox,
+			Subject:      thread.Subject,
+			Participants: nonNil(thread.Participants),
+			DateRange: jsonDateRange{
+				Start: f.formatTime(thread.DateRange.Start),
+				End:   f.formatTime(thread.DateRange.End),
+			},
+			Labels:   thread.Labels,
+			Messages: []jsonMessage{},
+		},
+	}
+
+	for i, msg := range thread.Messages {
+		body := strings.TrimSpace(msg.Body)
+		if opts.MessagesOnly {
+			body = StripQuotedContent(body)
+		}
+
+		m := jsonMessage{
+			Index:       i + 1,
+			ID:          msg.ID,
+			From:        msg.From,
+			To:          msg.To,
+			Cc:          msg.Cc,
+			Bcc:         msg.Bcc,
+			Subject:     msg.Subject,
+			Date:        f.formatTime(msg.Date),
+			Labels:      msg.Labels,
+			Size:        msg.Size,
+			Body:        body,
+			Attachments: []jsonAttachment{},
+		}
+		for _, att := range msg.Attachments {
+			m.Attachments = append(m.Attachments, jsonAttachment{
+				ID:        att.ID,
+				MessageID: att.MessageID,
+				Filename:  att.Filename,
+				MimeType:  att.MimeType,
+				Size:      att.Size,
+				SavedPath: savedAttachments[att.ID],
+			})
+		}
+		doc.Thread.Messages = append(doc.Thread.Messages, m)
+	}
+
+	if opts.Reverse {
+		slices.Reverse(doc.Thread.Messages)
+	}
+
+	return marshalJSON(doc)
+}
+
+// formatTime formats t as RFC 3339 in the formatter's time zone.
+func (f *JSONFormatter) formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	if f.Location != nil {
+		t = t.In(f.Location)
+	}
+	return t.Format(time.RFC3339)
+}
+
+// marshalJSON encodes v as indented JSON with a trailing newline.
+// HTML characters are not escaped, so bodies stay readable.
+func marshalJSON(v any) string {
+	var sb strings.Builder
+	enc := json.NewEncoder(&sb)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		// The documents contain only strings and numbers, so this can't happen
+		panic(err)
+	}
+	return sb.String()
+}
+
+// nonNil returns s, or an empty slice if s is nil, so it encodes as [].
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
@@ -0,0 +1,149 @@
+package output
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bentsolheim/gmail-cli/internal/gmail"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+// checkGolden compares got with testdata/<name>, rewriting the file with -update.
+func checkGolden(t *testing.T, name, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+
+	if *update {
+		if err := os.WriteFile(path, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read golden file (run with -update to create it): %v", err)
+	}
+	if got != string(want) {
+		t.Errorf("output does not match %s:\n%s", path, got)
+	}
+}
+
+// goldenThread returns the thread used by the golden file tests.
+func goldenThread() *gmail.Thread {
+	oslo := time.FixedZone("CET", 3600)
+	return &gmail.Thread{
+		ID:           "18c1234abcd5678",
+		Subject:      "Re: Conversion factors",
+		Participants: []string{"felipe@example.com", "you@example.com"},
+		DateRange: gmail.DateRange{
+			Start: time.Date(2025, 12, 9, 10, 30, 0, 0, oslo),
+			End:   time.Date(2025, 12, 11, 14, 5, 0, 0, oslo),
+		},
+		Messages: []gmail.Message{
+			{
+				ID:      "msg1",
+				From:    "Felipe Garcia <felipe@example.com>",
+				To:      "you@example.com",
+				Cc:      "Carol <carol@example.com>",
+				Subject: "Conversion factors",
+				Date:    time.Date(2025, 12, 9, 10, 30, 0, 0, oslo),
+				Labels:  []string{"INBOX", "Projects/Conversion"},
+				Size:    24576,
+				Body:    "Here are the <updated> factors.\n",
+				Attachments: []gmail.Attachment{
+					{ID: "att1", MessageID: "msg1", Filename: "conversion_factors.xlsx", MimeType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", Size: 20480},
+					{ID: "att2", MessageID: "msg1", Filename: "notes.pdf", MimeType: "application/pdf", Size: 1024},
+				},
+			},
+			{
+				ID:      "msg2",
+				From:    "you@example.com",
+				To:      "Felipe Garcia <felipe@example.com>",
+				Bcc:     "archive@example.com",
+				Subject: "Re: Conversion factors",
+				Date:    time.Date(2025, 12, 11, 14, 5, 0, 0, oslo),
+				Labels:  []string{"SENT"},
+				Size:    2048,
+				Body:    "Thanks!\n\nOn Tue, Dec 9, 2025 at 10:30 AM Felipe Garcia <felipe@example.com> wrote:\n> Here are the <updated> factors.",
+			},
+		},
+	}
+}
+
+func TestJSONFormatter_Golden(t *testing.T) {
+	formatter := &JSONFormatter{Location: time.UTC}
+
+	t.Run("search results", func(t *testing.T) {
+		result := &gmail.SearchResult{
+			Threads: []gmail.ThreadSummary{
+				{
+					ID:              "18c1234abcd5678",
+					Subject:         "Re: Conversion factors",
+					Participants:    []string{"Felipe Garcia", "me"},
+					LastMessageDate: time.Date(2025, 12, 11, 14, 5, 0, 0, time.UTC),
+					MessageCount:    2,
+					AttachmentCount: 2,
+				},
+				{
+					ID:              "18c0000ffff0001",
+					Subject:         "Lunch?",
+					LastMessageDate: time.Date(2025, 12, 1, 9, 0, 0, 0, time.UTC),
+					MessageCount:    1,
+				},
+			},
+			NextPageToken: "token-2",
+		}
+		checkGolden(t, "search.json", formatter.FormatSearchResults(result))
+	})
+
+	t.Run("ranked search results", func(t *testing.T) {
+		result := &gmail.SearchResult{
+			Threads: []gmail.ThreadSummary{
+				{
+					ID:              "18c1234abcd5678",
+					Subject:         "Re: Conversion factors",
+					Participants:    []string{"Felipe Garcia"},
+					LastMessageDate: time.Date(2025, 12, 11, 14, 5, 0, 0, time.UTC),
+					MessageCount:    2,
+					Score:           4.2137,
+					Snippet:         "...the updated **conversion** factors for Q4",
+				},
+			},
+		}
+		checkGolden(t, "search_ranked.json", formatter.FormatSearchResults(result))
+	})
+
+	t.Run("no search results", func(t *testing.T) {
+		checkGolden(t, "search_empty.json", formatter.FormatSearchResults(&gmail.SearchResult{}))
+	})
+
+	t.Run("thread", func(t *testing.T) {
+		saved := map[string]string{"att1": "/tmp/out/conversion_factors.xlsx"}
+		checkGolden(t, "thread.json", formatter.FormatThread(goldenThread(), saved, FormatOptions{}))
+	})
+
+	t.Run("thread reversed, messages only", func(t *testing.T) {
+		checkGolden(t, "thread_reverse_messages_only.json",
+			formatter.FormatThread(goldenThread(), nil, FormatOptions{Reverse: true, MessagesOnly: true}))
+	})
+}
+
+func TestJSONFormatter_SchemaVersion(t *testing.T) {
+	var doc struct {
+		SchemaVersion int    `json:"schema_version"`
+		Type          string `json:"type"`
+	}
+
+	out := NewJSONFormatter().FormatThread(goldenThread(), nil, FormatOptions{})
+	if err := json.Unmarshal([]byte(out), &doc); err != nil {
+		t.Fatalf("output is not valid JSON: %v", err)
+	}
+	if doc.SchemaVersion != JSONSchemaVersion || doc.Type != "thread" {
+		t.Errorf("schema_version = %d, type = %q; want %d, thread", doc.SchemaVersion, doc.Type, JSONSchemaVersion)
+	}
+}
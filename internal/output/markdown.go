@@ -0,0 +1,246 @@
+package output
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bentsolheim/gmail-cli/internal/gmail"
+	"gopkg.in/yaml.v3"
+)
+
+// markdownFrontMatter is the YAML front matter of a Markdown thread.
+// Dates are flat fields so note apps like Obsidian show them as properties.
+type markdownFrontMatter struct {
+	Subject      string   `yaml:"subject"`
+	ThreadID     string   `yaml:"thread_id"`
+	Mailbox      string   `yaml:"mailbox,omitempty"`
+	Participants []string `yaml:"participants"`
+	DateStart    string   `yaml:"date_start,omitempty"`
+	DateEnd      string   `yaml:"date_end,omitempty"`
+	Labels       []string `yaml:"labels"`
+	URL          string   `yaml:"url"`
+}
+
+// MarkdownFormatter implements Formatter for Markdown output with YAML front
+// matter, for note apps and knowledge bases.
+type MarkdownFormatter struct {
+	// Location is the time zone dates are displayed in. Nil keeps each
+	// message's own zone.
+	Location *time.Location
+	// BaseDir is the directory attachment links are relative to, normally
+	// where the Markdown file is saved. Empty means the current directory.
+	BaseDir string
+}
+
+// NewMarkdownFormatter creates a new Markdown formatter.
+func NewMarkdownFormatter() *MarkdownFormatter {
+	return &MarkdownFormatter{}
+}
+
+// FormatSearchResults formats search results as a Markdown list linking to
+// each thread in Gmail.
+// Output format:
+// - [Re: Conversion factors](https://mail.google.com/...) - Felipe Garcia, Dec 11, 2025 (3 messages, 2 attachments)
+//
+// Ranked local results add the score and the snippet on an indented line.
+func (f *MarkdownFormatter) FormatSearchResults(result *gmail.SearchResult) string {
+	if len(result.Threads) == 0 {
+		return "No results found.\n"
+	}
+
+	var sb strings.Builder
+	for _, r := range result.Threads {
+		counts := pluralize(r.MessageCount, "message")
+		if r.AttachmentCount > 0 {
+			counts += ", " + pluralize(r.AttachmentCount, "attachment")
+		}
+		fmt.Fprintf(&sb, "- [%s](%s) - %s, %s (%s)",
+			escapeLinkText(subjectOrPlaceholder(r.Subject)),
+			ThreadURL("", r.ID),
+			strings.Join(r.Participants, ", "),
+			f.inLocation(r.LastMessageDate).Format("Jan 2, 2006"),
+			counts)
+		if r.Score > 0 {
+			fmt.Fprintf(&sb, " - score %.2f", r.Score)
+		}
+		sb.WriteString("\n")
+		if r.Snippet != "" {
+			fmt.Fprintf(&sb, "  %s\n", r.Snippet)
+		}
+	}
+
+	if result.NextPageToken != "" {
+		fmt.Fprintf(&sb, "\nNext page token: `%s`\n", result.NextPageToken)
+	}
+
+	return sb.String()
+}
+
+// FormatThread formats a thread as Markdown: YAML front matter, then a
+// heading per message with quoted content as blockquotes, then links to the
+// saved attachments.
+func (f *MarkdownFormatter) FormatThread(thread *gmail.Thread, savedAttachments map[string]string, opts FormatOptions) string {
+	var sb strings.Builder
+
+	frontMatter := markdownFrontMatter{
+		Subject:      thread.Subject,
+		ThreadID:     thread.ID,
+		Mailbox:      thread.Mailbox,
+		Participants: nonNil(thread.Participants),
+		DateStart:    f.formatTime(thread.DateRange.Start),
+		DateEnd:      f.formatTime(thread.DateRange.End),
+		Labels:       nonNil(thread.Labels),
+		URL:          ThreadURL(thread.Mailbox, thread.ID),
+	}
+	sb.WriteString("---\n")
+	enc := yaml.NewEncoder(&sb)
+	enc.SetIndent(2)
+	if err := enc.Encode(frontMatter); err != nil {
+		// The front matter contains only strings, so this can't happen
+		panic(err)
+	}
+	enc.Close()
+	sb.WriteString("---\n\n")
+
+	fmt.Fprintf(&sb, "# %s\n\n", subjectOrPlaceholder(thread.Subject))
+
+	// Numbering is always chronological, display order can be reversed
+	indices := make([]int, len(thread.Messages))
+	for i := range indices {
+		indices[i] = i
+	}
+	if opts.Reverse {
+		slices.Reverse(indices)
+	}
+
+	for _, idx := range indices {
+		msg := thread.Messages[idx]
+		fmt.Fprintf(&sb, "## Message %d: %s\n\n", idx+1, msg.From)
+		if !msg.Date.IsZero() {
+			fmt.Fprintf(&sb, "*%s*\n\n", f.inLocation(msg.Date).Format("Mon, Jan 2, 2006 at 3:04 PM MST"))
+		}
+
+		body := strings.TrimSpace(msg.Body)
+		if opts.MessagesOnly {
+			body = StripQuotedContent(body)
+		} else {
+			body = blockquoteQuotedContent(body)
+		}
+		if body != "" {
+			sb.WriteString(body)
+			sb.WriteString("\n\n")
+		}
+	}
+
+	// Attachments section
+	allAttachments := collectAllAttachments(thread)
+	if len(allAttachments) > 0 {
+		sb.WriteString("## Attachments\n\n")
+		for _, att := range allAttachments {
+			if savedPath, ok := savedAttachments[att.ID]; ok {
+				fmt.Fprintf(&sb, "- [%s](%s)\n", escapeLinkText(att.Filename), f.attachmentLink(savedPath))
+			} else {
+				fmt.Fprintf(&sb, "- %s (not downloaded)\n", att.Filename)
+			}
+		}
+	}
+
+	return strings.TrimRight(sb.String(), "\n") + "\n"
+}
+
+// ThreadURL returns the Gmail web URL of a thread. An empty mailbox means the
+// first signed-in account.
+func ThreadURL(mailbox, threadID string) string {
+	account := "0"
+	if mailbox != "" {
+		account = url.PathEscape(mailbox)
+	}
+	return fmt.Sprintf("https://mail.google.com/mail/u/%s/#all/%s", account, url.PathEscape(threadID))
+}
+
+// attachmentLink returns a link to a saved attachment relative to BaseDir,
+// falling back to the path as saved if it can't be made relative.
+func (f *MarkdownFormatter) attachmentLink(savedPath string) string {
+	base := f.BaseDir
+	if base == "" {
+		base = "."
+	}
+
+	link := savedPath
+	absBase, err1 := filepath.Abs(base)
+	absPath, err2 := filepath.Abs(savedPath)
+	if err1 == nil && err2 == nil {
+		if rel, err := filepath.Rel(absBase, absPath); err == nil {
+			link = rel
+		}
+	}
+
+	// Escape spaces and other characters that would end the link
+	return (&url.URL{Path: filepath.ToSlash(link)}).EscapedPath()
+}
+
+// blockquoteQuotedContent renders quoted reply content as Markdown
+// blockquotes. Lines from a quote attribution onward are prefixed with "> ";
+// lines already starting with ">" are left as they are.
+func blockquoteQuotedContent(body string) string {
+	lines := strings.Split(body, "\n")
+	inQuotedBlock := false
+
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if !inQuotedBlock && startsQuotedBlock(trimmed, lines, i) {
+			inQuotedBlock = true
+		}
+		if !inQuotedBlock || strings.HasPrefix(trimmed, ">") {
+			continue
+		}
+		if trimmed == "" {
+			lines[i] = ">"
+		} else {
+			lines[i] = "> " + line
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// formatTime formats t as RFC 3339 in the formatter's time zone, or "" if zero.
+func (f *MarkdownFormatter) formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return f.inLocation(t).Format(time.RFC3339)
+}
+
+// inLocation converts t to the formatter's time zone.
+func (f *MarkdownFormatter) inLocation(t time.Time) time.Time {
+	if f.Location == nil || t.IsZero() {
+		return t
+	}
+	return t.In(f.Location)
+}
+
+// escapeLinkText escapes brackets that would end Markdown link text.
+func escapeLinkText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "[", `\[`, "]", `\]`).Replace(s)
+}
+
+// pluralize returns "1 message" or "n messages".
+func pluralize(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// subjectOrPlaceholder returns the subject, or a placeholder if it's empty.
+func subjectOrPlaceholder(subject string) string {
+	if subject == "" {
+		return "(no subject)"
+	}
+	return subject
+}
@@ -0,0 +1,91 @@
+package output
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bentsolheim/gmail-cli/internal/gmail"
+)
+
+func TestMarkdownFormatter_Golden(t *testing.T) {
+	base := t.TempDir()
+	formatter := &MarkdownFormatter{Location: time.UTC, BaseDir: base}
+
+	thread := goldenThread()
+	thread.Labels = []string{"INBOX", "Projects/Conversion"}
+
+	t.Run("thread", func(t *testing.T) {
+		saved := map[string]string{"att1": filepath.Join(base, "attachments", "conversion factors.xlsx")}
+		checkGolden(t, "thread.md", formatter.FormatThread(thread, saved, FormatOptions{}))
+	})
+
+	t.Run("thread reversed, messages only", func(t *testing.T) {
+		checkGolden(t, "thread_reverse_messages_only.md",
+			formatter.FormatThread(thread, nil, FormatOptions{Reverse: true, MessagesOnly: true}))
+	})
+
+	t.Run("search results", func(t *testing.T) {
+		result := &gmail.SearchResult{
+			Threads: []gmail.ThreadSummary{
+				{
+					ID:              "18c1234abcd5678",
+					Subject:         "Re: [ext] Conversion factors",
+					Participants:    []string{"Felipe Garcia", "me"},
+					LastMessageDate: time.Date(2025, 12, 11, 14, 5, 0, 0, time.UTC),
+					MessageCount:    2,
+					AttachmentCount: 2,
+				},
+				{
+					ID:              "18c0000ffff0001",
+					Participants:    []string{"Alice"},
+					LastMessageDate: time.Date(2025, 12, 1, 9, 0, 0, 0, time.UTC),
+					MessageCount:    1,
+				},
+			},
+		}
+		checkGolden(t, "search.md", formatter.FormatSearchResults(result))
+	})
+}
+
+func TestBlockquoteQuotedContent(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "no quotes",
+			input: "Hello\n\nThanks",
+			want:  "Hello\n\nThanks",
+		},
+		{
+			name:  "Gmail reply keeps existing quote markers",
+			input: "Sounds good.\n\nOn Mon, Jan 1, 2025 at 10:00 AM Bob <bob@example.com> wrote:\n> Lunch?\n>\n> Bob",
+			want:  "Sounds good.\n\n> On Mon, Jan 1, 2025 at 10:00 AM Bob <bob@example.com> wrote:\n> Lunch?\n>\n> Bob",
+		},
+		{
+			name:  "Outlook block is quoted",
+			input: "See below.\n\nFrom: Bob\nSent: Monday\nTo: Alice\nSubject: Lunch\n\nLunch?",
+			want:  "See below.\n\n> From: Bob\n> Sent: Monday\n> To: Alice\n> Subject: Lunch\n>\n> Lunch?",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := blockquoteQuotedContent(tt.input); got != tt.want {
+				t.Errorf("blockquoteQuotedContent() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestThreadURL(t *testing.T) {
+	if got, want := ThreadURL("", "18c1"), "https://mail.google.com/mail/u/0/#all/18c1"; got != want {
+		t.Errorf("ThreadURL() = %q, want %q", got, want)
+	}
+	if got := ThreadURL("boss@example.com", "18c1"); !strings.Contains(got, "/u/boss@example.com/") {
+		t.Errorf("ThreadURL() = %q, want the delegated mailbox in the URL", got)
+	}
+}
@@ -1,6 +1,7 @@
 package output
 
 import (
+	"fmt"
 	"regexp"
 	"strings"
 )
@@ -36,6 +37,20 @@ var quoteAttributionPatterns = []*regexp.Regexp{
 	regexp.MustCompile(`(?im)^-+\s*Forwarded message\s*-+\s*$`),
 }
 
+// AddQuotePatterns adds regular expressions that mark the start of a quoted
+// block, for email clients or languages the built-in patterns don't cover.
+// Each pattern is matched against a single trimmed line.
+func AddQuotePatterns(patterns []string) error {
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return fmt.Errorf("invalid quote pattern %q: %w", p, err)
+		}
+		quoteAttributionPatterns = append(quoteAttributionPatterns, re)
+	}
+	return nil
+}
+
 // StripQuotedContent removes quoted reply content from an email body,
 // preserving only the new content written by the sender.
 func StripQuotedContent(body string) string {
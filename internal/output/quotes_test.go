@@ -148,3 +148,25 @@ My response to point 2.`
 		t.Errorf("Should preserve 'My response to point 1.' but got: %q", result)
 	}
 }
+
+func TestAddQuotePatterns(t *testing.T) {
+	saved := quoteAttributionPatterns
+	t.Cleanup(func() { quoteAttributionPatterns = saved })
+
+	input := "Merci !\n\nLe 8 déc. 2025 à 14:30, Marie a écrit :\nTexte cité"
+
+	if got := StripQuotedContent(input); got != input {
+		t.Fatalf("StripQuotedContent() before AddQuotePatterns = %q", got)
+	}
+
+	if err := AddQuotePatterns([]string{`^Le .+ a écrit :$`}); err != nil {
+		t.Fatalf("AddQuotePatterns() error = %v", err)
+	}
+	if got := StripQuotedContent(input); got != "Merci !" {
+		t.Errorf("StripQuotedContent() = %q, want %q", got, "Merci !")
+	}
+
+	if err := AddQuotePatterns([]string{"("}); err == nil {
+		t.Error("AddQuotePatterns() should reject an invalid pattern")
+	}
+}
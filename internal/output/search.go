@@ -10,7 +10,14 @@ import (
 // FormatSearchResults formats search results for display.
 // Output format:
 // [1] Dec 11 | Felipe Garcia | Re: Conversion factors (3 messages, 2 attachments)
-func (f *TextFormatter) FormatSearchResults(results []gmail.ThreadSummary) string {
+//
+// Ranked local results add the score and a snippet:
+// [1] Dec 11 | Felipe Garcia | Re: Conversion factors (3 messages, 2 attachments) [score 4.21]
+//     ...the **conversion** factors for...
+//
+// followed by the next page token when more results are available.
+func (f *TextFormatter) FormatSearchResults(result *gmail.SearchResult) string {
+	results := result.Threads
 	if len(results) == 0 {
 		return "No results found."
 	}
@@ -18,7 +25,7 @@ func (f *TextFormatter) FormatSearchResults(results []gmail.ThreadSummary) strin
 	var sb strings.Builder
 	for i, r := range results {
 		// Format date as "Dec 11"
-		date := r.LastMessageDate.Format("Jan 2")
+		date := f.inLocation(r.LastMessageDate).Format("Jan 2")
 
 		// Format participants (join with ", ", truncate if too long)
 		participants := strings.Join(r.Participants, ", ")
@@ -46,8 +53,19 @@ func (f *TextFormatter) FormatSearchResults(results []gmail.ThreadSummary) strin
 		counts := strings.Join(countParts, ", ")
 
 		// Write the line
-		fmt.Fprintf(&sb, "[%d] %s | %s | %s | %s (%s)\n",
+		fmt.Fprintf(&sb, "[%d] %s | %s | %s | %s (%s)",
 			i+1, r.ID, date, participants, r.Subject, counts)
+		if r.Score > 0 {
+			fmt.Fprintf(&sb, " [score %.2f]", r.Score)
+		}
+		sb.WriteString("\n")
+		if r.Snippet != "" {
+			fmt.Fprintf(&sb, "    %s\n", r.Snippet)
+		}
+	}
+
+	if result.NextPageToken != "" {
+		fmt.Fprintf(&sb, "\nNext page token: %s\n", result.NextPageToken)
 	}
 
 	return sb.String()
@@ -0,0 +1,185 @@
+package output
+
+import (
+	"fmt"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bentsolheim/gmail-cli/internal/gmail"
+)
+
+// Names of the optional templates a template file can define to render
+// search results and threads differently. Without them the whole file is
+// used for both.
+const (
+	searchTemplateName = "search"
+	threadTemplateName = "thread"
+)
+
+// ThreadData is what a template renders for a thread. The thread's fields
+// are available directly, e.g. {{.Subject}} and {{range .Messages}}.
+type ThreadData struct {
+	*gmail.Thread
+	// SavedAttachments maps attachment IDs to the paths they were saved to.
+	SavedAttachments map[string]string
+}
+
+// TemplateFormatter implements Formatter with a user-defined text/template.
+// Search results are rendered with []gmail.ThreadSummary as data, and
+// threads with ThreadData.
+type TemplateFormatter struct {
+	tmpl *template.Template
+	// Location is the time zone the date helpers use. Nil keeps each
+	// message's own zone.
+	Location *time.Location
+	err      error
+}
+
+// LoadTemplate parses a template file.
+func LoadTemplate(path string) (*TemplateFormatter, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template: %w", err)
+	}
+	return NewTemplateFormatter(filepath.Base(path), string(b))
+}
+
+// NewTemplateFormatter parses a template from text.
+func NewTemplateFormatter(name, text string) (*TemplateFormatter, error) {
+	f := &TemplateFormatter{}
+	tmpl, err := template.New(name).Option("missingkey=error").Funcs(f.funcs()).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template: %w", err)
+	}
+	f.tmpl = tmpl
+	return f, nil
+}
+
+// Err returns the first error from rendering the template, if any.
+func (f *TemplateFormatter) Err() error {
+	return f.err
+}
+
+// FormatSearchResults renders the search results with the "search" template,
+// or the whole template if it defines none.
+func (f *TemplateFormatter) FormatSearchResults(result *gmail.SearchResult) string {
+	return f.execute(searchTemplateName, result.Threads)
+}
+
+// FormatThread renders the thread with the "thread" template, or the whole
+// template if it defines none. Messages are reversed and quoted content is
+// stripped according to opts before rendering.
+func (f *TemplateFormatter) FormatThread(thread *gmail.Thread, savedAttachments map[string]string, opts FormatOptions) string {
+	t := *thread
+	t.Messages = slices.Clone(thread.Messages)
+	if opts.MessagesOnly {
+		for i := range t.Messages {
+			t.Messages[i].Body = StripQuotedContent(strings.TrimSpace(t.Messages[i].Body))
+		}
+	}
+	if opts.Reverse {
+		slices.Reverse(t.Messages)
+	}
+
+	if savedAttachments == nil {
+		savedAttachments = map[string]string{}
+	}
+	return f.execute(threadTemplateName, ThreadData{Thread: &t, SavedAttachments: savedAttachments})
+}
+
+// execute renders the named template, falling back to the root template.
+func (f *TemplateFormatter) execute(name string, data any) string {
+	tmpl := f.tmpl.Lookup(name)
+	if tmpl == nil {
+		tmpl = f.tmpl
+	}
+
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		if f.err == nil {
+			f.err = fmt.Errorf("failed to render template: %w", err)
+		}
+		return ""
+	}
+	return sb.String()
+}
+
+// funcs returns the helper functions available to templates.
+func (f *TemplateFormatter) funcs() template.FuncMap {
+	return template.FuncMap{
+		// {{date "Jan 2, 2006" .Date}} formats a time, "" if unknown
+		"date": func(layout string, t time.Time) string {
+			if t.IsZero() {
+				return ""
+			}
+			if f.Location != nil {
+				t = t.In(f.Location)
+			}
+			return t.Format(layout)
+		},
+		// {{isodate .Date}} formats a time as RFC 3339
+		"isodate": func(t time.Time) string {
+			if t.IsZero() {
+				return ""
+			}
+			if f.Location != nil {
+				t = t.In(f.Location)
+			}
+			return t.Format(time.RFC3339)
+		},
+		"truncate":    truncate,
+		"stripQuotes": StripQuotedContent,
+		"name":        addressName,
+		"email":       addressEmail,
+		"join":        func(sep string, s []string) string { return strings.Join(s, sep) },
+		"indent":      indent,
+		"trim":        strings.TrimSpace,
+		"upper":       strings.ToUpper,
+		"lower":       strings.ToLower,
+	}
+}
+
+// truncate shortens s to at most n characters, ending with "..." if cut.
+func truncate(n int, s string) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	if n <= 3 {
+		return string([]rune(s)[:n])
+	}
+	return string([]rune(s)[:n-3]) + "..."
+}
+
+// addressName returns the display name of an address like
+// "Felipe Garcia <felipe@example.com>", or the email if there is no name.
+func addressName(address string) string {
+	addr, err := mail.ParseAddress(address)
+	if err != nil {
+		return strings.TrimSpace(address)
+	}
+	if addr.Name != "" {
+		return addr.Name
+	}
+	return addr.Address
+}
+
+// addressEmail returns the email of an address like
+// "Felipe Garcia <felipe@example.com>".
+func addressEmail(address string) string {
+	addr, err := mail.ParseAddress(address)
+	if err != nil {
+		return strings.TrimSpace(address)
+	}
+	return addr.Address
+}
+
+// indent prefixes every line of s with prefix.
+func indent(prefix, s string) string {
+	return prefix + strings.ReplaceAll(s, "\n", "\n"+prefix)
+}
@@ -0,0 +1,119 @@
+package output
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bentsolheim/gmail-cli/internal/gmail"
+)
+
+func TestTemplateFormatter(t *testing.T) {
+	const text = `{{define "search"}}{{range .}}{{.ID}}	{{date "2006-01-02" .LastMessageDate}}	{{truncate 12 .Subject}}
+{{end}}{{end}}{{define "thread"}}# {{.Subject}} [{{join ", " .Labels}}]
+{{range .Messages}}{{name .From}} <{{email .From}}> {{isodate .Date}}
+{{indent "  " (trim .Body)}}
+{{range .Attachments}}  @ {{.Filename}} -> {{index $.SavedAttachments .ID}}
+{{end}}{{end}}{{end}}`
+
+	formatter, err := NewTemplateFormatter("test", text)
+	if err != nil {
+		t.Fatalf("NewTemplateFormatter() error = %v", err)
+	}
+	formatter.Location = time.UTC
+
+	t.Run("search results", func(t *testing.T) {
+		got := formatter.FormatSearchResults(&gmail.SearchResult{Threads: []gmail.ThreadSummary{
+			{ID: "t1", Subject: "Re: Conversion factors", LastMessageDate: time.Date(2025, 12, 11, 23, 30, 0, 0, time.FixedZone("PST", -8*3600))},
+		}})
+		want := "t1\t2025-12-12\tRe: Conve...\n"
+		if got != want {
+			t.Errorf("FormatSearchResults() = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("thread", func(t *testing.T) {
+		thread := goldenThread()
+		thread.Labels = []string{"INBOX", "Work"}
+		saved := map[string]string{"att1": "/out/conversion_factors.xlsx"}
+
+		got := formatter.FormatThread(thread, saved, FormatOptions{Reverse: true, MessagesOnly: true})
+		want := `# Re: Conversion factors [INBOX, Work]
+you@example.com <you@example.com> 2025-12-11T13:05:00Z
+  Thanks!
+Felipe Garcia <felipe@example.com> 2025-12-09T09:30:00Z
+  Here are the <updated> factors.
+  @ conversion_factors.xlsx -> /out/conversion_factors.xlsx
+  @ notes.pdf -> 
+`
+		if got != want {
+			t.Errorf("FormatThread() =\n%s\nwant\n%s", got, want)
+		}
+
+		// The caller's thread is not modified
+		if thread.Messages[0].ID != "msg1" {
+			t.Error("FormatThread() should not reorder the caller's messages")
+		}
+	})
+
+	if err := formatter.Err(); err != nil {
+		t.Errorf("Err() = %v", err)
+	}
+}
+
+func TestTemplateFormatter_WholeTemplate(t *testing.T) {
+	formatter, err := NewTemplateFormatter("test", `{{len .}} results`)
+	if err != nil {
+		t.Fatalf("NewTemplateFormatter() error = %v", err)
+	}
+
+	got := formatter.FormatSearchResults(&gmail.SearchResult{Threads: make([]gmail.ThreadSummary, 3)})
+	if got != "3 results" {
+		t.Errorf("FormatSearchResults() = %q, want %q", got, "3 results")
+	}
+}
+
+func TestTemplateFormatter_Errors(t *testing.T) {
+	if _, err := NewTemplateFormatter("test", `{{.Subject`); err == nil {
+		t.Error("NewTemplateFormatter() should fail on a syntax error")
+	}
+	if _, err := NewTemplateFormatter("test", `{{nosuchfunc .}}`); err == nil {
+		t.Error("NewTemplateFormatter() should fail on an unknown function")
+	}
+
+	formatter, err := NewTemplateFormatter("test", `{{.NoSuchField}}`)
+	if err != nil {
+		t.Fatalf("NewTemplateFormatter() error = %v", err)
+	}
+	if got := formatter.FormatThread(goldenThread(), nil, FormatOptions{}); got != "" {
+		t.Errorf("FormatThread() = %q, want empty output on error", got)
+	}
+	if formatter.Err() == nil {
+		t.Error("Err() should report the render error")
+	}
+}
+
+func TestTemplateHelpers(t *testing.T) {
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"truncate short", truncate(10, "short"), "short"},
+		{"truncate long", truncate(8, "a long subject"), "a lon..."},
+		{"truncate multibyte", truncate(5, "blåbærsyltetøy"), "bl..."},
+		{"name with display name", addressName("Felipe Garcia <felipe@example.com>"), "Felipe Garcia"},
+		{"name without display name", addressName("felipe@example.com"), "felipe@example.com"},
+		{"name of encoded word", addressName("=?UTF-8?Q?Bj=C3=B8rn?= <bjorn@example.com>"), "Bjørn"},
+		{"email", addressEmail("Felipe Garcia <felipe@example.com>"), "felipe@example.com"},
+		{"email unparseable", addressEmail(" not an address "), "not an address"},
+		{"indent", indent("> ", "a\nb"), "> a\n> b"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("got %q, want %q", tt.got, tt.want)
+			}
+		})
+	}
+}
@@ -25,9 +25,15 @@ func (f *TextFormatter) FormatThread(thread *gmail.Thread, savedAttachments map[
 	var sb strings.Builder
 
 	// Header
+	if thread.Mailbox != "" {
+		fmt.Fprintf(&sb, "Mailbox: %s\n", thread.Mailbox)
+	}
 	fmt.Fprintf(&sb, "Subject: %s\n", thread.Subject)
 	fmt.Fprintf(&sb, "Participants: %s\n", strings.Join(thread.Participants, ", "))
-	fmt.Fprintf(&sb, "Date Range: %s\n", formatDateRange(thread.DateRange))
+	fmt.Fprintf(&sb, "Date Range: %s\n", formatDateRange(gmail.DateRange{
+		Start: f.inLocation(thread.DateRange.Start),
+		End:   f.inLocation(thread.DateRange.End),
+	}))
 	sb.WriteString("\n")
 
 	// Build message indices for proper numbering (always chronological)
@@ -46,7 +52,7 @@ func (f *TextFormatter) FormatThread(thread *gmail.Thread, savedAttachments map[
 	for _, idx := range indices {
 		msg := messages[idx]
 		// Message header - number is always chronological (1 = oldest)
-		date := msg.Date.Format("Jan 2, 3:04 PM")
+		date := f.inLocation(msg.Date).Format("Jan 2, 3:04 PM")
 		fmt.Fprintf(&sb, "--- Message %d (%s) ---\n", idx+1, date)
 		fmt.Fprintf(&sb, "From: %s\n", msg.From)
 
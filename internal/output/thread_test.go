@@ -169,3 +169,22 @@ On Dec 1, 2025, alice@example.com wrote:
 		t.Errorf("'Original question' should appear exactly once (not in quote), but found %d times", count)
 	}
 }
+
+func TestFormatThread_Mailbox(t *testing.T) {
+	thread := &gmail.Thread{
+		ID:      "thread1",
+		Subject: "Test Subject",
+	}
+
+	formatter := NewTextFormatter()
+
+	if result := formatter.FormatThread(thread, nil, FormatOptions{}); strings.Contains(result, "Mailbox:") {
+		t.Error("Own mailbox should not show a Mailbox header")
+	}
+
+	thread.Mailbox = "boss@example.com"
+	result := formatter.FormatThread(thread, nil, FormatOptions{})
+	if !strings.HasPrefix(result, "Mailbox: boss@example.com\nSubject: Test Subject\n") {
+		t.Errorf("Delegated mailbox should be shown in the header, got:\n%s", result)
+	}
+}
@@ -0,0 +1,179 @@
+package query
+
+import (
+	"path"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/bentsolheim/gmail-cli/internal/gmail"
+)
+
+// Match reports whether any message in a thread matches the query. As in
+// Gmail, the whole query must match a single message.
+func (q *Query) Match(thread *gmail.Thread) bool {
+	for i := range thread.Messages {
+		if q.MatchMessage(thread, &thread.Messages[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchMessage reports whether a message of thread matches the query.
+// Labels are matched by name, so msg.Labels should be resolved from IDs.
+func (q *Query) MatchMessage(thread *gmail.Thread, msg *gmail.Message) bool {
+	return q.eval(q.Root, thread, msg)
+}
+
+func (q *Query) eval(node Node, thread *gmail.Thread, msg *gmail.Message) bool {
+	switch n := node.(type) {
+	case *And:
+		for _, child := range n.Children {
+			if !q.eval(child, thread, msg) {
+				return false
+			}
+		}
+		return true
+	case *Or:
+		for _, child := range n.Children {
+			if q.eval(child, thread, msg) {
+				return true
+			}
+		}
+		return false
+	case *Not:
+		return !q.eval(n.Child, thread, msg)
+	case *Term:
+		return q.evalTerm(n, thread, msg)
+	}
+	return false
+}
+
+func (q *Query) evalTerm(t *Term, thread *gmail.Thread, msg *gmail.Message) bool {
+	value := strings.ToLower(t.Value)
+	subject := msg.Subject
+	if subject == "" {
+		subject = thread.Subject
+	}
+
+	switch t.Field {
+	case "from":
+		return containsWords(msg.From, value)
+	case "to":
+		// Like Gmail, to: includes copied recipients
+		return containsWords(msg.To, value) || containsWords(msg.Cc, value) || containsWords(msg.Bcc, value)
+	case "cc":
+		return containsWords(msg.Cc, value)
+	case "bcc":
+		return containsWords(msg.Bcc, value)
+	case "subject":
+		return containsWords(subject, value)
+	case "label":
+		return hasLabel(msg, value)
+	case "is":
+		switch value {
+		case "read":
+			return !hasLabel(msg, "unread")
+		case "unread", "starred", "important":
+			return hasLabel(msg, value)
+		}
+		return false
+	case "in":
+		if value == "anywhere" {
+			return true
+		}
+		if value == "drafts" {
+			value = "draft"
+		}
+		return hasLabel(msg, value)
+	case "has":
+		return value == "attachment" && len(msg.Attachments) > 0
+	case "filename":
+		return hasFilename(msg, value)
+	case "after", "before":
+		date, _ := parseDate(t.Value)
+		if msg.Date.IsZero() {
+			return false
+		}
+		if t.Field == "after" {
+			return !msg.Date.Before(date)
+		}
+		return msg.Date.Before(date)
+	case "newer_than", "older_than":
+		date, _ := parseAge(t.Value, q.Now)
+		if msg.Date.IsZero() {
+			return false
+		}
+		if t.Field == "newer_than" {
+			return msg.Date.After(date)
+		}
+		return msg.Date.Before(date)
+	case "larger", "smaller":
+		size, _ := parseSize(t.Value)
+		if t.Field == "larger" {
+			return msg.Size > size
+		}
+		return msg.Size < size
+	}
+
+	fields := []string{subject, msg.From, msg.To, msg.Cc, msg.Body}
+	for _, att := range msg.Attachments {
+		fields = append(fields, att.Filename)
+	}
+	for _, field := range fields {
+		if containsWords(field, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsWords reports whether the words of value appear in text in order
+// and next to each other, ignoring case and punctuation, so "felipe" matches
+// "Felipe Garcia <felipe@example.com>" but not "Felipes".
+func containsWords(text, value string) bool {
+	want := words(value)
+	if len(want) == 0 {
+		return false
+	}
+	have := words(text)
+	for i := 0; i+len(want) <= len(have); i++ {
+		if slices.Equal(have[i:i+len(want)], want) {
+			return true
+		}
+	}
+	return false
+}
+
+// words splits text into lowercase runs of letters and digits.
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// hasLabel matches a label by name, ignoring case. As in Gmail, "-" in the
+// query matches spaces and slashes in the name.
+func hasLabel(msg *gmail.Message, value string) bool {
+	normalize := strings.NewReplacer(" ", "-", "/", "-").Replace
+	for _, label := range msg.Labels {
+		label = strings.ToLower(label)
+		if label == value || normalize(label) == normalize(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasFilename matches an attachment by name or, as in filename:pdf, by
+// extension.
+func hasFilename(msg *gmail.Message, value string) bool {
+	for _, att := range msg.Attachments {
+		name := strings.ToLower(att.Filename)
+		if name == value || strings.TrimPrefix(path.Ext(name), ".") == value || containsWords(name, value) {
+			return true
+		}
+	}
+	return false
+}
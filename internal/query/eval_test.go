@@ -0,0 +1,125 @@
+package query
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bentsolheim/gmail-cli/internal/gmail"
+)
+
+func TestMatch(t *testing.T) {
+	date := func(s string) time.Time {
+		d, _ := time.ParseInLocation("2006-01-02 15:04", s, time.Local)
+		return d
+	}
+	thread := &gmail.Thread{
+		ID:      "t1",
+		Subject: "Conversion plan",
+		Messages: []gmail.Message{
+			{
+				ID:      "m1",
+				From:    "Felipe Garcia <felipe@example.com>",
+				To:      "Bob <bob@example.com>",
+				Cc:      "Carol <carol@example.com>",
+				Subject: "Conversion plan",
+				Date:    date("2025-01-10 12:00"),
+				Body:    "Let's meet on Monday to go through the factors.",
+				Labels:  []string{"INBOX", "Projects/Conversion", "UNREAD"},
+				Size:    3 << 20,
+				Attachments: []gmail.Attachment{
+					{ID: "a1", Filename: "Factors.PDF"},
+				},
+			},
+			{
+				ID:      "m2",
+				From:    "Bob <bob@example.com>",
+				To:      "Felipe Garcia <felipe@example.com>",
+				Subject: "Re: Conversion plan",
+				Date:    date("2025-01-20 09:30"),
+				Body:    "Sounds good.",
+				Labels:  []string{"SENT", "STARRED"},
+				Size:    2048,
+			},
+		},
+	}
+	now := date("2025-01-25 00:00")
+
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{query: "", want: true},
+		{query: "felipe", want: true},
+		{query: "feli", want: false},
+		{query: "FELIPE@example.com", want: true},
+		{query: "from:felipe", want: true},
+		{query: "from:carol", want: false},
+		{query: "to:carol", want: true},
+		{query: "cc:carol", want: true},
+		{query: "cc:bob", want: false},
+		{query: "subject:plan", want: true},
+		{query: `"meet on monday"`, want: true},
+		{query: `"monday on meet"`, want: false},
+		{query: "has:attachment", want: true},
+		{query: "filename:pdf", want: true},
+		{query: "filename:factors.pdf", want: true},
+		{query: "filename:csv", want: false},
+		{query: "factors.pdf", want: true},
+		{query: "label:projects-conversion", want: true},
+		{query: "label:projects/conversion", want: true},
+		{query: "label:work", want: false},
+		{query: "is:unread", want: true},
+		{query: "is:starred", want: true},
+		{query: "is:important", want: false},
+		{query: "in:sent", want: true},
+		{query: "in:anywhere", want: true},
+		{query: "after:2025/01/20", want: true},
+		{query: "after:2025/01/21", want: false},
+		{query: "before:2025/01/10", want: false},
+		{query: "before:2025/01/11", want: true},
+		{query: "newer_than:7d", want: true},
+		{query: "newer_than:2d", want: false},
+		{query: "older_than:1m", want: false},
+		{query: "older_than:10d", want: true},
+		{query: "larger:1M", want: true},
+		{query: "larger:5M", want: false},
+		{query: "smaller:4K", want: true},
+		{query: "smaller:1024", want: false},
+		{query: "-tacos", want: true},
+		{query: "-from:felipe", want: true},
+		{query: "from:felipe OR from:dave", want: true},
+		{query: "{from:dave from:erin}", want: false},
+		{query: "subject:(conversion plan)", want: true},
+		{query: "subject:(conversion tacos)", want: false},
+		// the whole query must match a single message
+		{query: "from:felipe is:starred", want: false},
+		{query: "from:bob is:starred", want: true},
+		{query: "from:bob (is:unread OR sounds)", want: true},
+		{query: "-{is:unread is:starred}", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			q, err := Parse(tt.query)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			q.Now = now
+			if got := q.Match(thread); got != tt.want {
+				t.Errorf("Parse(%q).Match() = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchMessage_ThreadSubject(t *testing.T) {
+	// Messages without a subject of their own match the thread's
+	thread := &gmail.Thread{Subject: "Lunch", Messages: []gmail.Message{{ID: "m1"}}}
+	q, err := Parse("subject:lunch")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if !q.MatchMessage(thread, &thread.Messages[0]) {
+		t.Error("MatchMessage() = false, want true")
+	}
+}
@@ -0,0 +1,496 @@
+// Package query parses Gmail search queries and evaluates them against
+// threads, for backends that search without Gmail.
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Node is a node of a parsed query.
+type Node interface {
+	node()
+}
+
+// And matches if all of its children match. Terms separated by spaces and
+// (parenthesized) groups are ANDed.
+type And struct {
+	Children []Node
+}
+
+// Or matches if any of its children match: terms joined by OR, or a
+// {curly brace} group.
+type Or struct {
+	Children []Node
+}
+
+// Not matches if its child doesn't: a term prefixed with "-".
+type Not struct {
+	Child Node
+}
+
+// Term is a single search term, such as from:felipe or "quoted phrase".
+type Term struct {
+	// Field is the operator, e.g. "from", or "" for a term matched
+	// against the whole message.
+	Field string
+	Value string
+	// Phrase is set for quoted values.
+	Phrase bool
+}
+
+func (*And) node()  {}
+func (*Or) node()   {}
+func (*Not) node()  {}
+func (*Term) node() {}
+
+// Fields lists the supported operators.
+var Fields = []string{
+	"from", "to", "cc", "bcc", "subject", "label", "is", "in", "has",
+	"filename", "after", "before", "newer_than", "older_than", "larger", "smaller",
+}
+
+// Query is a parsed query.
+type Query struct {
+	Root Node
+	// Now is the time newer_than: and older_than: are relative to. Parse
+	// sets it to the current time.
+	Now time.Time
+}
+
+// Parse parses a query in Gmail's search syntax. An empty query matches
+// everything.
+//
+// Terms are ANDed; OR binds tighter, so "a b OR c" is a AND (b OR c).
+// Supported are quoted phrases, -negation, (groups), {OR groups}, AND and
+// OR, and the operators in Fields. Operators apply to a following group, as
+// in subject:(dinner movie). Unknown operators are searched as text, as
+// Gmail does.
+func Parse(query string) (*Query, error) {
+	tokens, err := lex(query)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	root, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, fmt.Errorf("unexpected %s at position %d", t, t.pos+1)
+	}
+
+	return &Query{Root: root, Now: time.Now()}, nil
+}
+
+// Token kinds
+const (
+	tokenEOF = iota
+	tokenWord
+	tokenPhrase
+	tokenOr
+	tokenAnd
+	tokenNot
+	tokenLParen
+	tokenRParen
+	tokenLBrace
+	tokenRBrace
+)
+
+type token struct {
+	kind  int
+	value string
+	pos   int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokenEOF:
+		return "end of query"
+	case tokenPhrase:
+		return strconv.Quote(t.value)
+	}
+	return fmt.Sprintf("%q", t.value)
+}
+
+// lex splits a query into tokens.
+func lex(query string) ([]token, error) {
+	var tokens []token
+	runes := []rune(query)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case r == ' ' || r == '\t' || r == '\n':
+			i++
+		case r == '(':
+			tokens = append(tokens, token{kind: tokenLParen, value: "(", pos: i})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{kind: tokenRParen, value: ")", pos: i})
+			i++
+		case r == '{':
+			tokens = append(tokens, token{kind: tokenLBrace, value: "{", pos: i})
+			i++
+		case r == '}':
+			tokens = append(tokens, token{kind: tokenRBrace, value: "}", pos: i})
+			i++
+		case r == '-' && i+1 < len(runes) && !isSpace(runes[i+1]):
+			tokens = append(tokens, token{kind: tokenNot, value: "-", pos: i})
+			i++
+		case r == '"':
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			if end == len(runes) {
+				return nil, fmt.Errorf("unterminated quote at position %d", i+1)
+			}
+			tokens = append(tokens, token{kind: tokenPhrase, value: string(runes[i+1 : end]), pos: i})
+			i = end + 1
+		default:
+			start := i
+			for i < len(runes) && !isSpace(runes[i]) && !strings.ContainsRune(`(){}"`, runes[i]) {
+				i++
+			}
+			word := string(runes[start:i])
+			kind := tokenWord
+			switch word {
+			case "OR", "|":
+				kind = tokenOr
+			case "AND":
+				kind = tokenAnd
+			}
+			tokens = append(tokens, token{kind: kind, value: word, pos: start})
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, pos: len(runes)}), nil
+}
+
+func isSpace(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\n'
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+// parseAnd parses terms up to the end of the query or group.
+func (p *parser) parseAnd() (Node, error) {
+	var children []Node
+	for {
+		switch p.peek().kind {
+		case tokenEOF, tokenRParen, tokenRBrace:
+			if len(children) == 1 {
+				return children[0], nil
+			}
+			return &And{Children: children}, nil
+		case tokenAnd:
+			p.next()
+			continue
+		}
+
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, node)
+	}
+}
+
+// parseOr parses terms joined by OR.
+func (p *parser) parseOr() (Node, error) {
+	node, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokenOr {
+		return node, nil
+	}
+
+	or := &Or{Children: []Node{node}}
+	for p.peek().kind == tokenOr {
+		op := p.next()
+		switch p.peek().kind {
+		case tokenEOF, tokenRParen, tokenRBrace, tokenOr, tokenAnd:
+			return nil, fmt.Errorf("missing term after OR at position %d", op.pos+1)
+		}
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		or.Children = append(or.Children, node)
+	}
+	return or, nil
+}
+
+// parseUnary parses a term, group or negation.
+func (p *parser) parseUnary() (Node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenNot:
+		child, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &Not{Child: child}, nil
+	case tokenLParen, tokenLBrace:
+		return p.parseGroup(t)
+	case tokenPhrase:
+		return &Term{Value: t.value, Phrase: true}, nil
+	case tokenWord:
+		return p.parseWord(t)
+	case tokenOr:
+		return nil, fmt.Errorf("missing term before OR at position %d", t.pos+1)
+	}
+	return nil, fmt.Errorf("unexpected %s at position %d", t, t.pos+1)
+}
+
+// parseGroup parses the rest of a (group) or {group} opened by open.
+func (p *parser) parseGroup(open token) (Node, error) {
+	closeKind := tokenRParen
+	if open.kind == tokenLBrace {
+		closeKind = tokenRBrace
+	}
+
+	var node Node
+	if open.kind == tokenLParen {
+		var err error
+		node, err = p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		if and, ok := node.(*And); ok && len(and.Children) == 0 {
+			return nil, fmt.Errorf("empty group at position %d", open.pos+1)
+		}
+	} else {
+		or := &Or{}
+		for k := p.peek().kind; k != closeKind && k != tokenEOF; k = p.peek().kind {
+			if k == tokenOr || k == tokenAnd {
+				p.next()
+				continue
+			}
+			child, err := p.parseUnary()
+			if err != nil {
+				return nil, err
+			}
+			or.Children = append(or.Children, child)
+		}
+		switch len(or.Children) {
+		case 0:
+			return nil, fmt.Errorf("empty group at position %d", open.pos+1)
+		case 1:
+			node = or.Children[0]
+		default:
+			node = or
+		}
+	}
+
+	if t := p.next(); t.kind != closeKind {
+		return nil, fmt.Errorf("unclosed %q at position %d", open.value, open.pos+1)
+	}
+	return node, nil
+}
+
+// parseWord parses a word, which may be an operator applied to the word,
+// phrase or group after the colon.
+func (p *parser) parseWord(t token) (Node, error) {
+	field, value, ok := strings.Cut(t.value, ":")
+	if !ok || !isField(field) {
+		return &Term{Value: t.value}, nil
+	}
+	field = strings.ToLower(field)
+
+	if value == "" {
+		next := p.peek()
+		switch next.kind {
+		case tokenPhrase:
+			p.next()
+			term := &Term{Field: field, Value: next.value, Phrase: true}
+			return term, validate(term, t.pos)
+		case tokenLParen, tokenLBrace:
+			p.next()
+			group, err := p.parseGroup(next)
+			if err != nil {
+				return nil, err
+			}
+			return group, applyField(group, field, t.pos)
+		}
+		return nil, fmt.Errorf("missing value for %s: at position %d", field, t.pos+1)
+	}
+
+	term := &Term{Field: field, Value: value}
+	return term, validate(term, t.pos)
+}
+
+// applyField sets field on the terms in a group that have none, as in
+// subject:(dinner movie).
+func applyField(node Node, field string, pos int) error {
+	switch n := node.(type) {
+	case *And:
+		for _, child := range n.Children {
+			if err := applyField(child, field, pos); err != nil {
+				return err
+			}
+		}
+	case *Or:
+		for _, child := range n.Children {
+			if err := applyField(child, field, pos); err != nil {
+				return err
+			}
+		}
+	case *Not:
+		return applyField(n.Child, field, pos)
+	case *Term:
+		if n.Field == "" {
+			n.Field = field
+			return validate(n, pos)
+		}
+	}
+	return nil
+}
+
+func isField(name string) bool {
+	name = strings.ToLower(name)
+	for _, f := range Fields {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+// validate checks the values of operators that take dates and sizes.
+func validate(t *Term, pos int) error {
+	var err error
+	switch t.Field {
+	case "after", "before":
+		_, err = parseDate(t.Value)
+	case "newer_than", "older_than":
+		_, err = parseAge(t.Value, time.Time{})
+	case "larger", "smaller":
+		_, err = parseSize(t.Value)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid %s:%s at position %d: %w", t.Field, t.Value, pos+1, err)
+	}
+	return nil
+}
+
+// parseDate parses a date in after: and before: as Gmail does: YYYY/MM/DD
+// in local time, or seconds since the epoch.
+func parseDate(value string) (time.Time, error) {
+	for _, layout := range []string{"2006/01/02", "2006/1/2", "2006-01-02", "2006-1-2"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	if secs, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(secs, 0), nil
+	}
+	return time.Time{}, fmt.Errorf("use YYYY/MM/DD or seconds since the epoch")
+}
+
+// parseAge returns the time an age in newer_than: and older_than:, like 7d,
+// 2m or 1y, is before now.
+func parseAge(value string, now time.Time) (time.Time, error) {
+	if len(value) < 2 {
+		return time.Time{}, fmt.Errorf("use a number and d, m or y, e.g. 7d")
+	}
+	n, err := strconv.Atoi(value[:len(value)-1])
+	if err != nil || n < 0 {
+		return time.Time{}, fmt.Errorf("use a number and d, m or y, e.g. 7d")
+	}
+	switch strings.ToLower(value[len(value)-1:]) {
+	case "d":
+		return now.AddDate(0, 0, -n), nil
+	case "m":
+		return now.AddDate(0, -n, 0), nil
+	case "y":
+		return now.AddDate(-n, 0, 0), nil
+	}
+	return time.Time{}, fmt.Errorf("use a number and d, m or y, e.g. 7d")
+}
+
+// parseSize parses a size in larger: and smaller: in bytes, or with a K or
+// M suffix.
+func parseSize(value string) (int64, error) {
+	if value == "" {
+		return 0, fmt.Errorf("use a number of bytes, optionally with K or M, e.g. 5M")
+	}
+	multiplier := int64(1)
+	switch strings.ToUpper(value[len(value)-1:]) {
+	case "K":
+		multiplier = 1 << 10
+		value = value[:len(value)-1]
+	case "M":
+		multiplier = 1 << 20
+		value = value[:len(value)-1]
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("use a number of bytes, optionally with K or M, e.g. 5M")
+	}
+	return n * multiplier, nil
+}
+
+// Explain returns the query's tree, one node per line, indented by depth.
+func (q *Query) Explain() string {
+	var sb strings.Builder
+	explain(&sb, q.Root, 0)
+	return sb.String()
+}
+
+func explain(sb *strings.Builder, node Node, depth int) {
+	indent := strings.Repeat("  ", depth)
+	switch n := node.(type) {
+	case *And:
+		if len(n.Children) == 0 {
+			sb.WriteString(indent + "ALL\n")
+			return
+		}
+		sb.WriteString(indent + "AND\n")
+		for _, child := range n.Children {
+			explain(sb, child, depth+1)
+		}
+	case *Or:
+		sb.WriteString(indent + "OR\n")
+		for _, child := range n.Children {
+			explain(sb, child, depth+1)
+		}
+	case *Not:
+		sb.WriteString(indent + "NOT\n")
+		explain(sb, n.Child, depth+1)
+	case *Term:
+		sb.WriteString(indent + n.String() + "\n")
+	}
+}
+
+// String returns the term in query syntax, e.g. from:felipe or
+// subject:"dinner plans". Terms without an operator are shown as text:.
+func (t *Term) String() string {
+	field := t.Field
+	if field == "" {
+		field = "text"
+	}
+	value := t.Value
+	if t.Phrase {
+		value = strconv.Quote(value)
+	}
+	return field + ":" + value
+}
@@ -0,0 +1,81 @@
+package query
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{query: "", want: "ALL"},
+		{query: "felipe", want: "text:felipe"},
+		{query: "from:Felipe", want: "from:Felipe"},
+		{query: "FROM:felipe", want: "from:felipe"},
+		{query: `"dinner plans"`, want: `text:"dinner plans"`},
+		{query: `subject:"dinner plans"`, want: `subject:"dinner plans"`},
+		{query: "from:felipe has:attachment", want: "AND\n  from:felipe\n  has:attachment"},
+		{query: "from:felipe AND has:attachment", want: "AND\n  from:felipe\n  has:attachment"},
+		{query: "-label:work", want: "NOT\n  label:work"},
+		{query: "co-op", want: "text:co-op"},
+		// OR binds tighter than AND
+		{query: "a b OR c", want: "AND\n  text:a\n  OR\n    text:b\n    text:c"},
+		{query: "from:a OR from:b OR from:c", want: "OR\n  from:a\n  from:b\n  from:c"},
+		{query: "(a b) OR c", want: "OR\n  AND\n    text:a\n    text:b\n  text:c"},
+		{query: "{a b -c}", want: "OR\n  text:a\n  text:b\n  NOT\n    text:c"},
+		{query: "subject:(dinner movie)", want: "AND\n  subject:dinner\n  subject:movie"},
+		{query: "from:{alice bob}", want: "OR\n  from:alice\n  from:bob"},
+		{query: "-{a b}", want: "NOT\n  OR\n    text:a\n    text:b"},
+		{query: "after:2025/01/10 before:2025/02/01", want: "AND\n  after:2025/01/10\n  before:2025/02/01"},
+		{query: "newer_than:7d larger:5M", want: "AND\n  newer_than:7d\n  larger:5M"},
+		// unknown operators are searched as text
+		{query: "foo:bar", want: "text:foo:bar"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			q, err := Parse(tt.query)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if got := strings.TrimSuffix(q.Explain(), "\n"); got != tt.want {
+				t.Errorf("Parse(%q).Explain() =\n%s\nwant\n%s", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{query: `"unterminated`, want: "unterminated quote at position 1"},
+		{query: "(a b", want: `unclosed "(" at position 1`},
+		{query: "a b)", want: `unexpected ")" at position 4`},
+		{query: "{a", want: `unclosed "{" at position 1`},
+		{query: "()", want: "empty group at position 1"},
+		{query: "a OR", want: "missing term after OR at position 3"},
+		{query: "OR a", want: "missing term before OR at position 1"},
+		{query: "from:", want: "missing value for from: at position 1"},
+		{query: "after:yesterday", want: "invalid after:yesterday at position 1"},
+		{query: "newer_than:7w", want: "invalid newer_than:7w at position 1"},
+		{query: "subject:x larger:big", want: "invalid larger:big at position 11"},
+		{query: `larger:""`, want: "invalid larger: at position 1"},
+		{query: `smaller:("")`, want: "invalid smaller: at position 1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			_, err := Parse(tt.query)
+			if err == nil {
+				t.Fatalf("Parse(%q) succeeded, want error", tt.query)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Parse(%q) error = %q, want %q", tt.query, err, tt.want)
+			}
+		})
+	}
+}